@@ -27,15 +27,77 @@ func main() {
 		logger.Error("Can't connect to the k8s cluster %s", err)
 		os.Exit(1)
 	}
+	ca, err := coordinator.NewCertificateAuthority()
+	if err != nil {
+		logger.Error("Can't set up the certificate authority: %s", err)
+		os.Exit(1)
+	}
 	jobRepository := db.NewSQLiteJobsRepository(database)
 	taskRepository := db.NewSQLiteTaskRepository(database)
 	jobMetadataManager := coordinator.NewJobMetadataManager(jobRepository, taskRepository)
 	artifactRepository := db.NewSQLiteArtifactRepository(database)
 	artifactManager := coordinator.NewArtifactManager(artifactRepository)
-	jobScheduler := coordinator.NewJobScheduler(k8sClient, taskRepository)
-	jobManagerHandler := handler.NewJobManagerHandler(jobMetadataManager, artifactManager, jobScheduler)
-	artifactHandler := handler.NewArtifactHandler(artifactManager)
-	httpServer, err := server.NewHttpServer(":4750", jobManagerHandler, artifactHandler)
+	connectionRepository := db.NewSQLiteConnectionRepository(database)
+	connectionManager := coordinator.NewConnectionManager(connectionRepository, k8sClient)
+	projectRepository := db.NewSQLiteProjectRepository(database)
+	projectManager := coordinator.NewProjectManager(projectRepository, jobRepository, k8sClient)
+	podProfileRepository := db.NewSQLitePodProfileRepository(database)
+	podProfileManager := coordinator.NewPodProfileManager(podProfileRepository)
+	podTemplates := coordinator.NewPodTemplateLoader(k8sClient)
+	config := coordinator.GetConfig()
+	fairShare := coordinator.NewFairShareScheduler(config.GetMaxWorkerPods())
+	workerCollector := coordinator.NewWorkerCollector(k8sClient, projectManager, jobRepository)
+	taskDispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	workerLogs := coordinator.NewWorkerLogs(k8sClient, projectManager, jobRepository, taskRepository)
+	jobScheduler := coordinator.NewJobScheduler(k8sClient, taskRepository, projectManager, podTemplates, fairShare, workerCollector, workerLogs, taskDispatcher, ca)
+	queueRepository := db.NewSQLiteQueueRepository(database)
+	jobQueue := coordinator.NewJobQueue(queueRepository, jobRepository, taskRepository, artifactRepository, projectManager, jobScheduler, workerCollector, workerLogs)
+	jobQueue.Start()
+	workerCollector.Start()
+	if config.IsPullAssignment() {
+		dispatcherTLSConfig, err := ca.DispatcherTLSConfig()
+		if err != nil {
+			logger.Error("Can't set up the task dispatcher TLS: %s", err)
+			os.Exit(1)
+		}
+		gRPCServer, err := server.NewCoordinatorGrpcServer(":8091", dispatcherTLSConfig, handler.NewTaskDispatcherHandler(taskDispatcher))
+		if err != nil {
+			logger.Error("Can't create listener: %s", err)
+			os.Exit(1)
+		}
+		taskDispatcher.Start()
+		go func() {
+			if err := gRPCServer.Serve(); err != nil {
+				logger.Error("Impossible to serve the task dispatcher: %s", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	jobManagerHandler := handler.NewJobManagerHandler(
+		jobMetadataManager,
+		artifactManager,
+		connectionManager,
+		projectManager,
+		podProfileManager,
+		podTemplates,
+		jobScheduler,
+		jobQueue,
+		workerLogs)
+	artifactHandler := handler.NewArtifactHandler(artifactManager, projectManager)
+	connectionHandler := handler.NewConnectionHandler(connectionManager)
+	projectHandler := handler.NewProjectHandler(projectManager)
+	podProfileHandler := handler.NewPodProfileHandler(podProfileManager)
+	authenticator, err := coordinator.NewAuthenticator(
+		config.GetAPITokensPath(),
+		config.GetJWKSPath(),
+		config.GetJWTIssuer(),
+		config.GetJWTAudience(),
+		config.GetJWTRolesClaim())
+	if err != nil {
+		logger.Error("Can't set up the HTTP API authentication: %s", err)
+		os.Exit(1)
+	}
+	httpServer, err := server.NewHttpServer(":4750", authenticator, jobManagerHandler, artifactHandler, connectionHandler, projectHandler, podProfileHandler)
 	if err != nil {
 		logger.Error("Can't create listener: %s", err)
 		os.Exit(1)
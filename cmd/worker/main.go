@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"time"
 
@@ -8,23 +9,88 @@ import (
 	"github.com/Assifar-Karim/apollo/internal/server"
 	"github.com/Assifar-Karim/apollo/internal/utils"
 	"github.com/Assifar-Karim/apollo/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var startTime = time.Now()
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == worker.SandboxCommand {
+		// The worker re-runs itself to apply the limits of a program invocation before becoming the program
+		err := worker.RunSandboxed(os.Args[2:])
+		fmt.Fprintf(os.Stderr, "can't start the program: %s\n", err)
+		os.Exit(126)
+	}
 	logger := utils.GetLogger()
 	logger.PrintBanner()
 	logger.Info("Startup completed in %v", time.Since(startTime))
-	taskCreatorHandler := handler.NewTaskCreatorHandler(&worker.Worker{})
+	completionIndex, err := worker.GetCompletionIndex()
+	if err != nil {
+		logger.Error("Can't read the completion index: %s", err)
+		os.Exit(1)
+	}
+	if coordinatorAddress, exists := os.LookupEnv(worker.CoordinatorAddressEnv); exists {
+		pullTasks(coordinatorAddress, completionIndex)
+		return
+	}
+	taskCreatorHandler := handler.NewTaskCreatorHandler(completionIndex)
 	gRPCserver, err := server.NewGrpcServer(":8090", *taskCreatorHandler)
 	if err != nil {
 		logger.Error("Can't create listener: %s", err)
 		os.Exit(1)
 	}
+	taskResult := make(chan error, 1)
+	if completionIndex != nil {
+		// Indexed Job pods exit once their task is over so that Kubernetes can complete or retry their index
+		logger.Info("Running the task of completion index %v", *completionIndex)
+		worker.AllowLegacySockets(true)
+		go func() {
+			taskResult <- <-taskCreatorHandler.Done()
+			gRPCserver.GracefulStop()
+		}()
+	}
 	err = gRPCserver.Serve()
 	if err != nil {
 		logger.Error("Impossible to serve: %s", err)
 		os.Exit(1)
 	}
+	select {
+	case taskErr := <-taskResult:
+		if taskErr != nil {
+			os.Exit(1)
+		}
+	default:
+	}
+}
+
+// pullTasks runs the tasks handed out by the coordinator task dispatcher instead of waiting for the coordinator to
+// send them
+func pullTasks(coordinatorAddress string, completionIndex *int64) {
+	logger := utils.GetLogger()
+	// The task dispatcher only hands tasks out to the workers presenting the certificate issued for their job
+	tlsConfig, err := utils.NewWorkerClientTLSConfig(utils.TLSDir)
+	if err != nil {
+		logger.Error("Can't load the worker certificate: %s", err)
+		os.Exit(1)
+	}
+	conn, err := grpc.NewClient(coordinatorAddress, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		logger.Error("Can't reach the task dispatcher: %s", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	puller, err := worker.NewTaskPuller(conn, &worker.Worker{}, completionIndex)
+	if err != nil {
+		logger.Error("Can't set up the task puller: %s", err)
+		os.Exit(1)
+	}
+	// The pulled tasks run one at a time, they can keep their sockets where the older programs look for them
+	worker.AllowLegacySockets(true)
+	logger.Info("Pulling tasks from %s", coordinatorAddress)
+	if err := puller.Run(); err != nil {
+		logger.Error("Task puller stopped: %s", err)
+		conn.Close()
+		os.Exit(1)
+	}
 }
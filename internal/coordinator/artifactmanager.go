@@ -12,7 +12,7 @@ import (
 )
 
 type ArtifactManager interface {
-	CreateArtifact(filename, artifactType string, size int64, file io.Reader) (db.Artifact, error)
+	CreateArtifact(filename, artifactType, owner, project string, size int64, file io.Reader) (db.Artifact, error)
 	GetAllArtifactDetails() ([]db.Artifact, error)
 	GetArtifactDetailsByName(filename string) (*db.Artifact, error)
 	DeleteArtifact(filename string) (bool, error)
@@ -48,7 +48,7 @@ func writeFile(path string, fileContent []byte) error {
 	return nil
 }
 
-func (s ArtifactMngmtSvc) CreateArtifact(filename, artifactType string, size int64, file io.Reader) (db.Artifact, error) {
+func (s ArtifactMngmtSvc) CreateArtifact(filename, artifactType, owner, project string, size int64, file io.Reader) (db.Artifact, error) {
 	path := fmt.Sprintf("%s/%s", s.config.GetArtifactsPath(), filename)
 	fileContent, err := getFileContent(file)
 	if err != nil {
@@ -69,7 +69,7 @@ func (s ArtifactMngmtSvc) CreateArtifact(filename, artifactType string, size int
 			s.logger.Error(err.Error())
 			return db.Artifact{}, err
 		}
-		return s.artifactRepository.CreateArtifact(filename, artifactType, fileHash, size)
+		return s.artifactRepository.CreateArtifact(filename, artifactType, fileHash, owner, project, size)
 	}
 
 	if fileHash == artifact.Hash {
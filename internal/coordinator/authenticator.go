@@ -0,0 +1,286 @@
+package coordinator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+type Role int
+
+const (
+	RoleViewer Role = iota + 1
+	RoleSubmitter
+	RoleAdmin
+)
+
+var roleNames = map[string]Role{
+	"viewer":    RoleViewer,
+	"submitter": RoleSubmitter,
+	"admin":     RoleAdmin,
+}
+
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+type Principal struct {
+	User string
+	Role Role
+}
+
+type Authenticator interface {
+	// Authenticate resolves the principal behind an Authorization header value
+	Authenticate(authorization string) (*Principal, error)
+	IsEnabled() bool
+}
+
+type apiToken struct {
+	User string `json:"user"`
+	Role string `json:"role"`
+	// Hex encoded SHA-256 digest of the token, the plain token is never stored
+	TokenHash string `json:"tokenHash"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type AuthenticationSvc struct {
+	tokens     map[string]Principal
+	keys       map[string]crypto.PublicKey
+	issuer     string
+	audience   string
+	rolesClaim string
+	logger     *utils.Logger
+}
+
+func (s AuthenticationSvc) IsEnabled() bool {
+	return len(s.tokens) > 0 || len(s.keys) > 0
+}
+
+func (s AuthenticationSvc) Authenticate(authorization string) (*Principal, error) {
+	token, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || token == "" {
+		return nil, ErrUnauthenticated
+	}
+	digest := sha256.Sum256([]byte(token))
+	if principal, exists := s.tokens[fmt.Sprintf("%x", digest)]; exists {
+		return &principal, nil
+	}
+	if len(s.keys) == 0 || strings.Count(token, ".") != 2 {
+		return nil, ErrUnauthenticated
+	}
+	principal, err := s.verifyJWT(token)
+	if err != nil {
+		s.logger.Warn("JWT rejected -> %v", err)
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func (s AuthenticationSvc) verifyJWT(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, err
+	}
+	key, exists := s.keys[header.Kid]
+	if !exists {
+		return nil, fmt.Errorf("unknown key id %s", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("unsupported algorithm %s for RSA key", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, err
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(signature) != 64 {
+			return nil, fmt.Errorf("unsupported algorithm %s for EC key", header.Alg)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		sig := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, digest[:], r, sig) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || now >= exp {
+		return nil, fmt.Errorf("token is expired or has no expiration")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, fmt.Errorf("token isn't valid yet")
+	}
+	if s.issuer != "" && claims["iss"] != s.issuer {
+		return nil, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if s.audience != "" && !slices.Contains(claimStrings(claims["aud"]), s.audience) {
+		return nil, fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+
+	user, _ := claims["preferred_username"].(string)
+	if user == "" {
+		user, _ = claims["sub"].(string)
+	}
+	var role Role
+	for _, roleName := range claimStrings(claims[s.rolesClaim]) {
+		role = max(role, roleNames[roleName])
+	}
+	if user == "" || role == 0 {
+		return nil, fmt.Errorf("token doesn't carry a subject and a known role")
+	}
+	return &Principal{User: user, Role: role}, nil
+}
+
+func claimStrings(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := []string{}
+		for _, v := range value {
+			if str, ok := v.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func loadAPITokens(path string) (map[string]Principal, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []apiToken
+	if err := json.Unmarshal(content, &tokens); err != nil {
+		return nil, err
+	}
+	principals := make(map[string]Principal)
+	for _, token := range tokens {
+		role, exists := roleNames[token.Role]
+		if !exists {
+			return nil, fmt.Errorf("unknown role %s for user %s", token.Role, token.User)
+		}
+		principals[strings.ToLower(token.TokenHash)] = Principal{User: token.User, Role: role}
+	}
+	return principals, nil
+}
+
+func loadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return nil, err
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if jwk.Crv != "P-256" {
+				return nil, fmt.Errorf("unsupported curve %s for key %s", jwk.Crv, jwk.Kid)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err != nil {
+				return nil, err
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
+
+func NewAuthenticator(apiTokensPath, jwksPath, issuer, audience, rolesClaim string) (Authenticator, error) {
+	logger := utils.GetLogger()
+	svc := &AuthenticationSvc{
+		tokens:     map[string]Principal{},
+		keys:       map[string]crypto.PublicKey{},
+		issuer:     issuer,
+		audience:   audience,
+		rolesClaim: rolesClaim,
+		logger:     logger,
+	}
+	var err error
+	if apiTokensPath != "" {
+		if svc.tokens, err = loadAPITokens(apiTokensPath); err != nil {
+			return nil, err
+		}
+	}
+	if jwksPath != "" {
+		if svc.keys, err = loadJWKS(jwksPath); err != nil {
+			return nil, err
+		}
+	}
+	if !svc.IsEnabled() {
+		logger.Warn("No API tokens or JWKS were configured, the HTTP API is open to anyone")
+	}
+	return svc, nil
+}
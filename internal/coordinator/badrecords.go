@@ -0,0 +1,119 @@
+package coordinator
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+)
+
+// DeadLetterFilename is the object of the job output folder holding the records its map tasks skipped
+const DeadLetterFilename = "_dead-letter.json"
+
+// BadRecordsError is returned when a map task failed because its program crashed on some of its input records
+type BadRecordsError struct {
+	Offsets []int64
+	Reason  string
+}
+
+func (e *BadRecordsError) Error() string {
+	return e.Reason
+}
+
+// DeadLetterRecord is a record skipped by a map task as it is stored in the job dead-letter object
+type DeadLetterRecord struct {
+	TaskId  string `json:"taskId"`
+	Offset  int64  `json:"offset"`
+	Content string `json:"content"`
+}
+
+// taskFailure returns the error of a task its worker reported as failed
+func taskFailure(statusInfo *proto.TaskStatusInfo, reason string) error {
+	if len(statusInfo.GetBadRecords()) != 0 {
+		return &BadRecordsError{Offsets: statusInfo.GetBadRecords(), Reason: reason}
+	}
+	return errors.New(reason)
+}
+
+// RecordSkipper counts the program failures on the records of a map task, a record is skipped by the next attempts
+// of the task once the program failed on it skipAfter times
+type RecordSkipper struct {
+	skipAfter int64
+	retries   int64
+	failures  map[int64]int64
+	skipped   []*proto.SkippedRecord
+}
+
+// Retry counts the failures behind a failed attempt and tells whether the task should be attempted again, a task is
+// retried at most skipAfter times since its bad records are all skipped by then
+func (s *RecordSkipper) Retry(err error) bool {
+	var badRecords *BadRecordsError
+	if s == nil || !errors.As(err, &badRecords) || s.retries >= s.skipAfter {
+		return false
+	}
+	for _, offset := range badRecords.Offsets {
+		s.failures[offset]++
+	}
+	s.retries++
+	return true
+}
+
+// SkipList returns the offsets of the records the next attempt of the task skips
+func (s *RecordSkipper) SkipList() []int64 {
+	skipList := []int64{}
+	for offset, failures := range s.failures {
+		if failures >= s.skipAfter {
+			skipList = append(skipList, offset)
+		}
+	}
+	slices.Sort(skipList)
+	return skipList
+}
+
+// Skipped returns the records the completed task skipped
+func (s *RecordSkipper) Skipped() []*proto.SkippedRecord {
+	if s == nil {
+		return nil
+	}
+	return s.skipped
+}
+
+func (s *RecordSkipper) keep(skipped []*proto.SkippedRecord) {
+	if s != nil {
+		s.skipped = skipped
+	}
+}
+
+// NewRecordSkipper returns nil when skipAfter isn't set so that the failed tasks aren't retried to skip their records
+func NewRecordSkipper(skipAfter *int64) *RecordSkipper {
+	if skipAfter == nil || *skipAfter < 1 {
+		return nil
+	}
+	return &RecordSkipper{
+		skipAfter: *skipAfter,
+		failures:  map[int64]int64{},
+	}
+}
+
+// MarshalDeadLetter encodes the records skipped by the map tasks of a job, ordered by task and offset
+func MarshalDeadLetter(skipped map[string][]*proto.SkippedRecord) ([]byte, error) {
+	records := []DeadLetterRecord{}
+	for taskId, taskRecords := range skipped {
+		for _, record := range taskRecords {
+			records = append(records, DeadLetterRecord{
+				TaskId:  taskId,
+				Offset:  record.GetOffset(),
+				Content: record.GetContent(),
+			})
+		}
+	}
+	slices.SortFunc(records, func(a, b DeadLetterRecord) int {
+		if a.TaskId != b.TaskId {
+			return cmp.Compare(a.TaskId, b.TaskId)
+		}
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	return json.Marshal(records)
+}
@@ -0,0 +1,225 @@
+package coordinator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+const workerCertValidity = 7 * 24 * time.Hour
+
+// CertificateAuthority issues the certificates used for the mutual TLS between the coordinator and its workers
+type CertificateAuthority struct {
+	cert       *x509.Certificate
+	key        crypto.Signer
+	certPEM    []byte
+	clientCert tls.Certificate
+	config     *Config
+}
+
+func (ca *CertificateAuthority) GetCACertPEM() []byte {
+	return ca.certPEM
+}
+
+// IssueWorkerCertificate creates the server certificate presented by the worker pods of a job
+func (ca *CertificateAuthority) IssueWorkerCertificate(jobId, workerNS string) ([]byte, []byte, error) {
+	template := &x509.Certificate{
+		Subject: pkix.Name{CommonName: utils.WorkerCommonNamePrefix + jobId},
+		DNSNames: []string{
+			fmt.Sprintf("*.workers.%s.svc.cluster.local", workerNS),
+			fmt.Sprintf("*.workers.%s.svc", workerNS),
+			"localhost",
+		},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		// Pulling workers also use it to authenticate to the coordinator task dispatcher
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	certPEM, key, err := ca.sign(template, workerCertValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certPEM, keyPEM, nil
+}
+
+// ClientTLSConfig returns the configuration used by the coordinator to dial the workers
+func (ca *CertificateAuthority) ClientTLSConfig() *tls.Config {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{ca.clientCert},
+		RootCAs:      rootCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// DispatcherTLSConfig returns the configuration of the task dispatcher server, it only accepts the workers holding a
+// certificate issued for their job
+func (ca *CertificateAuthority) DispatcherTLSConfig() (*tls.Config, error) {
+	template := &x509.Certificate{
+		Subject: pkix.Name{CommonName: utils.CoordinatorCommonName},
+		DNSNames: []string{
+			fmt.Sprintf("coordinator.%s.svc.cluster.local", ca.config.GetCoordinatorNS()),
+			fmt.Sprintf("coordinator.%s.svc", ca.config.GetCoordinatorNS()),
+			"localhost",
+		},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if host, _, err := net.SplitHostPort(ca.config.GetCoordinatorAddress()); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	certPEM, key, err := ca.sign(template, ca.cert.NotAfter.Sub(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func (ca *CertificateAuthority) sign(template *x509.Certificate, validity time.Duration) ([]byte, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber = serialNumber
+	template.NotBefore = time.Now().Add(-5 * time.Minute)
+	template.NotAfter = time.Now().Add(validity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func loadCA(certPath, keyPath string) (*x509.Certificate, crypto.Signer, []byte, error) {
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("the CA private key can't be used to sign certificates")
+	}
+	certPEM, err := os.ReadFile(certPath)
+	return cert, key, certPEM, err
+}
+
+func generateCA() (*x509.Certificate, crypto.Signer, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "apollo-ca"},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// NewCertificateAuthority loads the CA from the configured certificate and key files or generates an ephemeral one
+func NewCertificateAuthority() (*CertificateAuthority, error) {
+	logger := utils.GetLogger()
+	config := GetConfig()
+	var cert *x509.Certificate
+	var key crypto.Signer
+	var certPEM []byte
+	var err error
+	if config.GetCACertPath() != "" && config.GetCAKeyPath() != "" {
+		logger.Info("Loading certificate authority from %s", config.GetCACertPath())
+		cert, key, certPEM, err = loadCA(config.GetCACertPath(), config.GetCAKeyPath())
+	} else {
+		logger.Warn("No certificate authority was configured, an ephemeral one will be generated")
+		cert, key, certPEM, err = generateCA()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ca := &CertificateAuthority{
+		cert:    cert,
+		key:     key,
+		certPEM: certPEM,
+		config:  config,
+	}
+
+	clientCertPEM, clientKey, err := ca.sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: utils.CoordinatorCommonName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, cert.NotAfter.Sub(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	clientKeyPEM, err := encodeKey(clientKey)
+	if err != nil {
+		return nil, err
+	}
+	ca.clientCert, err = tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	return ca, err
+}
@@ -1,13 +1,19 @@
 package coordinator
 
 import (
+	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/Assifar-Karim/apollo/internal/proto"
 	"github.com/Assifar-Karim/apollo/internal/utils"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var lock = &sync.Mutex{}
@@ -20,6 +26,32 @@ type Config struct {
 	workerNS             string
 	workerImg            string
 	intermediateFilesLoc string
+	connectionsKey       []byte
+	caCertPath           string
+	caKeyPath            string
+	apiTokensPath        string
+	jwksPath             string
+	jwtIssuer            string
+	jwtAudience          string
+	jwtRolesClaim        string
+	coordinatorNS        string
+	coordinatorSA        string
+	intFilesStorageClass string
+	intFilesStorageSize  string
+	maxRunningJobs       int
+	maxWorkerPods        int
+	workerPodTemplate    string
+	failedJobsRetention  time.Duration
+	workerSweepInterval  time.Duration
+	podReadyTimeout      time.Duration
+	indexedJobWorkers    bool
+	workerBackoffLimit   int32
+	pullAssignment       bool
+	coordinatorAddress   string
+	heartbeatInterval    time.Duration
+	lostTaskTimeout      time.Duration
+	taskAckTimeout       time.Duration
+	programLimits        *proto.ProgramLimits
 }
 
 var configInstance *Config
@@ -81,6 +113,201 @@ func GetConfig() *Config {
 		if intermediateFilesLoc[len(intermediateFilesLoc)-1] == '/' {
 			intermediateFilesLoc = intermediateFilesLoc[:len(intermediateFilesLoc)-1]
 		}
+		// Key used to encrypt the storage connection credentials at rest, it must be a base64 encoded 32 bytes key
+		var connectionsKey []byte
+		connectionsKeyStr, exists := os.LookupEnv("CONNECTIONS_KEY")
+		if exists {
+			key, err := base64.StdEncoding.DecodeString(connectionsKeyStr)
+			if err != nil || len(key) != 32 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read a 32 bytes key from CONNECTIONS_KEY environment variable, connections will only accept secret references")
+			} else {
+				connectionsKey = key
+			}
+		}
+		// Optional certificate authority used to secure the worker communications, usually mounted from a secret
+		caCertPath := os.Getenv("CA_CERT_PATH")
+		caKeyPath := os.Getenv("CA_KEY_PATH")
+		// HTTP API authentication through hashed API tokens and/or JWTs verified against a local JWKS file
+		apiTokensPath := os.Getenv("API_TOKENS_PATH")
+		jwksPath := os.Getenv("JWKS_PATH")
+		jwtIssuer := os.Getenv("JWT_ISSUER")
+		jwtAudience := os.Getenv("JWT_AUDIENCE")
+		jwtRolesClaim, exists := os.LookupEnv("JWT_ROLES_CLAIM")
+		if !exists {
+			jwtRolesClaim = "roles"
+		}
+		// Identity of the coordinator, it gets bound to the RBAC role provisioned in every project namespace
+		coordinatorNS, exists := os.LookupEnv("COORDINATOR_NS")
+		if !exists {
+			coordinatorNS = workerNS
+		}
+		coordinatorSA, exists := os.LookupEnv("COORDINATOR_SA")
+		if !exists {
+			coordinatorSA = "apollo-coordinator"
+		}
+		// Intermediate files volume claimed in every project namespace
+		intFilesStorageClass, exists := os.LookupEnv("INT_FILES_STORAGE_CLASS")
+		if !exists {
+			intFilesStorageClass = "local-path"
+		}
+		intFilesStorageSize, exists := os.LookupEnv("INT_FILES_STORAGE_SIZE")
+		if !exists {
+			intFilesStorageSize = "1Gi"
+		}
+		// Global cap on the jobs running at once, the remaining ones wait in the queue
+		maxRunningJobsStr, exists := os.LookupEnv("MAX_RUNNING_JOBS")
+		maxRunningJobs := 10
+		if exists {
+			conv, err := strconv.Atoi(maxRunningJobsStr)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the running jobs cap from MAX_RUNNING_JOBS environment variable, it will default to 10")
+			} else {
+				maxRunningJobs = conv
+			}
+		}
+		// Worker pods shared between the projects by the fair share scheduler
+		maxWorkerPodsStr, exists := os.LookupEnv("MAX_WORKER_PODS")
+		maxWorkerPods := 0
+		if exists {
+			conv, err := strconv.Atoi(maxWorkerPodsStr)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the worker pods capacity from MAX_WORKER_PODS environment variable, it will be unlimited")
+			} else {
+				maxWorkerPods = conv
+			}
+		}
+		// ConfigMap holding the pod template every worker pod starts from unless the job names another one
+		workerPodTemplate := os.Getenv("WORKER_POD_TEMPLATE")
+		// Failed jobs keep their worker pods around for this long so that their logs can be inspected
+		failedJobsRetention := time.Duration(0)
+		if retentionStr, exists := os.LookupEnv("FAILED_JOBS_RETENTION"); exists {
+			conv, err := time.ParseDuration(retentionStr)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the failed jobs retention from FAILED_JOBS_RETENTION environment variable, their workers will be deleted right away")
+			} else {
+				failedJobsRetention = conv
+			}
+		}
+		// Period of the sweeper deleting the worker pods and services left behind by finished or deleted jobs
+		workerSweepInterval := 5 * time.Minute
+		if intervalStr, exists := os.LookupEnv("WORKER_SWEEP_INTERVAL"); exists {
+			conv, err := time.ParseDuration(intervalStr)
+			if err != nil || conv <= 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the sweep interval from WORKER_SWEEP_INTERVAL environment variable, it will default to 5m")
+			} else {
+				workerSweepInterval = conv
+			}
+		}
+		// Worker pods that aren't ready after this long fail their task
+		podReadyTimeout := 10 * time.Minute
+		if timeoutStr, exists := os.LookupEnv("POD_READY_TIMEOUT"); exists {
+			conv, err := time.ParseDuration(timeoutStr)
+			if err != nil || conv <= 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the pod readiness timeout from POD_READY_TIMEOUT environment variable, it will default to 10m")
+			} else {
+				podReadyTimeout = conv
+			}
+		}
+		// Workers can run as one Indexed Job per phase instead of bare pods
+		indexedJobWorkers := os.Getenv("INDEXED_JOB_WORKERS") == "true"
+		workerBackoffLimit := int32(3)
+		if backoffLimitStr, exists := os.LookupEnv("WORKER_BACKOFF_LIMIT"); exists {
+			conv, err := strconv.ParseInt(backoffLimitStr, 10, 32)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the worker backoff limit from WORKER_BACKOFF_LIMIT environment variable, it will default to 3")
+			} else {
+				workerBackoffLimit = int32(conv)
+			}
+		}
+		// Workers either get their task pushed by the coordinator or pull it from the coordinator task dispatcher
+		pullAssignment := os.Getenv("TASK_ASSIGNMENT") == "pull"
+		coordinatorAddress, exists := os.LookupEnv("COORDINATOR_ADDRESS")
+		if !exists {
+			coordinatorAddress = fmt.Sprintf("coordinator.%s.svc.cluster.local:8091", coordinatorNS)
+		}
+		// Workers send a heartbeat every interval while they run a task, a task whose worker stays silent for longer
+		// than the lost task timeout fails
+		heartbeatInterval := 10 * time.Second
+		if intervalStr, exists := os.LookupEnv("TASK_HEARTBEAT_INTERVAL"); exists {
+			conv, err := time.ParseDuration(intervalStr)
+			if err != nil || conv <= 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the task heartbeat interval from TASK_HEARTBEAT_INTERVAL environment variable, it will default to 10s")
+			} else {
+				heartbeatInterval = conv
+			}
+		}
+		lostTaskTimeout := 6 * heartbeatInterval
+		if timeoutStr, exists := os.LookupEnv("LOST_TASK_TIMEOUT"); exists {
+			conv, err := time.ParseDuration(timeoutStr)
+			if err != nil || conv <= heartbeatInterval {
+				logger := utils.GetLogger()
+				logger.Warn("can't read a lost task timeout longer than the heartbeat interval from LOST_TASK_TIMEOUT environment variable, it will default to %v", lostTaskTimeout)
+			} else {
+				lostTaskTimeout = conv
+			}
+		}
+		// A worker that pulled a task has to acknowledge it with its first status report before this timeout, the task
+		// is handed to another poll otherwise since the response carrying it may never have reached the worker
+		taskAckTimeout := 30 * time.Second
+		if timeoutStr, exists := os.LookupEnv("TASK_ACK_TIMEOUT"); exists {
+			conv, err := time.ParseDuration(timeoutStr)
+			if err != nil || conv <= 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the task acknowledgement timeout from TASK_ACK_TIMEOUT environment variable, it will default to 30s")
+			} else {
+				taskAckTimeout = conv
+			}
+		}
+		// Every program invocation is bounded by these limits, 0 leaves a limit unset but the timeout which is what fails
+		// a program that hangs while its worker keeps sending heartbeats
+		programTimeout := worker.DefaultProgramTimeout
+		if timeoutStr, exists := os.LookupEnv("PROGRAM_TIMEOUT"); exists {
+			conv, err := time.ParseDuration(timeoutStr)
+			if err != nil || conv <= 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read a positive program timeout from PROGRAM_TIMEOUT environment variable, it will default to %v", programTimeout)
+			} else {
+				programTimeout = conv
+			}
+		}
+		var programMemory int64
+		if memoryStr, exists := os.LookupEnv("PROGRAM_MEMORY_LIMIT"); exists {
+			conv, err := resource.ParseQuantity(memoryStr)
+			if err != nil || conv.Sign() < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the program memory limit from PROGRAM_MEMORY_LIMIT environment variable, the memory of the programs won't be limited")
+			} else {
+				programMemory = conv.Value()
+			}
+		}
+		var programCPUTime time.Duration
+		if cpuStr, exists := os.LookupEnv("PROGRAM_CPU_TIME_LIMIT"); exists {
+			conv, err := time.ParseDuration(cpuStr)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the program CPU time limit from PROGRAM_CPU_TIME_LIMIT environment variable, the CPU time of the programs won't be limited")
+			} else {
+				programCPUTime = conv
+			}
+		}
+		programOpenFiles := int64(1024)
+		if openFilesStr, exists := os.LookupEnv("PROGRAM_OPEN_FILES_LIMIT"); exists {
+			conv, err := strconv.ParseInt(openFilesStr, 10, 64)
+			if err != nil || conv < 0 {
+				logger := utils.GetLogger()
+				logger.Warn("can't read the program open files limit from PROGRAM_OPEN_FILES_LIMIT environment variable, it will default to 1024")
+			} else {
+				programOpenFiles = conv
+			}
+		}
 		configInstance = &Config{
 			devMode:              devMode,
 			artifactsPath:        artifactsPath,
@@ -89,6 +316,38 @@ func GetConfig() *Config {
 			workerNS:             workerNS,
 			workerImg:            workerImg,
 			intermediateFilesLoc: intermediateFilesLoc,
+			connectionsKey:       connectionsKey,
+			caCertPath:           caCertPath,
+			caKeyPath:            caKeyPath,
+			apiTokensPath:        apiTokensPath,
+			jwksPath:             jwksPath,
+			jwtIssuer:            jwtIssuer,
+			jwtAudience:          jwtAudience,
+			jwtRolesClaim:        jwtRolesClaim,
+			coordinatorNS:        coordinatorNS,
+			coordinatorSA:        coordinatorSA,
+			intFilesStorageClass: intFilesStorageClass,
+			intFilesStorageSize:  intFilesStorageSize,
+			maxRunningJobs:       maxRunningJobs,
+			maxWorkerPods:        maxWorkerPods,
+			workerPodTemplate:    workerPodTemplate,
+			failedJobsRetention:  failedJobsRetention,
+			workerSweepInterval:  workerSweepInterval,
+			podReadyTimeout:      podReadyTimeout,
+			indexedJobWorkers:    indexedJobWorkers,
+			workerBackoffLimit:   workerBackoffLimit,
+			pullAssignment:       pullAssignment,
+			coordinatorAddress:   coordinatorAddress,
+			heartbeatInterval:    heartbeatInterval,
+			lostTaskTimeout:      lostTaskTimeout,
+			taskAckTimeout:       taskAckTimeout,
+			programLimits: &proto.ProgramLimits{
+				TimeoutMs:   programTimeout.Milliseconds(),
+				MemoryBytes: programMemory,
+				// The CPU time limit is rounded up since it is counted in whole seconds
+				CpuSeconds: int64((programCPUTime + time.Second - 1) / time.Second),
+				OpenFiles:  programOpenFiles,
+			},
 		}
 
 	}
@@ -122,3 +381,119 @@ func (c *Config) GetWorkerImg() string {
 func (c *Config) GetIntermediateFilesLoc() string {
 	return c.intermediateFilesLoc
 }
+
+func (c *Config) GetConnectionsKey() []byte {
+	return c.connectionsKey
+}
+
+func (c *Config) GetCACertPath() string {
+	return c.caCertPath
+}
+
+func (c *Config) GetCAKeyPath() string {
+	return c.caKeyPath
+}
+
+func (c *Config) GetAPITokensPath() string {
+	return c.apiTokensPath
+}
+
+func (c *Config) GetJWKSPath() string {
+	return c.jwksPath
+}
+
+func (c *Config) GetJWTIssuer() string {
+	return c.jwtIssuer
+}
+
+func (c *Config) GetJWTAudience() string {
+	return c.jwtAudience
+}
+
+func (c *Config) GetJWTRolesClaim() string {
+	return c.jwtRolesClaim
+}
+
+func (c *Config) GetCoordinatorNS() string {
+	return c.coordinatorNS
+}
+
+func (c *Config) GetCoordinatorSA() string {
+	return c.coordinatorSA
+}
+
+func (c *Config) GetIntFilesStorageClass() string {
+	return c.intFilesStorageClass
+}
+
+func (c *Config) GetIntFilesStorageSize() string {
+	return c.intFilesStorageSize
+}
+
+// GetMaxRunningJobs returns the global running jobs cap, 0 means that it is disabled
+func (c *Config) GetMaxRunningJobs() int {
+	return c.maxRunningJobs
+}
+
+// GetMaxWorkerPods returns the worker pods capacity shared between the projects, 0 means that it is unlimited
+func (c *Config) GetMaxWorkerPods() int {
+	return c.maxWorkerPods
+}
+
+// GetWorkerPodTemplate returns the name of the default worker pod template ConfigMap, an empty name means that the
+// worker pods are built from scratch
+func (c *Config) GetWorkerPodTemplate() string {
+	return c.workerPodTemplate
+}
+
+// GetFailedJobsRetention returns how long the workers of a failed job are kept, 0 means that they are deleted as soon
+// as the job fails
+func (c *Config) GetFailedJobsRetention() time.Duration {
+	return c.failedJobsRetention
+}
+
+func (c *Config) GetWorkerSweepInterval() time.Duration {
+	return c.workerSweepInterval
+}
+
+func (c *Config) GetPodReadyTimeout() time.Duration {
+	return c.podReadyTimeout
+}
+
+func (c *Config) UseIndexedJobWorkers() bool {
+	return c.indexedJobWorkers
+}
+
+// GetWorkerBackoffLimit returns how many times the pods of an Indexed Job phase are retried before the phase fails
+func (c *Config) GetWorkerBackoffLimit() int32 {
+	return c.workerBackoffLimit
+}
+
+// IsPullAssignment tells whether the workers pull their tasks from the coordinator instead of being dialed by it
+func (c *Config) IsPullAssignment() bool {
+	return c.pullAssignment
+}
+
+// GetCoordinatorAddress returns the address the workers use to reach the coordinator task dispatcher
+func (c *Config) GetCoordinatorAddress() string {
+	return c.coordinatorAddress
+}
+
+func (c *Config) GetHeartbeatInterval() time.Duration {
+	return c.heartbeatInterval
+}
+
+// GetLostTaskTimeout returns how long a worker can stay silent before its task is considered lost
+func (c *Config) GetLostTaskTimeout() time.Duration {
+	return c.lostTaskTimeout
+}
+
+// GetTaskAckTimeout returns how long a worker has to acknowledge the task it pulled before it gets handed out again
+func (c *Config) GetTaskAckTimeout() time.Duration {
+	return c.taskAckTimeout
+}
+
+// GetProgramLimits returns the limits applied to every map and reduce program invocation
+func (c *Config) GetProgramLimits() *proto.ProgramLimits {
+	return c.programLimits
+}
@@ -0,0 +1,132 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type ConnectionManager interface {
+	PersistConnection(connection db.Connection, creds *coreio.Credentials) (db.Connection, error)
+	GetAllConnections() ([]db.Connection, error)
+	GetConnectionByName(name string) (*db.Connection, error)
+	DeleteConnection(name string) (bool, error)
+	ResolveCredentials(connection db.Connection) (coreio.Credentials, error)
+}
+
+type ConnectionMngmtSvc struct {
+	connectionRepository db.ConnectionRepository
+	k8sClient            kubernetes.Interface
+	config               *Config
+	logger               *utils.Logger
+}
+
+// storedCredentials holds the secret part of a connection, it is the only part that gets encrypted
+type storedCredentials struct {
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	SessionToken string `json:"sessionToken,omitempty"`
+}
+
+func (s ConnectionMngmtSvc) PersistConnection(connection db.Connection, creds *coreio.Credentials) (db.Connection, error) {
+	if (creds == nil) == (connection.SecretRef == nil) {
+		return db.Connection{}, fmt.Errorf("a connection needs either inline credentials or a secret reference")
+	}
+	connection.Credentials = nil
+	if creds != nil {
+		key := s.config.GetConnectionsKey()
+		if key == nil {
+			return db.Connection{}, fmt.Errorf("inline credentials can't be stored without an encryption key, use a secret reference instead")
+		}
+		plaintext, err := json.Marshal(storedCredentials{
+			Username:     creds.Username,
+			Password:     creds.Password,
+			SessionToken: creds.SessionToken,
+		})
+		if err != nil {
+			s.logger.Error(err.Error())
+			return db.Connection{}, err
+		}
+		connection.Credentials, err = utils.Encrypt(key, plaintext)
+		if err != nil {
+			s.logger.Error(err.Error())
+			return db.Connection{}, err
+		}
+	}
+
+	existing, err := s.connectionRepository.FetchConnectionByName(connection.Name)
+	if err != nil {
+		return db.Connection{}, err
+	}
+	if existing == nil {
+		return s.connectionRepository.CreateConnection(connection)
+	}
+	return s.connectionRepository.UpdateConnection(connection)
+}
+
+func (s ConnectionMngmtSvc) GetAllConnections() ([]db.Connection, error) {
+	return s.connectionRepository.FetchConnections()
+}
+
+func (s ConnectionMngmtSvc) GetConnectionByName(name string) (*db.Connection, error) {
+	return s.connectionRepository.FetchConnectionByName(name)
+}
+
+func (s ConnectionMngmtSvc) DeleteConnection(name string) (bool, error) {
+	return s.connectionRepository.DeleteConnection(name)
+}
+
+func (s ConnectionMngmtSvc) ResolveCredentials(connection db.Connection) (coreio.Credentials, error) {
+	var stored storedCredentials
+	if connection.SecretRef != nil {
+		secret, err := s.k8sClient.CoreV1().Secrets(s.config.GetWorkerNS()).Get(
+			context.Background(),
+			*connection.SecretRef,
+			metav1.GetOptions{})
+		if err != nil {
+			s.logger.Error("Could not read secret %s of connection %s -> %v", *connection.SecretRef, connection.Name, err)
+			return coreio.Credentials{}, err
+		}
+		stored = storedCredentials{
+			Username:     string(secret.Data["username"]),
+			Password:     string(secret.Data["password"]),
+			SessionToken: string(secret.Data["sessionToken"]),
+		}
+	} else {
+		key := s.config.GetConnectionsKey()
+		if key == nil {
+			return coreio.Credentials{}, fmt.Errorf("connection %s credentials can't be decrypted without an encryption key", connection.Name)
+		}
+		plaintext, err := utils.Decrypt(key, connection.Credentials)
+		if err != nil {
+			s.logger.Error("Could not decrypt connection %s credentials -> %v", connection.Name, err)
+			return coreio.Credentials{}, err
+		}
+		if err := json.Unmarshal(plaintext, &stored); err != nil {
+			return coreio.Credentials{}, err
+		}
+	}
+	return coreio.Credentials{
+		Username:       stored.Username,
+		Password:       stored.Password,
+		SessionToken:   stored.SessionToken,
+		Region:         connection.Region,
+		ForcePathStyle: connection.ForcePathStyle,
+		CABundle:       connection.CABundle,
+	}, nil
+}
+
+func NewConnectionManager(connectionRepository db.ConnectionRepository, k8sClient kubernetes.Interface) ConnectionManager {
+	return &ConnectionMngmtSvc{
+		connectionRepository: connectionRepository,
+		k8sClient:            k8sClient,
+		config:               GetConfig(),
+		logger:               utils.GetLogger(),
+	}
+}
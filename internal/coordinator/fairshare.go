@@ -0,0 +1,145 @@
+package coordinator
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+var ErrShareCancelled = errors.New("worker pods request was cancelled")
+
+type FairShareScheduler interface {
+	// Acquire blocks until nPods worker pods can be created for the job
+	Acquire(project db.Project, jobId string, nPods int) error
+	// Release gives back all the worker pods held by the job
+	Release(jobId string)
+	// Cancel releases the job pods and aborts its pending requests
+	Cancel(jobId string)
+}
+
+type shareRequest struct {
+	jobId   string
+	project string
+	weight  int
+	nPods   int
+	arrival uint64
+	granted chan error
+}
+
+type jobShare struct {
+	project string
+	nPods   int
+}
+
+// FairShareSvc splits the worker pods capacity between the projects proportionally to their weights. Whenever
+// the capacity is contended, the pending request of the project with the lowest usage to weight ratio is served
+// first and the others wait behind it so that large requests don't get starved by smaller ones.
+type FairShareSvc struct {
+	capacity int
+	used     int
+	usage    map[string]int
+	shares   map[string]jobShare
+	waiting  []*shareRequest
+	arrivals uint64
+	lock     sync.Mutex
+	logger   *utils.Logger
+}
+
+func (s *FairShareSvc) Acquire(project db.Project, jobId string, nPods int) error {
+	weight := max(project.Weight, 1)
+	request := &shareRequest{
+		jobId:   jobId,
+		project: project.Name,
+		weight:  weight,
+		nPods:   nPods,
+		granted: make(chan error, 1),
+	}
+	s.lock.Lock()
+	s.arrivals++
+	request.arrival = s.arrivals
+	s.waiting = append(s.waiting, request)
+	s.grant()
+	s.lock.Unlock()
+
+	err := <-request.granted
+	if err == nil {
+		s.logger.Info("Job %s of project %s was granted %v worker pods", jobId, project.Name, nPods)
+	}
+	return err
+}
+
+func (s *FairShareSvc) Release(jobId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.release(jobId)
+	s.grant()
+}
+
+func (s *FairShareSvc) Cancel(jobId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pending := s.waiting[:0]
+	for _, request := range s.waiting {
+		if request.jobId == jobId {
+			request.granted <- ErrShareCancelled
+		} else {
+			pending = append(pending, request)
+		}
+	}
+	s.waiting = pending
+	s.release(jobId)
+	s.grant()
+}
+
+func (s *FairShareSvc) release(jobId string) {
+	share, exists := s.shares[jobId]
+	if !exists {
+		return
+	}
+	s.used -= share.nPods
+	s.usage[share.project] -= share.nPods
+	if s.usage[share.project] == 0 {
+		delete(s.usage, share.project)
+	}
+	delete(s.shares, jobId)
+}
+
+// grant serves the pending requests in fair order for as long as the capacity allows it, it must be called with
+// the lock held
+func (s *FairShareSvc) grant() {
+	sort.SliceStable(s.waiting, func(i, j int) bool {
+		a, b := s.waiting[i], s.waiting[j]
+		// Compare usage(a)/weight(a) with usage(b)/weight(b) without leaving the integers
+		aRatio, bRatio := s.usage[a.project]*b.weight, s.usage[b.project]*a.weight
+		if aRatio != bRatio {
+			return aRatio < bRatio
+		}
+		return a.arrival < b.arrival
+	})
+	for len(s.waiting) > 0 {
+		request := s.waiting[0]
+		// A request larger than the whole capacity still runs once the cluster is free
+		if s.capacity > 0 && s.used+request.nPods > s.capacity && s.used > 0 {
+			return
+		}
+		s.waiting = s.waiting[1:]
+		s.used += request.nPods
+		s.usage[request.project] += request.nPods
+		share := s.shares[request.jobId]
+		s.shares[request.jobId] = jobShare{project: request.project, nPods: share.nPods + request.nPods}
+		request.granted <- nil
+	}
+}
+
+// NewFairShareScheduler creates a scheduler sharing capacity worker pods, a capacity of 0 grants every request
+func NewFairShareScheduler(capacity int) FairShareScheduler {
+	return &FairShareSvc{
+		capacity: capacity,
+		usage:    map[string]int{},
+		shares:   map[string]jobShare{},
+		logger:   utils.GetLogger(),
+	}
+}
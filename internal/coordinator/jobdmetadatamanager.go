@@ -10,11 +10,13 @@ import (
 )
 
 type JobMetadataManager interface {
-	PersistJob(nReducers int, inputPath, inputType, outputPath string, useSSL bool) (db.Job, error)
+	PersistJob(nReducers int, inputPath, inputType, outputPath, owner, project string, useSSL bool) (db.Job, error)
 	GetAllJobs() ([]db.Job, error)
 	GetJobById(id string) (*db.Job, error)
 	GetTasksByJobID(id string) ([]db.Task, error)
+	GetTaskLogs(jobId, taskId string) ([]db.InvocationLog, bool, error)
 	SetJobEndTimestamp(id string) error
+	SetJobStatus(id, status string) (bool, error)
 	SetJobTasksAsStopped(id string) error
 }
 
@@ -24,7 +26,7 @@ type JobMetadataMngmtSvc struct {
 	logger         *utils.Logger
 }
 
-func (s JobMetadataMngmtSvc) PersistJob(nReducers int, inputPath, inputType, outputPath string, useSSL bool) (db.Job, error) {
+func (s JobMetadataMngmtSvc) PersistJob(nReducers int, inputPath, inputType, outputPath, owner, project string, useSSL bool) (db.Job, error) {
 	uuid, err := uuid.NewV7()
 	if err != nil {
 		s.logger.Error(err.Error())
@@ -32,7 +34,7 @@ func (s JobMetadataMngmtSvc) PersistJob(nReducers int, inputPath, inputType, out
 	}
 	id := fmt.Sprintf("j-%s", uuid.String())
 	startTime := time.Now().Unix()
-	return s.jobRepository.CreateJob(nReducers, startTime, id, inputPath, inputType, outputPath, useSSL)
+	return s.jobRepository.CreateJob(nReducers, startTime, id, inputPath, inputType, outputPath, owner, project, useSSL)
 }
 
 func (s JobMetadataMngmtSvc) GetAllJobs() ([]db.Job, error) {
@@ -40,17 +42,40 @@ func (s JobMetadataMngmtSvc) GetAllJobs() ([]db.Job, error) {
 }
 
 func (s JobMetadataMngmtSvc) GetJobById(id string) (*db.Job, error) {
-	return s.jobRepository.FetchJobByID(id)
+	job, err := s.jobRepository.FetchJobByID(id)
+	if err != nil || job == nil {
+		return job, err
+	}
+	tasks, err := s.taskRepository.FetchTasksByJobID(id)
+	if err != nil {
+		return nil, err
+	}
+	completion := ComputeJobCompletion(*job, tasks)
+	job.Completion = &completion
+	// The totals are only persisted once the job is over, they are computed on the fly until then
+	if job.Counters == nil {
+		job.Counters = AggregateCounters(tasks)
+	}
+	return job, nil
 }
 
 func (s JobMetadataMngmtSvc) GetTasksByJobID(id string) ([]db.Task, error) {
 	return s.taskRepository.FetchTasksByJobID(id)
 }
 
+// GetTaskLogs returns the output of the failed program invocations of a task and whether the job has such a task
+func (s JobMetadataMngmtSvc) GetTaskLogs(jobId, taskId string) ([]db.InvocationLog, bool, error) {
+	return s.taskRepository.FetchTaskLogsByID(jobId, taskId)
+}
+
 func (s JobMetadataMngmtSvc) SetJobEndTimestamp(id string) error {
 	return s.jobRepository.UpdateJobEndTimeByID(id, time.Now().Unix())
 }
 
+func (s JobMetadataMngmtSvc) SetJobStatus(id, status string) (bool, error) {
+	return s.jobRepository.UpdateJobStatusByID(id, status)
+}
+
 func (s JobMetadataMngmtSvc) SetJobTasksAsStopped(id string) error {
 	return s.taskRepository.UpdateUnfinishedTasksStatusByJobID("stopped", id)
 }
@@ -0,0 +1,367 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+const DispatchInterval = 10 * time.Second
+
+var priorityLevels = map[string]int{
+	"low":    0,
+	"normal": 1,
+	"high":   2,
+}
+
+// ParsePriority converts a priority level name to its rank, jobs default to the normal priority
+func ParsePriority(name string) (int, error) {
+	if name == "" {
+		return priorityLevels["normal"], nil
+	}
+	priority, exists := priorityLevels[name]
+	if !exists {
+		return 0, fmt.Errorf("%s isn't a supported priority level", name)
+	}
+	return priority, nil
+}
+
+type JobRequest struct {
+	Priority    int
+	MapperName  string
+	ReducerName string
+	Credentials []coreio.Credentials
+	Options     JobOptions
+}
+
+type JobQueue interface {
+	Enqueue(job db.Job, request JobRequest) error
+	Remove(jobId string) (bool, error)
+	Start()
+}
+
+// JobQueueSvc starts the queued jobs whenever both the global running jobs cap and the quotas of their project
+// allow it
+type JobQueueSvc struct {
+	queueRepository    db.QueueRepository
+	jobRepository      db.JobRepository
+	taskRepository     db.TaskRepository
+	artifactRepository db.ArtifactRepository
+	projectManager     ProjectManager
+	jobScheduler       JobScheduler
+	workerCollector    WorkerCollector
+	workerLogs         WorkerLogs
+	config             *Config
+	logger             *utils.Logger
+	// Credentials of the queued jobs that can't be persisted because no connections key was configured
+	pendingCreds map[string][]coreio.Credentials
+	credsLock    sync.Mutex
+	dispatchLock sync.Mutex
+	wakeup       chan struct{}
+}
+
+func (s *JobQueueSvc) Enqueue(job db.Job, request JobRequest) error {
+	entry := db.QueueEntry{
+		JobId:               job.Id,
+		Priority:            request.Priority,
+		EnqueueTime:         time.Now().UnixMilli(),
+		MapperName:          request.MapperName,
+		ReducerName:         request.ReducerName,
+		SplitSize:           request.Options.SplitSize,
+		PrefetchParallelism: request.Options.PrefetchParallelism,
+		SkipBadRecordsAfter: request.Options.SkipBadRecordsAfter,
+		ProgramConcurrency:  request.Options.ProgramConcurrency,
+		ArchiveLogs:         request.Options.ArchiveLogs,
+		PodTemplate:         request.Options.PodTemplate,
+	}
+	podSettings, err := json.Marshal(request.Options.PodSettings)
+	if err != nil {
+		return err
+	}
+	entry.PodSettings = podSettings
+	if key := s.config.GetConnectionsKey(); key != nil {
+		plaintext, err := json.Marshal(request.Credentials)
+		if err != nil {
+			return err
+		}
+		if entry.Credentials, err = utils.Encrypt(key, plaintext); err != nil {
+			return err
+		}
+	} else {
+		s.credsLock.Lock()
+		s.pendingCreds[job.Id] = request.Credentials
+		s.credsLock.Unlock()
+	}
+	if _, err := s.queueRepository.CreateQueueEntry(entry); err != nil {
+		s.takePendingCredentials(job.Id)
+		s.finishJob(job.Id, db.JobFailed)
+		return err
+	}
+	s.logger.Info("Job %s was queued with priority %v", job.Id, request.Priority)
+	s.notify()
+	return nil
+}
+
+func (s *JobQueueSvc) Remove(jobId string) (bool, error) {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+	s.takePendingCredentials(jobId)
+	return s.queueRepository.DeleteQueueEntry(jobId)
+}
+
+// Start fails the jobs that were interrupted by a coordinator restart and begins dispatching the queued ones
+func (s *JobQueueSvc) Start() {
+	interruptedJobs, err := s.jobRepository.FetchJobIDsByStatus(db.JobRunning)
+	if err != nil {
+		s.logger.Error("Could not fetch the jobs interrupted by the last shutdown -> %v", err)
+	}
+	for _, id := range interruptedJobs {
+		s.logger.Warn("Job %s was interrupted by a coordinator restart", id)
+		s.finishJob(id, db.JobFailed)
+		s.collectInterruptedJob(id)
+	}
+	go func() {
+		ticker := time.NewTicker(DispatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.wakeup:
+			case <-ticker.C:
+			}
+			s.dispatch()
+		}
+	}()
+	s.notify()
+}
+
+func (s *JobQueueSvc) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *JobQueueSvc) dispatch() {
+	s.dispatchLock.Lock()
+	defer s.dispatchLock.Unlock()
+	running, err := s.jobRepository.CountJobsByStatus(db.JobRunning)
+	if err != nil {
+		return
+	}
+	entries, err := s.queueRepository.FetchQueueEntries()
+	if err != nil {
+		return
+	}
+	maxRunningJobs := s.config.GetMaxRunningJobs()
+	for _, entry := range entries {
+		if maxRunningJobs > 0 && running >= maxRunningJobs {
+			return
+		}
+		job, err := s.jobRepository.FetchJobByID(entry.JobId)
+		if err != nil {
+			return
+		}
+		if job == nil {
+			s.queueRepository.DeleteQueueEntry(entry.JobId)
+			continue
+		}
+		project, err := s.projectManager.GetProjectByName(job.Project)
+		if err != nil {
+			return
+		}
+		if project == nil {
+			s.failQueuedJob(entry.JobId, fmt.Errorf("project %s can't be found", job.Project))
+			continue
+		}
+		// A job that doesn't fit in its project quota stays queued without blocking the other projects, its mappers are
+		// checked against the pod quota by the scheduler once its input is split
+		if err := s.projectManager.CheckJobStartQuota(*project, job.NReducers); errors.Is(err, ErrQuotaExceeded) {
+			s.logger.Trace("Job %s stays queued -> %v", job.Id, err)
+			continue
+		} else if err != nil {
+			return
+		}
+		creds, err := s.getCredentials(entry)
+		if err != nil {
+			s.failQueuedJob(entry.JobId, err)
+			continue
+		}
+		artifacts := make([]db.Artifact, 2)
+		for idx, name := range []string{entry.MapperName, entry.ReducerName} {
+			artifact, fetchErr := s.artifactRepository.FetchArficatByName(name)
+			if fetchErr == nil && artifact == nil {
+				fetchErr = fmt.Errorf("%s artifact can't be found", name)
+			}
+			if fetchErr != nil {
+				err = fetchErr
+				break
+			}
+			artifacts[idx] = *artifact
+		}
+		if err != nil {
+			s.failQueuedJob(entry.JobId, err)
+			continue
+		}
+		podSettings := PodSettings{}
+		if len(entry.PodSettings) != 0 {
+			if err := json.Unmarshal(entry.PodSettings, &podSettings); err != nil {
+				s.failQueuedJob(entry.JobId, err)
+				continue
+			}
+		}
+
+		if _, err := s.queueRepository.DeleteQueueEntry(entry.JobId); err != nil {
+			return
+		}
+		started, err := s.jobRepository.UpdateJobStatusByID(job.Id, db.JobRunning)
+		if err != nil {
+			s.logger.Error("Could not start job %s -> %v", job.Id, err)
+			s.finishJob(job.Id, db.JobFailed)
+			continue
+		}
+		if !started {
+			// The job was stopped while it was still queued
+			continue
+		}
+		running++
+		opts := JobOptions{
+			SplitSize:           entry.SplitSize,
+			PrefetchParallelism: entry.PrefetchParallelism,
+			SkipBadRecordsAfter: entry.SkipBadRecordsAfter,
+			ProgramConcurrency:  entry.ProgramConcurrency,
+			ArchiveLogs:         entry.ArchiveLogs,
+			PodSettings:         podSettings,
+			PodTemplate:         entry.PodTemplate,
+		}
+		s.logger.Info("Starting queued job %s", job.Id)
+		go s.run(*job, artifacts, creds, opts)
+	}
+}
+
+func (s *JobQueueSvc) run(job db.Job, artifacts []db.Artifact, creds []coreio.Credentials, opts JobOptions) {
+	status := db.JobCompleted
+	if _, err := s.jobScheduler.ScheduleJob(job, artifacts, creds, opts); err != nil {
+		s.logger.Error("Job %s failed -> %v", job.Id, err)
+		status = db.JobFailed
+	}
+	s.finishJob(job.Id, status)
+	s.persistCounters(job.Id)
+	// The logs are archived before the workers holding them get collected
+	if opts.ArchiveLogs {
+		if err := s.workerLogs.ArchiveJobLogs(job, creds[1]); err != nil {
+			s.logger.Error("Could not archive job %s worker logs -> %v", job.Id, err)
+		}
+	}
+	s.workerCollector.CollectJob(job, status)
+	s.notify()
+}
+
+// collectInterruptedJob releases what a job interrupted by a coordinator restart still holds since its run never
+// ended, its worker pods are collected as the ones of any failed job
+func (s *JobQueueSvc) collectInterruptedJob(jobId string) {
+	s.takePendingCredentials(jobId)
+	job, err := s.jobRepository.FetchJobByID(jobId)
+	if err != nil || job == nil {
+		s.logger.Error("Could not fetch interrupted job %s to collect it -> %v", jobId, err)
+		return
+	}
+	s.persistCounters(jobId)
+	if err := s.jobScheduler.ReleaseJob(*job); err != nil {
+		s.logger.Error("Could not release interrupted job %s -> %v", jobId, err)
+	}
+	s.workerCollector.CollectJob(*job, db.JobFailed)
+}
+
+func (s *JobQueueSvc) getCredentials(entry db.QueueEntry) ([]coreio.Credentials, error) {
+	if creds, exists := s.takePendingCredentials(entry.JobId); exists {
+		return creds, nil
+	}
+	if entry.Credentials == nil {
+		return nil, fmt.Errorf("job %s storage credentials were lost by a coordinator restart", entry.JobId)
+	}
+	key := s.config.GetConnectionsKey()
+	if key == nil {
+		return nil, fmt.Errorf("job %s storage credentials can't be decrypted without an encryption key", entry.JobId)
+	}
+	plaintext, err := utils.Decrypt(key, entry.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	var creds []coreio.Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func (s *JobQueueSvc) takePendingCredentials(jobId string) ([]coreio.Credentials, bool) {
+	s.credsLock.Lock()
+	defer s.credsLock.Unlock()
+	creds, exists := s.pendingCreds[jobId]
+	delete(s.pendingCreds, jobId)
+	return creds, exists
+}
+
+func (s *JobQueueSvc) failQueuedJob(jobId string, err error) {
+	s.logger.Error("Queued job %s can't be started -> %v", jobId, err)
+	s.queueRepository.DeleteQueueEntry(jobId)
+	s.takePendingCredentials(jobId)
+	s.finishJob(jobId, db.JobFailed)
+}
+
+// finishJob leaves untouched the jobs that were already ended, for instance by a stop request
+func (s *JobQueueSvc) finishJob(jobId, status string) {
+	updated, err := s.jobRepository.UpdateJobStatusByID(jobId, status)
+	if err != nil {
+		s.logger.Error("Could not set job %s status to %s -> %v", jobId, status, err)
+	}
+	if !updated {
+		return
+	}
+	if err := s.jobRepository.UpdateJobEndTimeByID(jobId, time.Now().Unix()); err != nil {
+		s.logger.Error("Could not set job %s end time -> %v", jobId, err)
+	}
+}
+
+// persistCounters stores the job-wide totals of the counters reported by the tasks of a job that ended
+func (s *JobQueueSvc) persistCounters(jobId string) {
+	tasks, err := s.taskRepository.FetchTasksByJobID(jobId)
+	if err != nil {
+		s.logger.Error("Could not fetch job %s tasks to total their counters -> %v", jobId, err)
+		return
+	}
+	if err := s.jobRepository.UpdateJobCountersByID(jobId, AggregateCounters(tasks)); err != nil {
+		s.logger.Error("Could not store job %s counters -> %v", jobId, err)
+	}
+}
+
+func NewJobQueue(
+	queueRepository db.QueueRepository,
+	jobRepository db.JobRepository,
+	taskRepository db.TaskRepository,
+	artifactRepository db.ArtifactRepository,
+	projectManager ProjectManager,
+	jobScheduler JobScheduler,
+	workerCollector WorkerCollector,
+	workerLogs WorkerLogs) JobQueue {
+	return &JobQueueSvc{
+		queueRepository:    queueRepository,
+		jobRepository:      jobRepository,
+		taskRepository:     taskRepository,
+		artifactRepository: artifactRepository,
+		projectManager:     projectManager,
+		jobScheduler:       jobScheduler,
+		workerCollector:    workerCollector,
+		workerLogs:         workerLogs,
+		config:             GetConfig(),
+		logger:             utils.GetLogger(),
+		pendingCreds:       map[string][]coreio.Credentials{},
+		wakeup:             make(chan struct{}, 1),
+	}
+}
@@ -2,6 +2,7 @@ package coordinator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
@@ -12,51 +13,109 @@ import (
 	coreio "github.com/Assifar-Karim/apollo/internal/io"
 	"github.com/Assifar-Karim/apollo/internal/proto"
 	"github.com/Assifar-Karim/apollo/internal/utils"
+	"github.com/Assifar-Karim/apollo/internal/worker"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/kubernetes"
-	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
-const MaxRetries = 5
+const (
+	InputCredentialsKey  = "input"
+	OutputCredentialsKey = "output"
+)
 
 type JobScheduler interface {
-	ScheduleJob(job db.Job, programArtifacts []db.Artifact, creds []coreio.Credentials, splitSize *int64) ([]db.Task, error)
-	StopJob(id string) error
+	ScheduleJob(job db.Job, programArtifacts []db.Artifact, creds []coreio.Credentials, opts JobOptions) ([]db.Task, error)
+	StopJob(job db.Job) error
+	// ReleaseJob gives back the worker pods share, the dispatcher state and the secrets of a job that won't be scheduled
+	// anymore
+	ReleaseJob(job db.Job) error
+}
+
+type JobOptions struct {
+	SplitSize           *int64
+	PrefetchParallelism *int64
+	// The map tasks skip the records their program failed on that many times, nil keeps failing the job instead
+	SkipBadRecordsAfter *int64
+	// Number of program invocations a task runs at once, nil lets the workers derive it from their CPU allocation
+	ProgramConcurrency *int64
+	// The logs of the worker pods are copied to the job output once the job ends
+	ArchiveLogs bool
+	PodSettings PodSettings
+	PodTemplate string
 }
 
 type JobSchedulingSvc struct {
-	config         *Config
-	podClient      v1.PodInterface
-	k8sClient      *kubernetes.Clientset
-	taskRepository db.TaskRepository
-	logger         *utils.Logger
+	config          *Config
+	ca              *CertificateAuthority
+	k8sClient       kubernetes.Interface
+	projectManager  ProjectManager
+	podTemplates    PodTemplateLoader
+	fairShare       FairShareScheduler
+	workerCollector WorkerCollector
+	workerLogs      WorkerLogs
+	dispatcher      TaskDispatcher
+	taskRepository  db.TaskRepository
+	logger          *utils.Logger
 }
 
 func (s JobSchedulingSvc) ScheduleJob(
 	job db.Job,
 	programArtifacts []db.Artifact,
 	creds []coreio.Credentials,
-	splitSize *int64) ([]db.Task, error) {
+	opts JobOptions) ([]db.Task, error) {
+	// The job can't be dispatched anymore once it returns, a stop request doesn't have to be remembered past it
+	s.dispatcher.AdmitJob(job.Id)
+	defer s.dispatcher.ForgetJob(job.Id)
+
+	project, err := s.projectManager.GetProjectByName(job.Project)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
+	}
+	namespace := project.Namespace
 
 	splits, err := s.generateMapInputSplits(
 		job.InputData.Path,
 		job.Id,
 		job.InputData.Type,
-		creds[0].Username,
-		creds[0].Password,
-		splitSize)
+		creds[0],
+		opts.SplitSize)
 	if err != nil {
 		return nil, err
 	}
 	nMapper := len(splits)
+	// The queue could only check the reducers, the job peaks at its mappers or its reducers
+	if err := s.projectManager.CheckPodQuota(*project, max(nMapper, job.NReducers)); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
 
-	pods, err := s.createWorkerPods(job.Id, "mapper", programArtifacts[0].Name, "/mappers", nMapper)
+	template, err := s.podTemplates.LoadPodTemplate(opts.PodTemplate)
+	if err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+
+	if err := s.createJobSecrets(job.Id, namespace, creds); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	defer s.deleteJobSecrets(job.Id, namespace)
+	defer s.fairShare.Release(job.Id)
+
+	pods, err := s.createWorkerPods(*project, job.Id, "mapper", programArtifacts[0].Name, "/mappers", InputCredentialsKey, nMapper, template, opts.PodSettings)
 	if err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
@@ -69,12 +128,34 @@ func (s JobSchedulingSvc) ScheduleJob(
 		return nil, err
 	}
 
-	if err := s.coordinateMapTasks(mTasks, job, creds[0]); err != nil {
+	skipped, err := s.coordinateMapTasks(mTasks, job, namespace, opts)
+	if len(skipped) != 0 {
+		if err := s.writeDeadLetter(job, creds[1], skipped); err != nil {
+			s.logger.Error(err.Error())
+			return nil, err
+		}
+	}
+	if err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
 	}
 
-	pods, err = s.createWorkerPods(job.Id, "reducer", programArtifacts[1].Name, s.config.GetIntermediateFilesLoc(), job.NReducers)
+	// The mappers are done with their work, they are deleted so that their share can go back to the other jobs before
+	// the reducers wait for theirs
+	if opts.ArchiveLogs {
+		if err := s.workerLogs.ArchivePhaseLogs(job, creds[1], "mapper"); err != nil {
+			s.logger.Error("Could not archive job %s mapper logs -> %v", job.Id, err)
+		}
+	}
+	if err := s.workerCollector.DeletePhaseWorkers(job, "mapper"); err != nil {
+		return nil, err
+	}
+	s.fairShare.Release(job.Id)
+	if err := s.projectManager.CheckPodQuota(*project, job.NReducers); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	pods, err = s.createWorkerPods(*project, job.Id, "reducer", programArtifacts[1].Name, s.config.GetIntermediateFilesLoc(), OutputCredentialsKey, job.NReducers, template, opts.PodSettings)
 	if err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
@@ -86,7 +167,7 @@ func (s JobSchedulingSvc) ScheduleJob(
 		s.logger.Error(err.Error())
 		return nil, err
 	}
-	if err := s.coordinateReduceTasks(rTasks, nMapper, creds[1], job.Id, job.OutputLocation); err != nil {
+	if err := s.coordinateReduceTasks(rTasks, nMapper, job.Id, namespace, job.OutputLocation, opts); err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
 	}
@@ -95,28 +176,54 @@ func (s JobSchedulingSvc) ScheduleJob(
 	return tasks, nil
 }
 
-func (s JobSchedulingSvc) StopJob(id string) error {
-	listOptions := metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("job=%s", id),
-	}
-	err := s.podClient.DeleteCollection(context.Background(), metav1.DeleteOptions{}, listOptions)
+func (s JobSchedulingSvc) StopJob(job db.Job) error {
+	project, err := s.projectManager.GetProjectByName(job.Project)
 	if err != nil {
-		s.logger.Error("Could not delete job %s pods -> %v", id, err)
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
 	}
+	s.fairShare.Cancel(job.Id)
+	s.dispatcher.CancelJob(job.Id)
+	err = s.workerCollector.DeleteJobWorkers(job)
+	s.deleteJobSecrets(job.Id, project.Namespace)
 	return err
 }
 
-func (s JobSchedulingSvc) createWorkerPods(jobId, wType, programPath, mountPath string, nSize int) ([]string, error) {
-	podName := generatePodName("worker-")
+func (s JobSchedulingSvc) ReleaseJob(job db.Job) error {
+	project, err := s.projectManager.GetProjectByName(job.Project)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
+	}
+	s.fairShare.Cancel(job.Id)
+	s.dispatcher.ForgetJob(job.Id)
+	s.deleteJobSecrets(job.Id, project.Namespace)
+	return nil
+}
+
+func (s JobSchedulingSvc) createWorkerPods(
+	project db.Project,
+	jobId, wType, programPath, mountPath, credentialsKey string,
+	nSize int,
+	template *corev1.PodTemplateSpec,
+	settings PodSettings) ([]string, error) {
+	if err := s.fairShare.Acquire(project, jobId, nSize); err != nil {
+		return nil, err
+	}
+	namespace := project.Namespace
+	// The worker runs as root, the secrets are kept from the programs it runs as an unprivileged user
+	secretMode := int32(0400)
 	podDefinition := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: s.config.GetWorkerNS(),
-			Labels:    map[string]string{"type": wType, "job": jobId, "app": "worker"},
+			Namespace: namespace,
+			Labels:    map[string]string{"type": wType, "job": jobId, "app": "worker", "program": programPath},
 		},
 		Spec: corev1.PodSpec{
 			Subdomain: "workers",
-			Hostname:  podName,
 			Containers: []corev1.Container{
 				{
 					Name:  "worker",
@@ -126,11 +233,40 @@ func (s JobSchedulingSvc) createWorkerPods(jobId, wType, programPath, mountPath
 							ContainerPort: 8090,
 						},
 					},
+					// The workers derive the number of programs they run at once from their CPU allocation
+					Env: []corev1.EnvVar{
+						{
+							Name: worker.CPUAllocationEnv,
+							ValueFrom: &corev1.EnvVarSource{
+								ResourceFieldRef: &corev1.ResourceFieldSelector{
+									ContainerName: "worker",
+									Resource:      "limits.cpu",
+								},
+							},
+						},
+					},
+					// The task is only sent once the worker gRPC server accepts connections
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8090)},
+						},
+						PeriodSeconds: 1,
+					},
 					VolumeMounts: []corev1.VolumeMount{
 						{
 							Name:      "data",
 							MountPath: mountPath,
 						},
+						{
+							Name:      "credentials",
+							MountPath: coreio.CredentialsDir,
+							ReadOnly:  true,
+						},
+						{
+							Name:      "tls",
+							MountPath: utils.TLSDir,
+							ReadOnly:  true,
+						},
 					},
 				},
 			},
@@ -139,58 +275,67 @@ func (s JobSchedulingSvc) createWorkerPods(jobId, wType, programPath, mountPath
 					Name: "data",
 					VolumeSource: corev1.VolumeSource{
 						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-							ClaimName: "apollo-intermediate-files-pvc",
+							ClaimName: IntermediateFilesClaim,
+						},
+					},
+				},
+				{
+					Name: "credentials",
+					VolumeSource: corev1.VolumeSource{
+						// Each worker type only gets the credentials of the storage it talks to
+						Secret: &corev1.SecretVolumeSource{
+							SecretName: credentialsSecretName(jobId),
+							Items: []corev1.KeyToPath{
+								{
+									Key:  credentialsKey,
+									Path: coreio.CredentialsFile,
+								},
+							},
+							DefaultMode: &secretMode,
+						},
+					},
+				},
+				{
+					Name: "tls",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{
+							SecretName:  tlsSecretName(jobId),
+							DefaultMode: &secretMode,
 						},
 					},
 				},
 			},
 		},
 	}
+	if s.config.IsPullAssignment() {
+		s.setupPullingWorker(&podDefinition.Spec.Containers[0], jobId, wType)
+	}
+	ApplyPodTemplate(podDefinition, template)
+	settings.apply(podDefinition, wType)
+	if s.config.UseIndexedJobWorkers() {
+		return s.createIndexedJob(namespace, jobId, wType, podDefinition, nSize)
+	}
 	pods := make([]string, nSize)
 	for i := 0; i < nSize; i++ {
 		taskId := fmt.Sprintf("%s-%c-%v", jobId, wType[0], i)
-		if s.config.IsInDevMode() {
-			// Create a service for external communication with the coordinator on dev mode
-			servicePort, err := generateDevModeServicePort(taskId)
-			if err != nil {
-				return nil, err
-			}
-			serviceDefinition := &corev1.Service{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: fmt.Sprintf("dev-mode-service-%s", taskId),
-				},
-				Spec: corev1.ServiceSpec{
-					Ports: []corev1.ServicePort{
-						{
-							Port:     8090,
-							NodePort: int32(servicePort),
-						},
-					},
-					Selector: map[string]string{"id": taskId},
-					Type:     corev1.ServiceTypeNodePort,
-				},
-			}
-			_, err = s.k8sClient.CoreV1().Services(s.config.GetWorkerNS()).Create(
-				context.Background(),
-				serviceDefinition,
-				metav1.CreateOptions{})
-			if err != nil {
-				return nil, err
-			}
-
+		if err := s.createDevModeService(namespace, jobId, taskId); err != nil {
+			return nil, err
 		}
+		podName := generatePodName("worker-")
+		podDefinition.ObjectMeta.Name = podName
+		podDefinition.Spec.Hostname = podName
 		podDefinition.ObjectMeta.Labels["id"] = taskId
-		podDefinition.ObjectMeta.Labels["program"] = programPath
-		pod, err := s.podClient.Create(context.Background(), podDefinition, metav1.CreateOptions{})
-		if err != nil && err.Error() == fmt.Sprintf("namespaces \"%s\" not found", s.config.GetWorkerNS()) {
+		podClient := s.k8sClient.CoreV1().Pods(namespace)
+		pod, err := podClient.Create(context.Background(), podDefinition, metav1.CreateOptions{})
+		if err != nil && err.Error() == fmt.Sprintf("namespaces \"%s\" not found", namespace) {
 			s.logger.Warn("%s", err)
-			s.logger.Info("Creating %s namespace", s.config.GetWorkerNS())
+			s.logger.Info("Creating %s namespace", namespace)
 			s.k8sClient.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: s.config.GetWorkerNS(),
+					Name: namespace,
 				},
 			}, metav1.CreateOptions{})
-			pod, err = s.podClient.Create(context.Background(), podDefinition, metav1.CreateOptions{})
+			pod, err = podClient.Create(context.Background(), podDefinition, metav1.CreateOptions{})
 		}
 		if err != nil {
 			s.logger.Error("worker pod %v couldn't be created -> %v", i, err)
@@ -202,7 +347,125 @@ func (s JobSchedulingSvc) createWorkerPods(jobId, wType, programPath, mountPath
 	return pods, nil
 }
 
-func (s JobSchedulingSvc) generateMapInputSplits(path, jobId, wType, username, password string, splitSize *int64) ([]db.InputData, error) {
+// createIndexedJob runs the tasks of a phase as a single Indexed Job, the returned names are the hostnames of its
+// pods until the actual pod names are known
+func (s JobSchedulingSvc) createIndexedJob(namespace, jobId, wType string, podDefinition *corev1.Pod, nSize int) ([]string, error) {
+	name := fmt.Sprintf("%s-%c", jobId, wType[0])
+	completions := int32(nSize)
+	completionMode := batchv1.IndexedCompletion
+	backoffLimit := s.config.GetWorkerBackoffLimit()
+	ttl := int32(s.config.GetFailedJobsRetention().Seconds())
+	podSpec := podDefinition.Spec.DeepCopy()
+	// Kubernetes names the pods of an Indexed Job after their completion index
+	podSpec.Hostname = ""
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+	jobDefinition := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"type": wType, "job": jobId, "app": "worker"},
+		},
+		Spec: batchv1.JobSpec{
+			Completions:             &completions,
+			Parallelism:             &completions,
+			CompletionMode:          &completionMode,
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      podDefinition.ObjectMeta.Labels,
+					Annotations: podDefinition.ObjectMeta.Annotations,
+				},
+				Spec: *podSpec,
+			},
+		},
+	}
+	pods := make([]string, nSize)
+	for i := 0; i < nSize; i++ {
+		taskId := fmt.Sprintf("%s-%c-%v", jobId, wType[0], i)
+		if err := s.createDevModeService(namespace, jobId, taskId); err != nil {
+			return nil, err
+		}
+		pods[i] = fmt.Sprintf("%s-%v", name, i)
+	}
+	_, err := s.k8sClient.BatchV1().Jobs(namespace).Create(context.Background(), jobDefinition, metav1.CreateOptions{})
+	if err != nil {
+		s.logger.Error("worker job %s couldn't be created -> %v", name, err)
+		return nil, err
+	}
+	s.logger.Info("worker job %s was successfully created for job %s with %v tasks", name, jobId, nSize)
+	return pods, nil
+}
+
+// setupPullingWorker points the worker container to the task dispatcher, the pulling workers don't serve any gRPC
+// server that the coordinator would wait for
+func (s JobSchedulingSvc) setupPullingWorker(container *corev1.Container, jobId, wType string) {
+	taskType := 0
+	if wType == "reducer" {
+		taskType = 1
+	}
+	container.Ports = nil
+	container.ReadinessProbe = nil
+	container.Env = append(container.Env,
+		corev1.EnvVar{
+			Name: worker.WorkerIdEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		},
+		corev1.EnvVar{Name: worker.JobIdEnv, Value: jobId},
+		corev1.EnvVar{Name: worker.TaskTypeEnv, Value: fmt.Sprint(taskType)},
+		corev1.EnvVar{Name: worker.CoordinatorAddressEnv, Value: s.config.GetCoordinatorAddress()},
+	)
+}
+
+// createDevModeService exposes a task worker to the coordinator running outside of the cluster in dev mode, the
+// pulling workers reach the coordinator by themselves and don't need one
+func (s JobSchedulingSvc) createDevModeService(namespace, jobId, taskId string) error {
+	if !s.config.IsInDevMode() || s.config.IsPullAssignment() {
+		return nil
+	}
+	servicePort, err := generateDevModeServicePort(taskId)
+	if err != nil {
+		return err
+	}
+	serviceDefinition := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("dev-mode-service-%s", taskId),
+			Labels: map[string]string{"app": "worker", "job": jobId, "id": taskId},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Port:     8090,
+					NodePort: int32(servicePort),
+				},
+			},
+			Selector: s.taskPodLabels(taskId),
+			Type:     corev1.ServiceTypeNodePort,
+		},
+	}
+	_, err = s.k8sClient.CoreV1().Services(namespace).Create(
+		context.Background(),
+		serviceDefinition,
+		metav1.CreateOptions{})
+	return err
+}
+
+// taskPodLabels returns the labels identifying the pods running a task, the tasks of an Indexed Job are found
+// through the job name and completion index that the task id ends with
+func (s JobSchedulingSvc) taskPodLabels(taskId string) map[string]string {
+	if !s.config.UseIndexedJobWorkers() {
+		return map[string]string{"id": taskId}
+	}
+	separator := strings.LastIndex(taskId, "-")
+	return map[string]string{
+		batchv1.JobNameLabel:                 taskId[:separator],
+		batchv1.JobCompletionIndexAnnotation: taskId[separator+1:],
+	}
+}
+
+func (s JobSchedulingSvc) generateMapInputSplits(path, jobId, wType string, creds coreio.Credentials, splitSize *int64) ([]db.InputData, error) {
 	pathInfo := strings.Split(path, "/")
 	endpoint := strings.Join(pathInfo[2:len(pathInfo)-2], "/")
 
@@ -221,7 +484,7 @@ func (s JobSchedulingSvc) generateMapInputSplits(path, jobId, wType, username, p
 	if s.config.IsInDevMode() {
 		endpoint = regexp.MustCompile(`(.)*:`).ReplaceAllString(endpoint, "localhost:")
 	}
-	s3Registrar, err := coreio.NewS3Registrar(endpoint, username, password, useSSL)
+	s3Registrar, err := coreio.NewS3Registrar(endpoint, useSSL, creds)
 	if err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
@@ -229,11 +492,20 @@ func (s JobSchedulingSvc) generateMapInputSplits(path, jobId, wType, username, p
 	bucket := pathInfo[len(pathInfo)-2]
 	filename := pathInfo[len(pathInfo)-1]
 
-	filesize, err := s3Registrar.GetFileSize(bucket, filename)
+	metadata, err := s3Registrar.GetFileMetadata(bucket, filename)
 	if err != nil {
 		s.logger.Error(err.Error())
 		return nil, err
 	}
+	filesize := metadata.Size
+	// Every split is pinned to the snapshot of the input that was used to plan it
+	var etag, versionId *string
+	if metadata.ETag != "" {
+		etag = &metadata.ETag
+	}
+	if metadata.VersionId != "" {
+		versionId = &metadata.VersionId
+	}
 
 	var concreteSplitSize int64
 	if splitSize == nil {
@@ -257,31 +529,37 @@ func (s JobSchedulingSvc) generateMapInputSplits(path, jobId, wType, username, p
 			Type:       wType,
 			SplitStart: &a,
 			SplitEnd:   &b,
+			ETag:       etag,
+			VersionId:  versionId,
 		})
 	}
 	s.logger.Info("Input file %s of size %s B generated %v of maximum size %v", path, filesize, len(splits), concreteSplitSize)
 	return splits, nil
 }
 
-func (s JobSchedulingSvc) coordinateMapTasks(tasks []db.Task, job db.Job, creds coreio.Credentials) error {
+// coordinateMapTasks runs the map tasks of a job and returns the records they skipped by task
+func (s JobSchedulingSvc) coordinateMapTasks(tasks []db.Task, job db.Job, namespace string, opts JobOptions) (map[string][]*proto.SkippedRecord, error) {
 	var taskGroup errgroup.Group
+	skippers := make(map[string]*RecordSkipper, len(tasks))
 	for i := 0; i < len(tasks); i++ {
 		taskType, err := tasks[i].GetType()
 		if err != nil {
 			s.logger.Error(err.Error())
-			return err
+			return nil, err
 		}
 		s.logger.Info("Program name: %s", tasks[i].Program.Name)
 		programContent, err := tasks[i].GetProgramContent(s.config.GetArtifactsPath())
 		if err != nil {
 			s.logger.Error(err.Error())
-			return err
+			return nil, err
 		}
 
 		inputData := []*proto.FileData{{
 			Path:       tasks[i].InputData.Path,
 			SplitStart: tasks[i].InputData.SplitStart,
 			SplitEnd:   tasks[i].InputData.SplitEnd,
+			Etag:       tasks[i].InputData.ETag,
+			VersionId:  tasks[i].InputData.VersionId,
 		}}
 
 		if i < len(tasks)-1 {
@@ -291,12 +569,13 @@ func (s JobSchedulingSvc) coordinateMapTasks(tasks []db.Task, job db.Job, creds
 					Path:       tasks[i+1].InputData.Path,
 					SplitStart: tasks[i+1].InputData.SplitStart,
 					SplitEnd:   tasks[i+1].InputData.SplitEnd,
+					Etag:       tasks[i+1].InputData.ETag,
+					VersionId:  tasks[i+1].InputData.VersionId,
 				},
 			)
 		}
 		nReducers := int64(job.NReducers)
 
-		target := *tasks[i].PodName
 		payload := &proto.Task{
 			Id:        tasks[i].Id,
 			Type:      taskType,
@@ -305,20 +584,33 @@ func (s JobSchedulingSvc) coordinateMapTasks(tasks []db.Task, job db.Job, creds
 				Name:    fmt.Sprintf("/apollo/%s", tasks[i].Program.Name),
 				Content: programContent,
 			},
-			InputData: inputData,
-			ObjectStorageCreds: &proto.Credentials{
-				Username: creds.Username,
-				Password: creds.Password,
-			},
+			InputData:           inputData,
+			PrefetchParallelism: opts.PrefetchParallelism,
+			ProgramLimits:       s.config.GetProgramLimits(),
+			ProgramConcurrency:  opts.ProgramConcurrency,
 		}
+		skipper := NewRecordSkipper(opts.SkipBadRecordsAfter)
+		skippers[payload.Id] = skipper
 		taskGroup.Go(func() error {
-			return s.startTask(target, payload)
+			return s.startTask(namespace, job.Id, payload, skipper)
 		})
 	}
-	return taskGroup.Wait()
+	err := taskGroup.Wait()
+	skipped := map[string][]*proto.SkippedRecord{}
+	for taskId, skipper := range skippers {
+		if records := skipper.Skipped(); len(records) != 0 {
+			skipped[taskId] = records
+		}
+	}
+	return skipped, err
 }
 
-func (s JobSchedulingSvc) coordinateReduceTasks(tasks []db.Task, nMapper int, creds coreio.Credentials, jobId string, outLoc db.OutputLocation) error {
+func (s JobSchedulingSvc) coordinateReduceTasks(
+	tasks []db.Task,
+	nMapper int,
+	jobId, namespace string,
+	outLoc db.OutputLocation,
+	opts JobOptions) error {
 	var taskGroup errgroup.Group
 	for i := 0; i < len(tasks); i++ {
 		taskType, err := tasks[i].GetType()
@@ -340,7 +632,6 @@ func (s JobSchedulingSvc) coordinateReduceTasks(tasks []db.Task, nMapper int, cr
 				Path: path,
 			})
 		}
-		target := *tasks[i].PodName
 		payload := &proto.Task{
 			Id:   tasks[i].Id,
 			Type: taskType,
@@ -349,76 +640,185 @@ func (s JobSchedulingSvc) coordinateReduceTasks(tasks []db.Task, nMapper int, cr
 				Content: programContent,
 			},
 			InputData: inputData,
-			ObjectStorageCreds: &proto.Credentials{
-				Username: creds.Username,
-				Password: creds.Password,
-			},
 			OutputStorageInfo: &proto.OutputStorageInfo{
 				Location: outLoc.Location,
 				UseSSL:   &outLoc.UseSSL,
 			},
+			ProgramLimits:      s.config.GetProgramLimits(),
+			ProgramConcurrency: opts.ProgramConcurrency,
 		}
 		taskGroup.Go(func() error {
-			return s.startTask(target, payload)
+			return s.startTask(namespace, jobId, payload, nil)
 		})
 	}
 
 	return taskGroup.Wait()
 }
 
-func (s JobSchedulingSvc) startTask(target string, task *proto.Task) error {
-	target = fmt.Sprintf("%s.workers.%s.svc.cluster.local:8090", target, s.config.GetWorkerNS())
+// writeDeadLetter stores the records skipped by the map tasks of a job next to its output
+func (s JobSchedulingSvc) writeDeadLetter(job db.Job, creds coreio.Credentials, skipped map[string][]*proto.SkippedRecord) error {
+	content, err := MarshalDeadLetter(skipped)
+	if err != nil {
+		return err
+	}
+	s3Registrar, err := newOutputS3Registrar(job.OutputLocation, creds, s.config.IsInDevMode())
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/reducers/%v/%v", job.Id, DeadLetterFilename)
+	s.logger.Warn("Writing the records skipped by the map tasks of job %s to %s", job.Id, path)
+	return s3Registrar.WriteFile(path, content)
+}
+
+// newOutputS3Registrar connects to the object storage holding the output of a job
+func newOutputS3Registrar(outLoc db.OutputLocation, creds coreio.Credentials, devMode bool) (*coreio.S3Registrar, error) {
+	endpoint := outLoc.Location
+	useSSL := outLoc.UseSSL
+	if locationInfo := strings.Split(endpoint, "/"); locationInfo[0] == "http:" || locationInfo[0] == "https:" {
+		useSSL = locationInfo[0] == "https:"
+		endpoint = strings.Join(locationInfo[2:], "/")
+	}
+	if devMode {
+		endpoint = regexp.MustCompile(`(.)*:`).ReplaceAllString(endpoint, "localhost:")
+	}
+	return coreio.NewS3Registrar(endpoint, useSSL, creds)
+}
+
+// startTask sends a task to its worker once its pod is ready, the tasks of Indexed Jobs are sent again to the pods
+// replacing the failed ones until the backoff limit is reached and the map tasks whose program failed on some records
+// are sent again skipping them when the job asked for it
+func (s JobSchedulingSvc) startTask(namespace, jobId string, task *proto.Task, skipper *RecordSkipper) error {
+	attempts := 1
+	if s.config.UseIndexedJobWorkers() {
+		attempts += int(s.config.GetWorkerBackoffLimit())
+	}
+	var statusInfo *proto.TaskStatusInfo
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retriable bool
+		if s.config.IsPullAssignment() {
+			statusInfo, retriable, err = s.dispatchTask(namespace, jobId, task)
+		} else {
+			statusInfo, retriable, err = s.runTask(namespace, task)
+		}
+		if err == nil || (s.config.UseIndexedJobWorkers() && s.isWorkerJobFailed(namespace, task.Id)) {
+			break
+		}
+		if retriable && skipper.Retry(err) {
+			task.SkipRecords = skipper.SkipList()
+			s.logger.Warn("Attempt %v of task %s failed on bad records, it will be retried skipping the records at offsets %v -> %v",
+				attempt, task.Id, task.SkipRecords, err)
+			continue
+		}
+		if !retriable || attempt >= attempts {
+			break
+		}
+		s.logger.Warn("Attempt %v of task %s failed, it will be retried on a new pod -> %v", attempt, task.Id, err)
+	}
+	if err != nil {
+		return s.failTask(task.Id, err)
+	}
+	skipper.keep(statusInfo.GetSkippedRecords())
+	s.logger.Info("Task %s has completed its workload", task.Id)
+	return s.taskRepository.UpdateTaskEndTimeByID(task.Id, time.Now().Unix())
+}
+
+// runTask makes a single attempt at running a task, the problems preventing its pod from starting aren't retriable
+func (s JobSchedulingSvc) runTask(namespace string, task *proto.Task) (*proto.TaskStatusInfo, bool, error) {
+	pod, err := s.waitForTaskPod(namespace, task.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.taskRepository.UpdateTaskPodNameByID(task.Id, pod.Name); err != nil {
+		return nil, false, err
+	}
+	hostname := pod.Spec.Hostname
+	if hostname == "" {
+		hostname = pod.Name
+	}
+	target := fmt.Sprintf("%s.workers.%s.svc.cluster.local:8090", hostname, namespace)
 	if s.config.IsInDevMode() {
 		port, err := generateDevModeServicePort(task.GetId())
 		if err != nil {
-			return err
+			return nil, false, err
 		}
 		target = fmt.Sprintf("localhost:%v", port)
 	}
-	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(credentials.NewTLS(s.ca.ClientTLSConfig())))
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 	defer conn.Close()
 	s.logger.Info("Connected successfuly to %s", target)
 	client := proto.NewTaskCreatorClient(conn)
-	stream, err := client.StartTask(context.Background(), task)
-	retries := MaxRetries
-	exp := 2
-	for retries > 0 && err != nil {
-		s.logger.Warn("Connection attempt %v to %s failed with error %v", MaxRetries-retries+1, target, err)
-		backoff := time.Duration(exp-1) * time.Second
-		s.logger.Info("Retrying connection to %s in %v", target, backoff)
-		time.Sleep(backoff)
-		retries--
-		exp *= 2
-		stream, err = client.StartTask(context.Background(), task)
-	}
+	heartbeatInterval := s.config.GetHeartbeatInterval().Milliseconds()
+	task.HeartbeatIntervalMs = &heartbeatInterval
+	// The stream is canceled when the worker stops sending heartbeats
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	detector := newSilenceDetector(s.config.GetLostTaskTimeout(), cancel)
+	defer detector.Stop()
+	stream, err := client.StartTask(ctx, task)
 	if err != nil {
-		return err
+		return nil, true, s.explainPodFailure(namespace, task.Id, err)
 	}
 
 	s.logger.Info("Starting task %v in %s", task.Id, target)
+	var lastStatusInfo *proto.TaskStatusInfo
 	for {
 		taskStatusInfo, err := stream.Recv()
+		if detector.IsLost() {
+			s.logger.Warn("Task %s was lost -> %v", task.Id, detector.Err())
+			return nil, true, s.explainPodFailure(namespace, task.Id, detector.Err())
+		}
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return nil, true, s.explainPodFailure(namespace, task.Id, err)
 		}
+		detector.Beat()
+		if taskStatusInfo.Progress != nil {
+			err = s.taskRepository.UpdateTaskProgressByID(task.Id, taskProgressFromProto(taskStatusInfo.Progress))
+			if err != nil {
+				s.logger.Warn("Could not store task %s progress -> %v", task.Id, err)
+			}
+			continue
+		}
+		storeTaskOutcome(s.taskRepository, s.logger, task.Id, taskStatusInfo)
 		err = s.taskRepository.UpdateTaskStatusByID(task.Id, taskStatusInfo.TaskStatus)
 		if err != nil {
-			return err
+			return nil, false, err
 		}
+		lastStatusInfo = taskStatusInfo
 
 		if taskStatusInfo.TaskStatus == "failed" {
-			errMsg := fmt.Sprintf("Task %s has failed", task.Id)
-			return fmt.Errorf(errMsg)
+			reason := "the worker reported a failure"
+			// The worker closes the stream with the error that caused the failure
+			if _, err := stream.Recv(); err != nil && err != io.EOF {
+				reason = status.Convert(err).Message()
+			}
+			return taskStatusInfo, true, taskFailure(taskStatusInfo, reason)
 		}
 	}
-	s.logger.Info("Task %s has completed its workload", task.Id)
-	return s.taskRepository.UpdateTaskEndTimeByID(task.Id, time.Now().Unix())
+	return lastStatusInfo, false, nil
+}
+
+// dispatchTask makes a single attempt at running a task by handing it to the first worker of its job that pulls it
+func (s JobSchedulingSvc) dispatchTask(namespace, jobId string, task *proto.Task) (*proto.TaskStatusInfo, bool, error) {
+	statusInfo, err := s.dispatcher.Dispatch(jobId, task)
+	if errors.Is(err, ErrTaskNotAssigned) {
+		return nil, false, s.explainWorkersFailure(namespace, jobId, task.GetType(), err)
+	}
+	return statusInfo, err != nil && !errors.Is(err, ErrDispatchCanceled), err
+}
+
+// failTask records why a task failed and returns the failure
+func (s JobSchedulingSvc) failTask(taskId string, err error) error {
+	if dbErr := s.taskRepository.FailTaskByID(taskId, err.Error()); dbErr != nil {
+		s.logger.Error("Could not store task %s failure reason -> %v", taskId, dbErr)
+	}
+	return fmt.Errorf("task %s has failed -> %w", taskId, err)
 }
 
 func generateDevModeServicePort(taskId string) (int, error) {
@@ -444,14 +844,27 @@ func generatePodName(base string) string {
 	return fmt.Sprintf("%s%s", base, utilrand.String(randomLength))
 }
 
-func NewJobScheduler(k8sClient *kubernetes.Clientset, taskRepository db.TaskRepository) JobScheduler {
-	config := GetConfig()
-	podClient := k8sClient.CoreV1().Pods(config.GetWorkerNS())
+func NewJobScheduler(
+	k8sClient kubernetes.Interface,
+	taskRepository db.TaskRepository,
+	projectManager ProjectManager,
+	podTemplates PodTemplateLoader,
+	fairShare FairShareScheduler,
+	workerCollector WorkerCollector,
+	workerLogs WorkerLogs,
+	dispatcher TaskDispatcher,
+	ca *CertificateAuthority) JobScheduler {
 	return &JobSchedulingSvc{
-		config:         config,
-		podClient:      podClient,
-		k8sClient:      k8sClient,
-		taskRepository: taskRepository,
-		logger:         utils.GetLogger(),
+		config:          GetConfig(),
+		ca:              ca,
+		k8sClient:       k8sClient,
+		projectManager:  projectManager,
+		podTemplates:    podTemplates,
+		fairShare:       fairShare,
+		workerCollector: workerCollector,
+		workerLogs:      workerLogs,
+		dispatcher:      dispatcher,
+		taskRepository:  taskRepository,
+		logger:          utils.GetLogger(),
 	}
 }
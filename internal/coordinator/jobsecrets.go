@@ -0,0 +1,93 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func credentialsSecretName(jobId string) string {
+	return fmt.Sprintf("%s-storage-credentials", jobId)
+}
+
+func tlsSecretName(jobId string) string {
+	return fmt.Sprintf("%s-tls", jobId)
+}
+
+// createJobSecrets stores the job object storage credentials and the worker TLS material in secrets that get
+// mounted in the worker pods so that they never travel in the task payloads
+func (s JobSchedulingSvc) createJobSecrets(jobId, namespace string, creds []coreio.Credentials) error {
+	inputCreds, err := json.Marshal(creds[0])
+	if err != nil {
+		return err
+	}
+	outputCreds, err := json.Marshal(creds[1])
+	if err != nil {
+		return err
+	}
+	certPEM, keyPEM, err := s.ca.IssueWorkerCertificate(jobId, namespace)
+	if err != nil {
+		return err
+	}
+	secretDefinitions := []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      credentialsSecretName(jobId),
+				Namespace: namespace,
+				Labels:    map[string]string{"job": jobId, "app": "worker"},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				InputCredentialsKey:  inputCreds,
+				OutputCredentialsKey: outputCreds,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      tlsSecretName(jobId),
+				Namespace: namespace,
+				Labels:    map[string]string{"job": jobId, "app": "worker"},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+				"ca.crt":                s.ca.GetCACertPEM(),
+			},
+		},
+	}
+	secretClient := s.k8sClient.CoreV1().Secrets(namespace)
+	for _, secretDefinition := range secretDefinitions {
+		_, err = secretClient.Create(context.Background(), secretDefinition, metav1.CreateOptions{})
+		if err != nil && err.Error() == fmt.Sprintf("namespaces \"%s\" not found", namespace) {
+			s.logger.Warn("%s", err)
+			s.logger.Info("Creating %s namespace", namespace)
+			s.k8sClient.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: namespace,
+				},
+			}, metav1.CreateOptions{})
+			_, err = secretClient.Create(context.Background(), secretDefinition, metav1.CreateOptions{})
+		}
+		if err != nil {
+			s.logger.Error("secret %s of job %s couldn't be created -> %v", secretDefinition.Name, jobId, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s JobSchedulingSvc) deleteJobSecrets(jobId, namespace string) {
+	secretClient := s.k8sClient.CoreV1().Secrets(namespace)
+	for _, name := range []string{credentialsSecretName(jobId), tlsSecretName(jobId)} {
+		err := secretClient.Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			s.logger.Error("Could not delete job %s secret %s -> %v", jobId, name, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package coordinator
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var ErrTaskLost = errors.New("task lost")
+
+// silenceDetector calls its callback once a worker stayed silent for longer than the lost task timeout, every
+// message received from the worker restarts the countdown
+type silenceDetector struct {
+	timeout time.Duration
+	timer   *time.Timer
+	silent  atomic.Bool
+}
+
+func (d *silenceDetector) Beat() {
+	d.timer.Reset(d.timeout)
+}
+
+func (d *silenceDetector) Stop() {
+	d.timer.Stop()
+}
+
+func (d *silenceDetector) IsLost() bool {
+	return d.silent.Load()
+}
+
+func (d *silenceDetector) Err() error {
+	return fmt.Errorf("%w: no heartbeat was received from its worker for %v", ErrTaskLost, d.timeout)
+}
+
+func newSilenceDetector(timeout time.Duration, onSilence func()) *silenceDetector {
+	detector := &silenceDetector{timeout: timeout}
+	detector.timer = time.AfterFunc(timeout, func() {
+		detector.silent.Store(true)
+		onSilence()
+	})
+	return detector
+}
@@ -0,0 +1,82 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+var ErrPodProfileNotFound = errors.New("pod profile can't be found")
+
+type PodProfileManager interface {
+	PersistPodProfile(name string, settings PodSettings) (db.PodProfile, error)
+	GetAllPodProfiles() ([]db.PodProfile, error)
+	GetPodProfileByName(name string) (*db.PodProfile, error)
+	DeletePodProfile(name string) (bool, error)
+	ResolvePodSettings(profileName string, overrides *PodSettings) (PodSettings, error)
+}
+
+type PodProfileMngmtSvc struct {
+	podProfileRepository db.PodProfileRepository
+	logger               *utils.Logger
+}
+
+func (s PodProfileMngmtSvc) PersistPodProfile(name string, settings PodSettings) (db.PodProfile, error) {
+	content, err := json.Marshal(settings)
+	if err != nil {
+		return db.PodProfile{}, err
+	}
+	profile := db.PodProfile{Name: name, Settings: content}
+	existing, err := s.podProfileRepository.FetchPodProfileByName(name)
+	if err != nil {
+		return db.PodProfile{}, err
+	}
+	if existing == nil {
+		return s.podProfileRepository.CreatePodProfile(profile)
+	}
+	return s.podProfileRepository.UpdatePodProfile(profile)
+}
+
+func (s PodProfileMngmtSvc) GetAllPodProfiles() ([]db.PodProfile, error) {
+	return s.podProfileRepository.FetchPodProfiles()
+}
+
+func (s PodProfileMngmtSvc) GetPodProfileByName(name string) (*db.PodProfile, error) {
+	return s.podProfileRepository.FetchPodProfileByName(name)
+}
+
+func (s PodProfileMngmtSvc) DeletePodProfile(name string) (bool, error) {
+	return s.podProfileRepository.DeletePodProfile(name)
+}
+
+// ResolvePodSettings starts from the named profile, if any, and applies the job specific overrides on top of it
+func (s PodProfileMngmtSvc) ResolvePodSettings(profileName string, overrides *PodSettings) (PodSettings, error) {
+	settings := PodSettings{}
+	if profileName != "" {
+		profile, err := s.podProfileRepository.FetchPodProfileByName(profileName)
+		if err != nil {
+			return PodSettings{}, err
+		}
+		if profile == nil {
+			return PodSettings{}, fmt.Errorf("%w: %s", ErrPodProfileNotFound, profileName)
+		}
+		if err := json.Unmarshal(profile.Settings, &settings); err != nil {
+			s.logger.Error("Pod profile %s is corrupted -> %v", profileName, err)
+			return PodSettings{}, err
+		}
+	}
+	if overrides != nil {
+		settings = settings.Override(*overrides)
+	}
+	return settings, nil
+}
+
+func NewPodProfileManager(podProfileRepository db.PodProfileRepository) PodProfileManager {
+	return &PodProfileMngmtSvc{
+		podProfileRepository: podProfileRepository,
+		logger:               utils.GetLogger(),
+	}
+}
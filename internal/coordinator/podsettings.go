@@ -0,0 +1,83 @@
+package coordinator
+
+import (
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodSettings customizes the scheduling and the resources of the worker pods of a job
+type PodSettings struct {
+	MapperResources   *corev1.ResourceRequirements `json:"mapperResources,omitempty"`
+	ReducerResources  *corev1.ResourceRequirements `json:"reducerResources,omitempty"`
+	NodeSelector      map[string]string            `json:"nodeSelector,omitempty"`
+	Tolerations       []corev1.Toleration          `json:"tolerations,omitempty"`
+	Affinity          *corev1.Affinity             `json:"affinity,omitempty"`
+	PriorityClassName string                       `json:"priorityClassName,omitempty"`
+	Labels            map[string]string            `json:"labels,omitempty"`
+	Annotations       map[string]string            `json:"annotations,omitempty"`
+}
+
+// Override returns the settings with every field set in overrides replacing its counterpart, labels and
+// annotations are merged key by key
+func (p PodSettings) Override(overrides PodSettings) PodSettings {
+	if overrides.MapperResources != nil {
+		p.MapperResources = overrides.MapperResources
+	}
+	if overrides.ReducerResources != nil {
+		p.ReducerResources = overrides.ReducerResources
+	}
+	if overrides.NodeSelector != nil {
+		p.NodeSelector = overrides.NodeSelector
+	}
+	if overrides.Tolerations != nil {
+		p.Tolerations = overrides.Tolerations
+	}
+	if overrides.Affinity != nil {
+		p.Affinity = overrides.Affinity
+	}
+	if overrides.PriorityClassName != "" {
+		p.PriorityClassName = overrides.PriorityClassName
+	}
+	p.Labels = mergeMaps(p.Labels, overrides.Labels)
+	p.Annotations = mergeMaps(p.Annotations, overrides.Annotations)
+	return p
+}
+
+// apply sets the settings on a worker pod on top of its template, the labels the coordinator relies on always take
+// precedence
+func (p PodSettings) apply(pod *corev1.Pod, wType string) {
+	resources := p.MapperResources
+	if wType == "reducer" {
+		resources = p.ReducerResources
+	}
+	if resources != nil {
+		pod.Spec.Containers[0].Resources = *resources
+	}
+	if p.NodeSelector != nil {
+		pod.Spec.NodeSelector = p.NodeSelector
+	}
+	if p.Tolerations != nil {
+		pod.Spec.Tolerations = p.Tolerations
+	}
+	if p.Affinity != nil {
+		pod.Spec.Affinity = p.Affinity
+	}
+	if p.PriorityClassName != "" {
+		pod.Spec.PriorityClassName = p.PriorityClassName
+	}
+	pod.ObjectMeta.Labels = mergeMaps(p.Labels, pod.ObjectMeta.Labels)
+	pod.ObjectMeta.Annotations = mergeMaps(pod.ObjectMeta.Annotations, p.Annotations)
+}
+
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	if base == nil && overrides == nil {
+		return nil
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, overrides)
+	return merged
+}
@@ -0,0 +1,121 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// PodTemplateKey is the ConfigMap entry holding the YAML or JSON encoded pod template
+const PodTemplateKey = "template"
+
+var ErrPodTemplateNotFound = errors.New("pod template can't be found")
+
+type PodTemplateLoader interface {
+	LoadPodTemplate(name string) (*corev1.PodTemplateSpec, error)
+}
+
+type PodTemplateLoadingSvc struct {
+	k8sClient kubernetes.Interface
+	config    *Config
+	logger    *utils.Logger
+}
+
+// LoadPodTemplate reads the pod template stored in the named ConfigMap of the coordinator namespace, an empty name
+// falls back to the default template and nil is returned when none was configured
+func (s PodTemplateLoadingSvc) LoadPodTemplate(name string) (*corev1.PodTemplateSpec, error) {
+	if name == "" {
+		name = s.config.GetWorkerPodTemplate()
+	}
+	if name == "" {
+		return nil, nil
+	}
+	configMap, err := s.k8sClient.CoreV1().ConfigMaps(s.config.GetCoordinatorNS()).Get(
+		context.Background(),
+		name,
+		metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrPodTemplateNotFound, name)
+	}
+	if err != nil {
+		s.logger.Error("Could not get the %s pod template -> %v", name, err)
+		return nil, err
+	}
+	content, exists := configMap.Data[PodTemplateKey]
+	if !exists {
+		return nil, fmt.Errorf("%s ConfigMap has no %s entry", name, PodTemplateKey)
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err := yaml.Unmarshal([]byte(content), template); err != nil {
+		s.logger.Error("Pod template %s is invalid -> %v", name, err)
+		return nil, err
+	}
+	return template, nil
+}
+
+// ApplyPodTemplate rebuilds the pod on top of the template, the worker container, hostname, subdomain, labels and
+// volumes set by the coordinator override their counterparts in the template while everything else is kept
+func ApplyPodTemplate(pod *corev1.Pod, template *corev1.PodTemplateSpec) {
+	if template == nil {
+		return
+	}
+	spec := template.Spec.DeepCopy()
+	worker := pod.Spec.Containers[0]
+	containers := []corev1.Container{worker}
+	for _, container := range spec.Containers {
+		if container.Name != worker.Name {
+			containers = append(containers, container)
+			continue
+		}
+		// The worker container of the template only brings the settings the coordinator doesn't care about
+		merged := *container.DeepCopy()
+		merged.Image = worker.Image
+		merged.Ports = worker.Ports
+		merged.ReadinessProbe = worker.ReadinessProbe
+		merged.Env = mergeByName(merged.Env, worker.Env, func(e corev1.EnvVar) string { return e.Name })
+		merged.VolumeMounts = mergeByName(merged.VolumeMounts, worker.VolumeMounts,
+			func(m corev1.VolumeMount) string { return m.Name })
+		containers[0] = merged
+	}
+	spec.Containers = containers
+	spec.Volumes = mergeByName(spec.Volumes, pod.Spec.Volumes, func(v corev1.Volume) string { return v.Name })
+	spec.Hostname = pod.Spec.Hostname
+	spec.Subdomain = pod.Spec.Subdomain
+
+	pod.Spec = *spec
+	pod.ObjectMeta.Labels = mergeMaps(template.ObjectMeta.Labels, pod.ObjectMeta.Labels)
+	pod.ObjectMeta.Annotations = mergeMaps(template.ObjectMeta.Annotations, pod.ObjectMeta.Annotations)
+}
+
+// mergeByName returns the base items where the overrides replace the items sharing their name
+func mergeByName[T any](base, overrides []T, name func(T) string) []T {
+	merged := []T{}
+	for _, item := range base {
+		replaced := false
+		for _, override := range overrides {
+			if name(item) == name(override) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, item)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+func NewPodTemplateLoader(k8sClient kubernetes.Interface) PodTemplateLoader {
+	return &PodTemplateLoadingSvc{
+		k8sClient: k8sClient,
+		config:    GetConfig(),
+		logger:    utils.GetLogger(),
+	}
+}
@@ -0,0 +1,167 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Waiting reasons of a container that won't start without an intervention
+var fatalWaitingReasons = []string{
+	"ErrImagePull",
+	"ImagePullBackOff",
+	"InvalidImageName",
+	"CreateContainerConfigError",
+	"CreateContainerError",
+	"CrashLoopBackOff",
+}
+
+// PodFailureReason explains why a worker pod can't run its task, fatal tells whether the problem is permanent or
+// whether the pod may still recover from it, as an unschedulable pod does when the cluster scales up
+func PodFailureReason(pod *corev1.Pod) (reason string, fatal bool) {
+	statuses := append(slices.Clone(pod.Status.InitContainerStatuses), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == "OOMKilled" {
+				return fmt.Sprintf("OOMKilled: container %s ran out of memory", status.Name), true
+			}
+		}
+		if waiting := status.State.Waiting; waiting != nil && slices.Contains(fatalWaitingReasons, waiting.Reason) {
+			return fmt.Sprintf("%s: %s", waiting.Reason, waiting.Message), true
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Sprintf("%s: %s", pod.Status.Reason, pod.Status.Message), true
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return fmt.Sprintf("%s: %s", condition.Reason, condition.Message), false
+		}
+	}
+	return "", false
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// waitForTaskPod watches the pods of a task until one of them is ready to receive it, it gives up as soon as the pod
+// runs into a fatal problem or once the readiness timeout expires. The failed pods of an Indexed Job are ignored since
+// Kubernetes replaces them until the backoff limit is reached
+func (s JobSchedulingSvc) waitForTaskPod(namespace, taskId string) (*corev1.Pod, error) {
+	timeout := s.config.GetPodReadyTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	replaceable := s.config.UseIndexedJobWorkers()
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(s.taskPodLabels(taskId)).String(),
+	}
+	lastReason := ""
+	for ctx.Err() == nil {
+		watcher, err := s.k8sClient.CoreV1().Pods(namespace).Watch(ctx, listOptions)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		for event := range watcher.ResultChan() {
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			if replaceable && (event.Type == watch.Deleted || pod.DeletionTimestamp != nil ||
+				pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded) {
+				continue
+			}
+			if event.Type == watch.Deleted {
+				watcher.Stop()
+				return nil, fmt.Errorf("pod %s was deleted before it became ready", pod.Name)
+			}
+			if isPodReady(pod) {
+				watcher.Stop()
+				return pod, nil
+			}
+			reason, fatal := PodFailureReason(pod)
+			if fatal {
+				watcher.Stop()
+				return nil, errors.New(reason)
+			}
+			if reason != "" && reason != lastReason {
+				s.logger.Warn("Pod %s of task %s isn't ready yet -> %s", pod.Name, taskId, reason)
+			}
+			lastReason = reason
+		}
+		// The API server closes watches after a while, they are resumed until the timeout expires
+		watcher.Stop()
+	}
+	if lastReason != "" {
+		return nil, fmt.Errorf("task %s pod wasn't ready after %v -> %s", taskId, timeout, lastReason)
+	}
+	return nil, fmt.Errorf("task %s pod wasn't ready after %v", taskId, timeout)
+}
+
+// explainPodFailure replaces a connection error with the reason the task pod failed when there is one
+func (s JobSchedulingSvc) explainPodFailure(namespace, taskId string, err error) error {
+	pods, listErr := s.k8sClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(s.taskPodLabels(taskId)).String(),
+	})
+	if listErr != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if reason, _ := PodFailureReason(&pod); reason != "" {
+			return errors.New(reason)
+		}
+	}
+	return err
+}
+
+// explainWorkersFailure replaces the error of a task that no worker pulled with the reason the pods of its phase
+// failed when there is one
+func (s JobSchedulingSvc) explainWorkersFailure(namespace, jobId string, taskType int64, err error) error {
+	wType := "mapper"
+	if taskType == 1 {
+		wType = "reducer"
+	}
+	pods, listErr := s.k8sClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{"app": "worker", "job": jobId, "type": wType}).String(),
+	})
+	if listErr != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if reason, _ := PodFailureReason(&pod); reason != "" {
+			return fmt.Errorf("%w -> %s", err, reason)
+		}
+	}
+	return err
+}
+
+// isWorkerJobFailed tells whether the Indexed Job running a task gave up on retrying its pods
+func (s JobSchedulingSvc) isWorkerJobFailed(namespace, taskId string) bool {
+	name := s.taskPodLabels(taskId)[batchv1.JobNameLabel]
+	job, err := s.k8sClient.BatchV1().Jobs(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return true
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
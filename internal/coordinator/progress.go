@@ -0,0 +1,109 @@
+package coordinator
+
+import (
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+// Share of a task done once it reaches a phase, used when the size of its input isn't known upfront
+var phaseCompletion = map[string]float64{
+	"fetch":   0,
+	"map":     0.1,
+	"sort":    0.3,
+	"reduce":  0.4,
+	"persist": 0.9,
+}
+
+func taskProgressFromProto(progress *proto.TaskProgress) db.TaskProgress {
+	return db.TaskProgress{
+		Phase:            progress.GetPhase(),
+		BytesRead:        progress.GetBytesRead(),
+		BytesTotal:       progress.GetBytesTotal(),
+		RecordsProcessed: progress.GetRecordsProcessed(),
+		PairsEmitted:     progress.GetPairsEmitted(),
+		UpdateTime:       time.Now().Unix(),
+	}
+}
+
+func invocationLogsFromProto(logs []*proto.InvocationLog) []db.InvocationLog {
+	invocationLogs := make([]db.InvocationLog, 0, len(logs))
+	for _, log := range logs {
+		invocationLogs = append(invocationLogs, db.InvocationLog{
+			Invocation: log.GetInvocation(),
+			ExitCode:   log.GetExitCode(),
+			Stdout:     log.GetStdout(),
+			Stderr:     log.GetStderr(),
+		})
+	}
+	return invocationLogs
+}
+
+// storeTaskOutcome keeps the counters and the failed invocations logs sent along with the final status of a task, the
+// logs of earlier failed attempts are kept when the last attempt has none
+func storeTaskOutcome(taskRepository db.TaskRepository, logger *utils.Logger, taskId string, statusInfo *proto.TaskStatusInfo) {
+	if len(statusInfo.GetCounters()) != 0 {
+		if err := taskRepository.UpdateTaskCountersByID(taskId, statusInfo.GetCounters()); err != nil {
+			logger.Warn("Could not store task %s counters -> %v", taskId, err)
+		}
+	}
+	if len(statusInfo.GetInvocationLogs()) != 0 {
+		if err := taskRepository.UpdateTaskLogsByID(taskId, invocationLogsFromProto(statusInfo.GetInvocationLogs())); err != nil {
+			logger.Warn("Could not store task %s invocation logs -> %v", taskId, err)
+		}
+	}
+}
+
+// ComputeJobCompletion estimates the percentage of a job that is done, the map and reduce phases weigh as much as
+// their number of tasks and the tasks that aren't completed yet count for the share of their input they went through
+func ComputeJobCompletion(job db.Job, tasks []db.Task) float64 {
+	if job.Status == db.JobCompleted {
+		return 100
+	}
+	nMappers := 0
+	done := 0.0
+	for _, task := range tasks {
+		if task.Type == "mapper" {
+			nMappers++
+		}
+		done += taskCompletion(task)
+	}
+	// The reduce tasks are only created once the map phase is over
+	total := nMappers + job.NReducers
+	if nMappers == 0 || total == 0 {
+		return 0
+	}
+	return 100 * done / float64(total)
+}
+
+func taskCompletion(task db.Task) float64 {
+	if task.Status == "completed" {
+		return 1
+	}
+	progress := task.Progress
+	if progress == nil {
+		return 0
+	}
+	completion := phaseCompletion[progress.Phase]
+	if progress.BytesTotal > 0 && (progress.Phase == "map" || progress.Phase == "fetch") {
+		read := min(float64(progress.BytesRead)/float64(progress.BytesTotal), 1)
+		completion = phaseCompletion["persist"] * read
+	}
+	return completion
+}
+
+// AggregateCounters sums the counters of the completed tasks, the failed attempts aren't accounted for
+func AggregateCounters(tasks []db.Task) map[string]int64 {
+	totals := map[string]int64{}
+	for _, task := range tasks {
+		if task.Status != "completed" {
+			continue
+		}
+		for name, n := range task.Counters {
+			totals[name] += n
+		}
+	}
+	return totals
+}
@@ -0,0 +1,257 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DefaultProject         = "default"
+	IntermediateFilesClaim = "apollo-intermediate-files-pvc"
+)
+
+var (
+	ErrQuotaExceeded  = errors.New("project quota exceeded")
+	ErrProjectInUse   = errors.New("project still has running jobs")
+	ErrNamespaceInUse = errors.New("namespace already belongs to another project")
+)
+
+type ProjectManager interface {
+	PersistProject(project db.Project) (db.Project, error)
+	GetAllProjects() ([]db.Project, error)
+	GetProjectByName(name string) (*db.Project, error)
+	DeleteProject(name string) (bool, error)
+	CheckJobStartQuota(project db.Project, nReducers int) error
+	CheckPodQuota(project db.Project, nPods int) error
+}
+
+type ProjectMngmtSvc struct {
+	projectRepository db.ProjectRepository
+	jobRepository     db.JobRepository
+	k8sClient         kubernetes.Interface
+	config            *Config
+	logger            *utils.Logger
+}
+
+func (s ProjectMngmtSvc) PersistProject(project db.Project) (db.Project, error) {
+	existing, err := s.projectRepository.FetchProjects()
+	if err != nil {
+		return db.Project{}, err
+	}
+	isNew := true
+	for _, p := range existing {
+		if p.Name == project.Name {
+			isNew = false
+			if p.Namespace != project.Namespace {
+				return db.Project{}, fmt.Errorf("project %s namespace can't be changed from %s", p.Name, p.Namespace)
+			}
+		} else if p.Namespace == project.Namespace {
+			return db.Project{}, fmt.Errorf("%w: %s is used by %s", ErrNamespaceInUse, p.Namespace, p.Name)
+		}
+	}
+	if err := s.provisionNamespace(project.Namespace); err != nil {
+		s.logger.Error("Could not provision namespace %s of project %s -> %v", project.Namespace, project.Name, err)
+		return db.Project{}, err
+	}
+	if isNew {
+		return s.projectRepository.CreateProject(project)
+	}
+	return s.projectRepository.UpdateProject(project)
+}
+
+func (s ProjectMngmtSvc) GetAllProjects() ([]db.Project, error) {
+	projects, err := s.projectRepository.FetchProjects()
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		if project.Name == DefaultProject {
+			return projects, nil
+		}
+	}
+	return append([]db.Project{s.defaultProject()}, projects...), nil
+}
+
+// GetProjectByName falls back to an unlimited default project running in the worker namespace when the default
+// project wasn't explicitly configured
+func (s ProjectMngmtSvc) GetProjectByName(name string) (*db.Project, error) {
+	project, err := s.projectRepository.FetchProjectByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil && name == DefaultProject {
+		defaultProject := s.defaultProject()
+		return &defaultProject, nil
+	}
+	return project, nil
+}
+
+// DeleteProject only removes the project metadata, its namespace and the intermediate files it holds are kept
+func (s ProjectMngmtSvc) DeleteProject(name string) (bool, error) {
+	runningJobs, err := s.jobRepository.CountRunningJobsByProject(name)
+	if err != nil {
+		return false, err
+	}
+	if runningJobs != 0 {
+		return false, fmt.Errorf("%w: %s has %v running jobs", ErrProjectInUse, name, runningJobs)
+	}
+	return s.projectRepository.DeleteProject(name)
+}
+
+// CheckJobStartQuota checks the running jobs quota of a project and that the reducers of a job fit in its pod quota, the
+// number of mappers is only known once the input is split so the scheduler checks the peak pod count of the job then
+func (s ProjectMngmtSvc) CheckJobStartQuota(project db.Project, nReducers int) error {
+	if project.MaxRunningJobs > 0 {
+		runningJobs, err := s.jobRepository.CountRunningJobsByProject(project.Name)
+		if err != nil {
+			return err
+		}
+		if runningJobs >= project.MaxRunningJobs {
+			return fmt.Errorf("%w: %s already runs %v of its %v allowed jobs",
+				ErrQuotaExceeded, project.Name, runningJobs, project.MaxRunningJobs)
+		}
+	}
+	return s.CheckPodQuota(project, nReducers)
+}
+
+func (s ProjectMngmtSvc) CheckPodQuota(project db.Project, nPods int) error {
+	if project.MaxPods <= 0 {
+		return nil
+	}
+	pods, err := s.k8sClient.CoreV1().Pods(project.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: "app=worker",
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		s.logger.Error("Could not list the worker pods of project %s -> %v", project.Name, err)
+		return err
+	}
+	if len(pods.Items)+nPods > project.MaxPods {
+		return fmt.Errorf("%w: %s runs %v worker pods and can't start %v more out of its %v allowed pods",
+			ErrQuotaExceeded, project.Name, len(pods.Items), nPods, project.MaxPods)
+	}
+	return nil
+}
+
+func (s ProjectMngmtSvc) defaultProject() db.Project {
+	return db.Project{
+		Name:      DefaultProject,
+		Namespace: s.config.GetWorkerNS(),
+		Weight:    1,
+	}
+}
+
+// provisionNamespace creates the namespace of a project along with the volume and headless service used by the
+// workers and the role allowing the coordinator to manage them, existing resources are left untouched
+func (s ProjectMngmtSvc) provisionNamespace(namespace string) error {
+	ctx := context.Background()
+	labels := map[string]string{"app.kubernetes.io/managed-by": "apollo"}
+	storageClass := s.config.GetIntFilesStorageClass()
+	storageSize, err := resource.ParseQuantity(s.config.GetIntFilesStorageSize())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.k8sClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: labels},
+	}, metav1.CreateOptions{})
+	if err = ignoreAlreadyExists(err); err != nil {
+		return err
+	}
+
+	_, err = s.k8sClient.RbacV1().Roles(namespace).Create(ctx, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "coordinator-role", Namespace: namespace, Labels: labels},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods", "services", "secrets"},
+				Verbs:     []string{"get", "watch", "list", "create", "update", "patch", "delete", "deletecollection"},
+			},
+			{
+				APIGroups: []string{"batch"},
+				Resources: []string{"jobs"},
+				Verbs:     []string{"get", "watch", "list", "create", "delete", "deletecollection"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods/log"},
+				Verbs:     []string{"get"},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err = ignoreAlreadyExists(err); err != nil {
+		return err
+	}
+
+	_, err = s.k8sClient.RbacV1().RoleBindings(namespace).Create(ctx, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "coordinator-role-binding", Namespace: namespace, Labels: labels},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     "coordinator-role",
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      s.config.GetCoordinatorSA(),
+				Namespace: s.config.GetCoordinatorNS(),
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err = ignoreAlreadyExists(err); err != nil {
+		return err
+	}
+
+	_, err = s.k8sClient.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: IntermediateFilesClaim, Namespace: namespace, Labels: labels},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: storageSize},
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err = ignoreAlreadyExists(err); err != nil {
+		return err
+	}
+
+	_, err = s.k8sClient.CoreV1().Services(namespace).Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: namespace, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector:  map[string]string{"app": "worker"},
+			ClusterIP: corev1.ClusterIPNone,
+		},
+	}, metav1.CreateOptions{})
+	return ignoreAlreadyExists(err)
+}
+
+func ignoreAlreadyExists(err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func NewProjectManager(
+	projectRepository db.ProjectRepository,
+	jobRepository db.JobRepository,
+	k8sClient kubernetes.Interface) ProjectManager {
+	return &ProjectMngmtSvc{
+		projectRepository: projectRepository,
+		jobRepository:     jobRepository,
+		k8sClient:         k8sClient,
+		config:            GetConfig(),
+		logger:            utils.GetLogger(),
+	}
+}
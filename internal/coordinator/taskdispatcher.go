@@ -0,0 +1,426 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+const PollTimeout = 30 * time.Second
+
+var (
+	ErrUnknownWorker    = errors.New("worker isn't registered")
+	ErrTaskNotAssigned  = errors.New("no worker picked the task up")
+	ErrDispatchCanceled = errors.New("task dispatch was canceled")
+)
+
+// TaskDispatcher hands the tasks of the running jobs to the workers pulling them
+type TaskDispatcher interface {
+	Dispatch(jobId string, task *proto.Task) (*proto.TaskStatusInfo, error)
+	// AdmitJob lets a job that is being scheduled be canceled until it is forgotten
+	AdmitJob(jobId string)
+	CancelJob(jobId string)
+	// ForgetJob drops the cancellation of a job once it isn't scheduled anymore
+	ForgetJob(jobId string)
+	RegisterWorker(workerId, jobId string, taskType int64) error
+	// WorkerJob returns the job a registered worker belongs to
+	WorkerJob(workerId string) (string, error)
+	RecordHeartbeat(workerId string, taskId *string, progress *proto.TaskProgress) error
+	PollTask(ctx context.Context, workerId string, completionIndex *int64) (*proto.Task, error)
+	ReportTaskStatus(workerId, taskId string, statusInfo *proto.TaskStatusInfo, reason *string) error
+	ExpireWorkers(silentSince time.Time)
+	Start()
+}
+
+type dispatchedTask struct {
+	jobId    string
+	task     *proto.Task
+	workerId string
+	updates  chan *proto.TaskStatusReport
+	assigned chan string
+	canceled chan struct{}
+	done     chan struct{}
+	// Set once the worker that pulled the task reported about it, the assignment is provisional until then
+	acked bool
+}
+
+type registeredWorker struct {
+	jobId    string
+	taskType int64
+	taskId   string
+	lastSeen time.Time
+}
+
+type TaskDispatchingSvc struct {
+	taskRepository db.TaskRepository
+	config         *Config
+	logger         *utils.Logger
+	lock           sync.Mutex
+	pending        []*dispatchedTask
+	assigned       map[string]*dispatchedTask
+	workers        map[string]*registeredWorker
+	admittedJobs   map[string]struct{}
+	canceledJobs   map[string]struct{}
+	// Closed and replaced every time a task is queued to wake the long polling workers up
+	available chan struct{}
+}
+
+// Dispatch queues a task until a worker of its job pulls it and waits for the final status that worker reports
+func (s *TaskDispatchingSvc) Dispatch(jobId string, task *proto.Task) (*proto.TaskStatusInfo, error) {
+	dispatched := &dispatchedTask{
+		jobId:    jobId,
+		task:     task,
+		updates:  make(chan *proto.TaskStatusReport, 4),
+		assigned: make(chan string, 1),
+		canceled: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	defer close(dispatched.done)
+	s.lock.Lock()
+	if _, canceled := s.canceledJobs[jobId]; canceled {
+		s.lock.Unlock()
+		return nil, ErrDispatchCanceled
+	}
+	s.pending = append(s.pending, dispatched)
+	close(s.available)
+	s.available = make(chan struct{})
+	s.lock.Unlock()
+
+	assignmentTimer := time.NewTimer(s.config.GetPodReadyTimeout())
+	defer assignmentTimer.Stop()
+	ackTimer := time.NewTimer(s.config.GetTaskAckTimeout())
+	ackTimer.Stop()
+	defer ackTimer.Stop()
+	for {
+		select {
+		case <-assignmentTimer.C:
+			if s.unqueue(dispatched) {
+				return nil, fmt.Errorf("%w within %v", ErrTaskNotAssigned, s.config.GetPodReadyTimeout())
+			}
+		case workerId := <-dispatched.assigned:
+			assignmentTimer.Stop()
+			if err := s.recordAssignment(task.Id, workerId); err != nil {
+				return nil, err
+			}
+			ackTimer.Reset(s.config.GetTaskAckTimeout())
+		case <-ackTimer.C:
+			if s.requeue(dispatched) {
+				assignmentTimer.Reset(s.config.GetPodReadyTimeout())
+			}
+		case report := <-dispatched.updates:
+			// The assignment is recorded first when the worker reported its status right after pulling the task
+			select {
+			case workerId := <-dispatched.assigned:
+				if err := s.recordAssignment(task.Id, workerId); err != nil {
+					return nil, err
+				}
+			default:
+			}
+			storeTaskOutcome(s.taskRepository, s.logger, task.Id, report.GetStatusInfo())
+			taskStatus := report.GetStatusInfo().GetTaskStatus()
+			if err := s.taskRepository.UpdateTaskStatusByID(task.Id, taskStatus); err != nil {
+				return nil, err
+			}
+			if taskStatus == "completed" {
+				return report.GetStatusInfo(), nil
+			}
+			if taskStatus == "failed" {
+				reason := "the worker reported a failure"
+				if report.Error != nil {
+					reason = report.GetError()
+				}
+				return report.GetStatusInfo(), taskFailure(report.GetStatusInfo(), reason)
+			}
+		case <-dispatched.canceled:
+			s.unqueue(dispatched)
+			return nil, ErrDispatchCanceled
+		}
+	}
+}
+
+func (s *TaskDispatchingSvc) AdmitJob(jobId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.admittedJobs[jobId] = struct{}{}
+}
+
+// CancelJob stops waiting for the tasks of a job, including the ones dispatched later on, the workers still running
+// them are left to be deleted. The cancellation of a job that was already forgotten isn't recorded since nothing would
+// drop it
+func (s *TaskDispatchingSvc) CancelJob(jobId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, canceled := s.canceledJobs[jobId]; canceled {
+		return
+	}
+	if _, admitted := s.admittedJobs[jobId]; !admitted && !s.hasTasks(jobId) {
+		return
+	}
+	s.canceledJobs[jobId] = struct{}{}
+	for _, dispatched := range s.pending {
+		if dispatched.jobId == jobId {
+			close(dispatched.canceled)
+		}
+	}
+	for taskId, dispatched := range s.assigned {
+		if dispatched.jobId == jobId {
+			close(dispatched.canceled)
+			delete(s.assigned, taskId)
+		}
+	}
+}
+
+func (s *TaskDispatchingSvc) ForgetJob(jobId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.admittedJobs, jobId)
+	delete(s.canceledJobs, jobId)
+}
+
+func (s *TaskDispatchingSvc) RegisterWorker(workerId, jobId string, taskType int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.workers[workerId] = &registeredWorker{
+		jobId:    jobId,
+		taskType: taskType,
+		lastSeen: time.Now(),
+	}
+	s.logger.Info("Worker %s of job %s registered", workerId, jobId)
+	return nil
+}
+
+func (s *TaskDispatchingSvc) WorkerJob(workerId string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	worker, exists := s.workers[workerId]
+	if !exists {
+		return "", ErrUnknownWorker
+	}
+	return worker.jobId, nil
+}
+
+// RecordHeartbeat keeps a worker alive and stores the progress of the task it runs, the task a worker acknowledged
+// but doesn't name in its heartbeats anymore is failed as lost
+func (s *TaskDispatchingSvc) RecordHeartbeat(workerId string, taskId *string, progress *proto.TaskProgress) error {
+	s.lock.Lock()
+	worker, exists := s.workers[workerId]
+	if !exists {
+		s.lock.Unlock()
+		return ErrUnknownWorker
+	}
+	worker.lastSeen = time.Now()
+	runningTask := worker.taskId
+	if dispatched, exists := s.assigned[runningTask]; exists {
+		if taskId != nil && *taskId == runningTask {
+			// The heartbeats naming the task prove that the worker got it as well as its reports
+			dispatched.acked = true
+		} else if dispatched.acked {
+			// The worker moved on from the task it acknowledged without its final report getting through
+			reason := fmt.Errorf("%w: worker %s doesn't run it anymore", ErrTaskLost, workerId).Error()
+			s.loseTask(workerId, worker, reason)
+			runningTask = ""
+		}
+	}
+	s.lock.Unlock()
+	if taskId == nil || *taskId != runningTask || progress == nil {
+		return nil
+	}
+	return s.taskRepository.UpdateTaskProgressByID(runningTask, taskProgressFromProto(progress))
+}
+
+// PollTask waits until a task matching the worker is available or the long poll expires, nil is returned in the
+// latter case. The task is handed out again when the worker doesn't acknowledge it within the task ack timeout
+func (s *TaskDispatchingSvc) PollTask(ctx context.Context, workerId string, completionIndex *int64) (*proto.Task, error) {
+	ctx, cancel := context.WithTimeout(ctx, PollTimeout)
+	defer cancel()
+	for {
+		s.lock.Lock()
+		worker, exists := s.workers[workerId]
+		if !exists {
+			s.lock.Unlock()
+			return nil, ErrUnknownWorker
+		}
+		worker.lastSeen = time.Now()
+		for idx, dispatched := range s.pending {
+			if !s.matches(worker, dispatched, completionIndex) {
+				continue
+			}
+			s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+			s.assigned[dispatched.task.Id] = dispatched
+			dispatched.workerId = workerId
+			worker.taskId = dispatched.task.Id
+			dispatched.assigned <- workerId
+			s.lock.Unlock()
+			return dispatched.task, nil
+		}
+		available := s.available
+		s.lock.Unlock()
+
+		select {
+		case <-available:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+func (s *TaskDispatchingSvc) ReportTaskStatus(workerId, taskId string, statusInfo *proto.TaskStatusInfo, reason *string) error {
+	s.lock.Lock()
+	dispatched, exists := s.assigned[taskId]
+	if !exists || dispatched.workerId != workerId {
+		s.lock.Unlock()
+		return fmt.Errorf("task %s isn't assigned to worker %s", taskId, workerId)
+	}
+	dispatched.acked = true
+	taskStatus := statusInfo.GetTaskStatus()
+	if taskStatus == "completed" || taskStatus == "failed" {
+		delete(s.assigned, taskId)
+		if worker, exists := s.workers[workerId]; exists {
+			worker.taskId = ""
+		}
+	}
+	s.lock.Unlock()
+
+	report := &proto.TaskStatusReport{
+		WorkerId:   workerId,
+		TaskId:     taskId,
+		StatusInfo: statusInfo,
+		Error:      reason,
+	}
+	select {
+	case dispatched.updates <- report:
+	case <-dispatched.done:
+	}
+	return nil
+}
+
+// ExpireWorkers forgets about the workers that stayed silent since the given time and fails the tasks they were
+// running, a worker that comes back has to register again
+func (s *TaskDispatchingSvc) ExpireWorkers(silentSince time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for workerId, worker := range s.workers {
+		if !worker.lastSeen.Before(silentSince) {
+			continue
+		}
+		delete(s.workers, workerId)
+		if _, exists := s.assigned[worker.taskId]; !exists {
+			s.logger.Warn("Idle worker %s of job %s was lost", workerId, worker.jobId)
+			continue
+		}
+		reason := fmt.Errorf("%w: no heartbeat was received from worker %s since %v", ErrTaskLost, workerId,
+			worker.lastSeen.Format(time.RFC3339)).Error()
+		s.loseTask(workerId, worker, reason)
+	}
+}
+
+// loseTask fails the task assigned to a worker on its behalf, it must be called with the lock held
+func (s *TaskDispatchingSvc) loseTask(workerId string, worker *registeredWorker, reason string) {
+	dispatched := s.assigned[worker.taskId]
+	delete(s.assigned, worker.taskId)
+	s.logger.Warn("Task %s was lost -> %s", worker.taskId, reason)
+	report := &proto.TaskStatusReport{
+		WorkerId:   workerId,
+		TaskId:     worker.taskId,
+		StatusInfo: &proto.TaskStatusInfo{TaskStatus: "failed"},
+		Error:      &reason,
+	}
+	worker.taskId = ""
+	go func() {
+		select {
+		case dispatched.updates <- report:
+		case <-dispatched.done:
+		}
+	}()
+}
+
+func (s *TaskDispatchingSvc) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.GetHeartbeatInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			s.ExpireWorkers(time.Now().Add(-s.config.GetLostTaskTimeout()))
+		}
+	}()
+}
+
+func (s *TaskDispatchingSvc) recordAssignment(taskId, workerId string) error {
+	s.logger.Info("Task %s was assigned to worker %s", taskId, workerId)
+	return s.taskRepository.UpdateTaskPodNameByID(taskId, workerId)
+}
+
+func (s *TaskDispatchingSvc) matches(worker *registeredWorker, dispatched *dispatchedTask, completionIndex *int64) bool {
+	if worker.taskId != "" || dispatched.jobId != worker.jobId || dispatched.task.GetType() != worker.taskType {
+		return false
+	}
+	return completionIndex == nil || strings.HasSuffix(dispatched.task.Id, fmt.Sprintf("-%v", *completionIndex))
+}
+
+// requeue hands a task out again when the worker that pulled it never acknowledged it, false is returned when the
+// worker did or when the task isn't assigned anymore
+func (s *TaskDispatchingSvc) requeue(dispatched *dispatchedTask) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if dispatched.acked || s.assigned[dispatched.task.Id] != dispatched {
+		return false
+	}
+	s.logger.Warn("Worker %s didn't acknowledge task %s within %v, it is queued again", dispatched.workerId,
+		dispatched.task.Id, s.config.GetTaskAckTimeout())
+	delete(s.assigned, dispatched.task.Id)
+	if worker, exists := s.workers[dispatched.workerId]; exists && worker.taskId == dispatched.task.Id {
+		worker.taskId = ""
+	}
+	dispatched.workerId = ""
+	s.pending = append(s.pending, dispatched)
+	close(s.available)
+	s.available = make(chan struct{})
+	return true
+}
+
+// hasTasks tells whether tasks of a job are queued or assigned, it must be called with the lock held
+func (s *TaskDispatchingSvc) hasTasks(jobId string) bool {
+	for _, dispatched := range s.pending {
+		if dispatched.jobId == jobId {
+			return true
+		}
+	}
+	for _, dispatched := range s.assigned {
+		if dispatched.jobId == jobId {
+			return true
+		}
+	}
+	return false
+}
+
+// unqueue removes a task that no worker picked up yet, false is returned when a worker got it in the meantime
+func (s *TaskDispatchingSvc) unqueue(dispatched *dispatchedTask) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for idx, pending := range s.pending {
+		if pending == dispatched {
+			s.pending = append(s.pending[:idx], s.pending[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func NewTaskDispatcher(taskRepository db.TaskRepository) TaskDispatcher {
+	return &TaskDispatchingSvc{
+		taskRepository: taskRepository,
+		config:         GetConfig(),
+		logger:         utils.GetLogger(),
+		assigned:       map[string]*dispatchedTask{},
+		workers:        map[string]*registeredWorker{},
+		admittedJobs:   map[string]struct{}{},
+		canceledJobs:   map[string]struct{}{},
+		available:      make(chan struct{}),
+	}
+}
@@ -0,0 +1,213 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WorkerCollector garbage collects the worker pods, Indexed Jobs and services of the jobs that are over
+type WorkerCollector interface {
+	CollectJob(job db.Job, status string)
+	DeleteJobWorkers(job db.Job) error
+	// DeletePhaseWorkers deletes the pods of a single worker type of a running job
+	DeletePhaseWorkers(job db.Job, wType string) error
+	Sweep()
+	Start()
+}
+
+type WorkerCollectionSvc struct {
+	k8sClient      kubernetes.Interface
+	projectManager ProjectManager
+	jobRepository  db.JobRepository
+	config         *Config
+	logger         *utils.Logger
+}
+
+// CollectJob deletes the workers of a finished job, the ones of a failed job are kept for the configured retention
+func (s WorkerCollectionSvc) CollectJob(job db.Job, status string) {
+	retention := s.retention(status)
+	if retention == 0 {
+		s.DeleteJobWorkers(job)
+		return
+	}
+	s.logger.Info("Workers of failed job %s are kept for %v", job.Id, retention)
+	time.AfterFunc(retention, func() {
+		s.DeleteJobWorkers(job)
+	})
+}
+
+func (s WorkerCollectionSvc) DeleteJobWorkers(job db.Job) error {
+	project, err := s.projectManager.GetProjectByName(job.Project)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
+	}
+	return s.deleteWorkers(project.Namespace, job.Id)
+}
+
+// DeletePhaseWorkers frees the cluster resources held by the workers of a phase that is over, their services are
+// left to the collection of the job
+func (s WorkerCollectionSvc) DeletePhaseWorkers(job db.Job, wType string) error {
+	project, err := s.projectManager.GetProjectByName(job.Project)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
+	}
+	listOptions := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=worker,job=%s,type=%s", job.Id, wType),
+	}
+	if err := s.deleteWorkerPods(project.Namespace, listOptions); err != nil {
+		s.logger.Error("Could not delete job %s %s workers -> %v", job.Id, wType, err)
+		return err
+	}
+	s.logger.Info("The %s workers of job %s were deleted", wType, job.Id)
+	return nil
+}
+
+// Sweep deletes the workers left behind in the project namespaces by the jobs that no longer exist or that ended
+// longer than their retention ago, for instance because the coordinator restarted before collecting them
+func (s WorkerCollectionSvc) Sweep() {
+	projects, err := s.projectManager.GetAllProjects()
+	if err != nil {
+		s.logger.Error("Could not fetch the projects to sweep -> %v", err)
+		return
+	}
+	for _, project := range projects {
+		jobIds, err := s.listWorkerJobs(project.Namespace)
+		if err != nil {
+			s.logger.Error("Could not list the workers of project %s -> %v", project.Name, err)
+			continue
+		}
+		for jobId := range jobIds {
+			job, err := s.jobRepository.FetchJobByID(jobId)
+			if err != nil {
+				continue
+			}
+			if job != nil && !s.isExpired(*job) {
+				continue
+			}
+			s.logger.Info("Sweeping the orphan workers of job %s in namespace %s", jobId, project.Namespace)
+			s.deleteWorkers(project.Namespace, jobId)
+		}
+	}
+}
+
+func (s WorkerCollectionSvc) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.GetWorkerSweepInterval())
+		defer ticker.Stop()
+		for {
+			s.Sweep()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s WorkerCollectionSvc) retention(status string) time.Duration {
+	if status == db.JobFailed {
+		return s.config.GetFailedJobsRetention()
+	}
+	return 0
+}
+
+func (s WorkerCollectionSvc) isExpired(job db.Job) bool {
+	if job.EndTime == nil {
+		return false
+	}
+	return time.Since(time.Unix(*job.EndTime, 0)) >= s.retention(job.Status)
+}
+
+func (s WorkerCollectionSvc) listWorkerJobs(namespace string) (map[string]struct{}, error) {
+	ctx := context.Background()
+	listOptions := metav1.ListOptions{LabelSelector: "app=worker"}
+	jobIds := map[string]struct{}{}
+	pods, err := s.k8sClient.CoreV1().Pods(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if jobId, exists := pod.Labels["job"]; exists {
+			jobIds[jobId] = struct{}{}
+		}
+	}
+	services, err := s.k8sClient.CoreV1().Services(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		if jobId, exists := service.Labels["job"]; exists {
+			jobIds[jobId] = struct{}{}
+		}
+	}
+	workerJobs, err := s.k8sClient.BatchV1().Jobs(namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, workerJob := range workerJobs.Items {
+		if jobId, exists := workerJob.Labels["job"]; exists {
+			jobIds[jobId] = struct{}{}
+		}
+	}
+	return jobIds, nil
+}
+
+func (s WorkerCollectionSvc) deleteWorkers(namespace, jobId string) error {
+	ctx := context.Background()
+	listOptions := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=worker,job=%s", jobId),
+	}
+	if err := s.deleteWorkerPods(namespace, listOptions); err != nil {
+		s.logger.Error("Could not delete job %s workers -> %v", jobId, err)
+		return err
+	}
+	services, err := s.k8sClient.CoreV1().Services(namespace).List(ctx, listOptions)
+	if err != nil {
+		s.logger.Error("Could not list job %s services -> %v", jobId, err)
+		return err
+	}
+	for _, service := range services.Items {
+		err := s.k8sClient.CoreV1().Services(namespace).Delete(ctx, service.Name, metav1.DeleteOptions{})
+		if err != nil {
+			s.logger.Error("Could not delete job %s service %s -> %v", jobId, service.Name, err)
+			return err
+		}
+	}
+	s.logger.Info("Workers of job %s were deleted", jobId)
+	return nil
+}
+
+func NewWorkerCollector(
+	k8sClient kubernetes.Interface,
+	projectManager ProjectManager,
+	jobRepository db.JobRepository) WorkerCollector {
+	return &WorkerCollectionSvc{
+		k8sClient:      k8sClient,
+		projectManager: projectManager,
+		jobRepository:  jobRepository,
+		config:         GetConfig(),
+		logger:         utils.GetLogger(),
+	}
+}
+
+func (s WorkerCollectionSvc) deleteWorkerPods(namespace string, listOptions metav1.ListOptions) error {
+	ctx := context.Background()
+	// The Indexed Jobs go first so that they don't recreate the pods deleted right after
+	propagationPolicy := metav1.DeletePropagationBackground
+	err := s.k8sClient.BatchV1().Jobs(namespace).DeleteCollection(ctx, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	}, listOptions)
+	if err != nil {
+		return err
+	}
+	return s.k8sClient.CoreV1().Pods(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, listOptions)
+}
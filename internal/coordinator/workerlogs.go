@@ -0,0 +1,154 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogsFolder is the folder of the job output holding the archived logs of its workers
+const LogsFolder = "_logs"
+
+var (
+	ErrTaskNotFound   = errors.New("task can't be found")
+	ErrTaskPodUnknown = errors.New("task wasn't given a worker pod yet")
+)
+
+type PodLogOptions struct {
+	Follow    bool
+	TailLines *int64
+}
+
+// WorkerLogs reads the logs of the worker pods through the Kubernetes API
+type WorkerLogs interface {
+	StreamTaskLogs(ctx context.Context, jobId, taskId string, opts PodLogOptions) (io.ReadCloser, error)
+	ArchiveJobLogs(job db.Job, creds coreio.Credentials) error
+	// ArchivePhaseLogs only archives the logs of the workers of a type, before they are deleted with their phase
+	ArchivePhaseLogs(job db.Job, creds coreio.Credentials, wType string) error
+}
+
+type WorkerLogSvc struct {
+	k8sClient      kubernetes.Interface
+	projectManager ProjectManager
+	jobRepository  db.JobRepository
+	taskRepository db.TaskRepository
+	config         *Config
+	logger         *utils.Logger
+}
+
+// StreamTaskLogs returns the logs of the pod running a task, or that ran it last when the task was retried
+func (s WorkerLogSvc) StreamTaskLogs(ctx context.Context, jobId, taskId string, opts PodLogOptions) (io.ReadCloser, error) {
+	job, err := s.jobRepository.FetchJobByID(jobId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s in job %s", ErrTaskNotFound, taskId, jobId)
+	}
+	tasks, err := s.taskRepository.FetchTasksByJobID(jobId)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.Id != taskId {
+			continue
+		}
+		if task.PodName == nil {
+			return nil, fmt.Errorf("%w: %s", ErrTaskPodUnknown, taskId)
+		}
+		namespace, err := s.namespace(*job)
+		if err != nil {
+			return nil, err
+		}
+		return s.k8sClient.CoreV1().Pods(namespace).GetLogs(*task.PodName, &corev1.PodLogOptions{
+			Container: "worker",
+			Follow:    opts.Follow,
+			TailLines: opts.TailLines,
+		}).Stream(ctx)
+	}
+	return nil, fmt.Errorf("%w: %s in job %s", ErrTaskNotFound, taskId, jobId)
+}
+
+// ArchiveJobLogs copies the logs of the worker pods of a job next to its output, it has to run before the workers are
+// collected and goes on with the other pods when the logs of one of them can't be archived
+func (s WorkerLogSvc) ArchiveJobLogs(job db.Job, creds coreio.Credentials) error {
+	return s.archiveLogs(job, creds, "")
+}
+
+func (s WorkerLogSvc) ArchivePhaseLogs(job db.Job, creds coreio.Credentials, wType string) error {
+	return s.archiveLogs(job, creds, wType)
+}
+
+// archiveLogs copies the logs of the workers of a type, or of all of them when it is empty, the pods that are already
+// gone were deleted with their phase once their logs were archived
+func (s WorkerLogSvc) archiveLogs(job db.Job, creds coreio.Credentials, wType string) error {
+	namespace, err := s.namespace(job)
+	if err != nil {
+		return err
+	}
+	tasks, err := s.taskRepository.FetchTasksByJobID(job.Id)
+	if err != nil {
+		return err
+	}
+	s3Registrar, err := newOutputS3Registrar(job.OutputLocation, creds, s.config.IsInDevMode())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, task := range tasks {
+		if task.PodName == nil || (wType != "" && task.Type != wType) {
+			continue
+		}
+		logs, err := s.k8sClient.CoreV1().Pods(namespace).GetLogs(*task.PodName, &corev1.PodLogOptions{
+			Container: "worker",
+		}).Do(context.Background()).Raw()
+		if apierrors.IsNotFound(err) {
+			s.logger.Trace("Task %s pod %s is gone, its logs aren't archived again", task.Id, *task.PodName)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not read task %s pod %s logs -> %w", task.Id, *task.PodName, err))
+			continue
+		}
+		path := fmt.Sprintf("/reducers/%v/%v/%v.log", job.Id, LogsFolder, task.Id)
+		if err := s3Registrar.WriteFile(path, logs); err != nil {
+			errs = append(errs, fmt.Errorf("could not archive task %s logs to %s -> %w", task.Id, path, err))
+		}
+	}
+	s.logger.Info("Worker logs of job %s were archived", job.Id)
+	return errors.Join(errs...)
+}
+
+func (s WorkerLogSvc) namespace(job db.Job) (string, error) {
+	project, err := s.projectManager.GetProjectByName(job.Project)
+	if err != nil {
+		return "", err
+	}
+	if project == nil {
+		return "", fmt.Errorf("project %s of job %s can't be found", job.Project, job.Id)
+	}
+	return project.Namespace, nil
+}
+
+func NewWorkerLogs(
+	k8sClient kubernetes.Interface,
+	projectManager ProjectManager,
+	jobRepository db.JobRepository,
+	taskRepository db.TaskRepository) WorkerLogs {
+	return &WorkerLogSvc{
+		k8sClient:      k8sClient,
+		projectManager: projectManager,
+		jobRepository:  jobRepository,
+		taskRepository: taskRepository,
+		config:         GetConfig(),
+		logger:         utils.GetLogger(),
+	}
+}
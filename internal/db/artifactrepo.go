@@ -8,7 +8,7 @@ import (
 )
 
 type ArtifactRepository interface {
-	CreateArtifact(name, artifactType, hash string, size int64) (Artifact, error)
+	CreateArtifact(name, artifactType, hash, owner, project string, size int64) (Artifact, error)
 	FetchArtifacts() ([]Artifact, error)
 	FetchArficatByName(name string) (*Artifact, error)
 	DeleteArtifact(name string) (bool, error)
@@ -20,24 +20,26 @@ type SQLiteArtifactRepository struct {
 	logger *utils.Logger
 }
 
-func (r SQLiteArtifactRepository) CreateArtifact(name, artifactType, hash string, size int64) (Artifact, error) {
-	query := "INSERT INTO artifact VALUES (?, ?, ?, ?);"
+func (r SQLiteArtifactRepository) CreateArtifact(name, artifactType, hash, owner, project string, size int64) (Artifact, error) {
+	query := "INSERT INTO artifact (name, type, size, hash, owner, project) VALUES (?, ?, ?, ?, ?, ?);"
 	r.logger.Trace(query)
-	_, err := r.db.Exec(query, name, artifactType, size, hash)
+	_, err := r.db.Exec(query, name, artifactType, size, hash, owner, project)
 	if err != nil {
 		r.logger.Error(err.Error())
 		return Artifact{}, err
 	}
 	return Artifact{
-		Name: name,
-		Type: artifactType,
-		Size: size,
-		Hash: hash,
+		Name:    name,
+		Type:    artifactType,
+		Size:    size,
+		Hash:    hash,
+		Owner:   owner,
+		Project: project,
 	}, nil
 }
 
 func (r SQLiteArtifactRepository) FetchArtifacts() ([]Artifact, error) {
-	query := "SELECT name, type, size, hash FROM artifact;"
+	query := "SELECT name, type, size, hash, owner, project FROM artifact;"
 	r.logger.Trace(query)
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -48,22 +50,26 @@ func (r SQLiteArtifactRepository) FetchArtifacts() ([]Artifact, error) {
 	artifacts := []Artifact{}
 	for rows.Next() {
 		artifact := Artifact{}
-		err := rows.Scan(&artifact.Name, &artifact.Type, &artifact.Size, &artifact.Hash)
+		var owner sql.NullString
+		err := rows.Scan(&artifact.Name, &artifact.Type, &artifact.Size, &artifact.Hash, &owner, &artifact.Project)
 		if err != nil {
 			r.logger.Error(err.Error())
 			return []Artifact{}, err
 		}
+		artifact.Owner = owner.String
 		artifacts = append(artifacts, artifact)
 	}
 	return artifacts, nil
 }
 
 func (r SQLiteArtifactRepository) FetchArficatByName(name string) (*Artifact, error) {
-	query := "SELECT name, type, size, hash FROM artifact WHERE name = ?;"
+	query := "SELECT name, type, size, hash, owner, project FROM artifact WHERE name = ?;"
 	r.logger.Trace(query)
 	row := r.db.QueryRow(query, name)
 	artifact := Artifact{}
-	err := row.Scan(&artifact.Name, &artifact.Type, &artifact.Size, &artifact.Hash)
+	var owner sql.NullString
+	err := row.Scan(&artifact.Name, &artifact.Type, &artifact.Size, &artifact.Hash, &owner, &artifact.Project)
+	artifact.Owner = owner.String
 
 	if errors.Is(err, sql.ErrNoRows) {
 		r.logger.Warn("No artifact with name %s was found", name)
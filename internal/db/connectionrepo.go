@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+type ConnectionRepository interface {
+	CreateConnection(connection Connection) (Connection, error)
+	FetchConnections() ([]Connection, error)
+	FetchConnectionByName(name string) (*Connection, error)
+	UpdateConnection(connection Connection) (Connection, error)
+	DeleteConnection(name string) (bool, error)
+}
+
+type SQLiteConnectionRepository struct {
+	db     *sql.DB
+	logger *utils.Logger
+}
+
+func (r SQLiteConnectionRepository) CreateConnection(connection Connection) (Connection, error) {
+	query := `INSERT INTO connection (name, endpoint, use_ssl, region, force_path_style,
+	ca_bundle, secret_ref, credentials) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query,
+		connection.Name,
+		connection.Endpoint,
+		connection.UseSSL,
+		connection.Region,
+		connection.ForcePathStyle,
+		connection.CABundle,
+		connection.SecretRef,
+		connection.Credentials)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Connection{}, err
+	}
+	return connection, nil
+}
+
+func (r SQLiteConnectionRepository) FetchConnections() ([]Connection, error) {
+	query := `SELECT name, endpoint, use_ssl, region, force_path_style,
+	ca_bundle, secret_ref, credentials FROM connection;`
+	r.logger.Trace(query)
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return []Connection{}, err
+	}
+	defer rows.Close()
+	connections := []Connection{}
+	for rows.Next() {
+		connection, err := scanConnection(rows)
+		if err != nil {
+			r.logger.Error(err.Error())
+			return []Connection{}, err
+		}
+		connections = append(connections, connection)
+	}
+	return connections, nil
+}
+
+func (r SQLiteConnectionRepository) FetchConnectionByName(name string) (*Connection, error) {
+	query := `SELECT name, endpoint, use_ssl, region, force_path_style,
+	ca_bundle, secret_ref, credentials FROM connection WHERE name = ?;`
+	r.logger.Trace(query)
+	row := r.db.QueryRow(query, name)
+	connection, err := scanConnection(row)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("No connection with name %s was found", name)
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r SQLiteConnectionRepository) UpdateConnection(connection Connection) (Connection, error) {
+	query := `UPDATE connection SET endpoint = ?, use_ssl = ?, region = ?, force_path_style = ?,
+	ca_bundle = ?, secret_ref = ?, credentials = ? WHERE name = ?;`
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query,
+		connection.Endpoint,
+		connection.UseSSL,
+		connection.Region,
+		connection.ForcePathStyle,
+		connection.CABundle,
+		connection.SecretRef,
+		connection.Credentials,
+		connection.Name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Connection{}, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Connection{}, err
+	}
+	if count == 0 {
+		return Connection{}, sql.ErrNoRows
+	}
+	return connection, nil
+}
+
+func (r SQLiteConnectionRepository) DeleteConnection(name string) (bool, error) {
+	query := "DELETE FROM connection WHERE name = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	return count != 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConnection(row rowScanner) (Connection, error) {
+	connection := Connection{}
+	var region, caBundle sql.NullString
+	err := row.Scan(
+		&connection.Name,
+		&connection.Endpoint,
+		&connection.UseSSL,
+		&region,
+		&connection.ForcePathStyle,
+		&caBundle,
+		&connection.SecretRef,
+		&connection.Credentials)
+	connection.Region = region.String
+	connection.CABundle = caBundle.String
+	return connection, err
+}
+
+func NewSQLiteConnectionRepository(db *sql.DB) ConnectionRepository {
+	return &SQLiteConnectionRepository{
+		db:     db,
+		logger: utils.GetLogger(),
+	}
+}
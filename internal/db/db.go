@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +11,14 @@ import (
 	"github.com/Assifar-Karim/apollo/internal/utils"
 )
 
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobStopped   = "stopped"
+)
+
 type Job struct {
 	Id             string         `json:"id"`
 	NReducers      int            `json:"nReducers"`
@@ -17,6 +26,42 @@ type Job struct {
 	InputData      InputData      `json:"inputData"`
 	StartTime      int64          `json:"startTime"`
 	EndTime        *int64         `json:"endTime,omitempty"`
+	Owner          string         `json:"owner,omitempty"`
+	Project        string         `json:"project"`
+	Status         string         `json:"status"`
+	// Percentage of the job work that is done, only computed when a single job is fetched
+	Completion *float64 `json:"completion,omitempty"`
+	// Totals of the counters of the completed tasks
+	Counters map[string]int64 `json:"counters,omitempty"`
+}
+
+// QueueEntry holds everything needed to start a queued job once capacity frees up
+type QueueEntry struct {
+	JobId               string
+	Priority            int
+	EnqueueTime         int64
+	MapperName          string
+	ReducerName         string
+	SplitSize           *int64
+	PrefetchParallelism *int64
+	// JSON encoded worker pod settings
+	PodSettings []byte
+	// Name of the worker pod template ConfigMap, empty when the default template is used
+	PodTemplate string
+	// Number of program failures on the same record after which the map tasks skip it, nil when records aren't skipped
+	SkipBadRecordsAfter *int64
+	// Number of program invocations a task runs at once, nil when the workers derive it from their CPU allocation
+	ProgramConcurrency *int64
+	// Whether the worker logs are copied to the job output once the job ends
+	ArchiveLogs bool
+	// Storage credentials of the job, encrypted when a connections key is configured
+	Credentials []byte
+}
+
+// PodProfile is a named set of worker pod settings that jobs can refer to
+type PodProfile struct {
+	Name     string          `json:"name"`
+	Settings json.RawMessage `json:"settings"`
 }
 
 type Task struct {
@@ -29,14 +74,40 @@ type Task struct {
 	PodName   *string    `json:"podName,omitempty"`
 	StartTime int64      `json:"startTime"`
 	EndTime   *int64     `json:"endTime,omitempty"`
+	// Why the task failed, either the error reported by its worker or the problem its pod ran into
+	FailureReason *string `json:"failureReason,omitempty"`
+	// Latest progress reported by the worker running the task
+	Progress *TaskProgress `json:"progress,omitempty"`
+	// Counters reported by the worker once the task is over
+	Counters map[string]int64 `json:"counters,omitempty"`
+}
+
+type TaskProgress struct {
+	Phase            string          `json:"phase"`
+	BytesRead        int64           `json:"bytesRead"`
+	BytesTotal       int64           `json:"bytesTotal,omitempty"`
+	RecordsProcessed int64           `json:"recordsProcessed"`
+	PairsEmitted     map[int64]int64 `json:"pairsEmitted,omitempty"`
+	UpdateTime       int64           `json:"updateTime"`
+}
+
+// InvocationLog holds the output of a program invocation that failed while running a task
+type InvocationLog struct {
+	// Line number passed to a map program or sort order passed to a reduce program
+	Invocation int64  `json:"invocation"`
+	ExitCode   int64  `json:"exitCode"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
 }
 
 type InputData struct {
-	Id         int    `json:"id"`
-	Path       string `json:"path"`
-	Type       string `json:"type"`
-	SplitStart *int64 `json:"splitStart,omitempty"`
-	SplitEnd   *int64 `json:"splitEnd,omitempty"`
+	Id         int     `json:"id"`
+	Path       string  `json:"path"`
+	Type       string  `json:"type"`
+	SplitStart *int64  `json:"splitStart,omitempty"`
+	SplitEnd   *int64  `json:"splitEnd,omitempty"`
+	ETag       *string `json:"etag,omitempty"`
+	VersionId  *string `json:"versionId,omitempty"`
 }
 
 type OutputLocation struct {
@@ -45,10 +116,34 @@ type OutputLocation struct {
 }
 
 type Artifact struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Size int64  `json:"size"`
-	Hash string `json:"hash"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"`
+	Owner   string `json:"owner,omitempty"`
+	Project string `json:"project"`
+}
+
+// Project groups the jobs and artifacts of a tenant, its workers run in their own namespace.
+// A zero limit means that the project isn't limited, the weight sets its share of the worker pods.
+type Project struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	MaxPods        int    `json:"maxPods"`
+	MaxRunningJobs int    `json:"maxRunningJobs"`
+	Weight         int    `json:"weight"`
+}
+
+type Connection struct {
+	Name           string  `json:"name"`
+	Endpoint       string  `json:"endpoint"`
+	UseSSL         bool    `json:"useSSL"`
+	Region         string  `json:"region,omitempty"`
+	ForcePathStyle bool    `json:"forcePathStyle"`
+	CABundle       string  `json:"caBundle,omitempty"`
+	SecretRef      *string `json:"secretRef,omitempty"`
+	// Credentials are stored encrypted and never leave the coordinator
+	Credentials []byte `json:"-"`
 }
 
 func runInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
@@ -80,7 +175,7 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
 		return nil, err
 	}
 	// Setup DB tables
-	queries := make([]string, 5)
+	queries := make([]string, 9)
 
 	queries[0] = `CREATE TABLE IF NOT EXISTS output_location (
     location VARCHAR PRIMARY KEY NOT NULL,
@@ -91,7 +186,9 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
     path VARCHAR NOT NULL,
     type VARCHAR NOT NULL,
     split_start INTEGER,
-    split_end INTEGER);`
+    split_end INTEGER,
+    etag VARCHAR,
+    version_id VARCHAR);`
 
 	queries[2] = `CREATE TABLE IF NOT EXISTS job (
     id VARCHAR PRIMARY KEY NOT NULL,
@@ -100,6 +197,10 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
     input_id INTEGER NOT NULL,
     start_time DATETIME NOT NULL,
     end_time DATETIME,
+    owner VARCHAR,
+    project VARCHAR NOT NULL DEFAULT 'default',
+    status VARCHAR,
+    counters VARCHAR,
 	FOREIGN KEY(input_id) REFERENCES input_data(id),
 	FOREIGN KEY(output_path) REFERENCES output_location(location));`
 
@@ -107,7 +208,9 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
     name VARCHAR PRIMARY KEY NOT NULL,
     type VARCHAR NOT NULL DEFAULT executable,
     size INTEGER NOT NULL DEFAULT 0,
-	hash VARCHAR NOT NULL);`
+	hash VARCHAR NOT NULL,
+    owner VARCHAR,
+    project VARCHAR NOT NULL DEFAULT 'default');`
 
 	queries[4] = `CREATE TABLE IF NOT EXISTS task (
     id VARCHAR PRIMARY KEY NOT NULL,
@@ -119,10 +222,51 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
     pod_name VARCHAR,
     start_time DATETIME NOT NULL,
     end_time DATETIME,
+    failure_reason VARCHAR,
+    progress VARCHAR,
+    counters VARCHAR,
+    invocation_logs VARCHAR,
     FOREIGN KEY(job_id) REFERENCES job(id),
     FOREIGN KEY(input_data_id) REFERENCES input_data(id),
 	FOREIGN KEY(program_name) REFERENCES artifact(name));`
 
+	queries[5] = `CREATE TABLE IF NOT EXISTS connection (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    endpoint VARCHAR NOT NULL,
+    use_ssl BOOLEAN NOT NULL,
+    region VARCHAR,
+    force_path_style BOOLEAN NOT NULL DEFAULT FALSE,
+    ca_bundle VARCHAR,
+    secret_ref VARCHAR,
+    credentials BLOB);`
+
+	queries[6] = `CREATE TABLE IF NOT EXISTS project (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    namespace VARCHAR NOT NULL UNIQUE,
+    max_pods INTEGER NOT NULL DEFAULT 0,
+    max_running_jobs INTEGER NOT NULL DEFAULT 0,
+    weight INTEGER NOT NULL DEFAULT 1);`
+
+	queries[7] = `CREATE TABLE IF NOT EXISTS job_queue (
+    job_id VARCHAR PRIMARY KEY NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enqueue_time DATETIME NOT NULL,
+    mapper_name VARCHAR NOT NULL,
+    reducer_name VARCHAR NOT NULL,
+    split_size INTEGER,
+    prefetch_parallelism INTEGER,
+    credentials BLOB,
+    pod_settings VARCHAR,
+    pod_template VARCHAR NOT NULL DEFAULT '',
+    skip_bad_records_after INTEGER,
+    archive_logs BOOLEAN NOT NULL DEFAULT 0,
+    program_concurrency INTEGER,
+    FOREIGN KEY(job_id) REFERENCES job(id));`
+
+	queries[8] = `CREATE TABLE IF NOT EXISTS pod_profile (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    settings VARCHAR NOT NULL);`
+
 	for _, query := range queries {
 		logger.Trace(query)
 		_, err := db.Exec(query)
@@ -130,9 +274,66 @@ func New(driver, dbName string, devMode bool) (*sql.DB, error) {
 			return nil, err
 		}
 	}
+
+	// Columns added after the initial release are appended to the tables of existing databases
+	migrations := []columnMigration{
+		{table: "input_data", column: "etag", definition: "VARCHAR"},
+		{table: "input_data", column: "version_id", definition: "VARCHAR"},
+		{table: "job", column: "owner", definition: "VARCHAR"},
+		{table: "artifact", column: "owner", definition: "VARCHAR"},
+		{table: "job", column: "project", definition: "VARCHAR NOT NULL DEFAULT 'default'"},
+		{table: "artifact", column: "project", definition: "VARCHAR NOT NULL DEFAULT 'default'"},
+		{table: "job", column: "status", definition: "VARCHAR"},
+		{table: "project", column: "weight", definition: "INTEGER NOT NULL DEFAULT 1"},
+		{table: "job_queue", column: "pod_settings", definition: "VARCHAR"},
+		{table: "task", column: "failure_reason", definition: "VARCHAR"},
+		{table: "job_queue", column: "pod_template", definition: "VARCHAR NOT NULL DEFAULT ''"},
+		{table: "task", column: "progress", definition: "VARCHAR"},
+		{table: "task", column: "counters", definition: "VARCHAR"},
+		{table: "job", column: "counters", definition: "VARCHAR"},
+		{table: "job_queue", column: "skip_bad_records_after", definition: "INTEGER"},
+		{table: "task", column: "invocation_logs", definition: "VARCHAR"},
+		{table: "job_queue", column: "archive_logs", definition: "BOOLEAN NOT NULL DEFAULT 0"},
+		{table: "job_queue", column: "program_concurrency", definition: "INTEGER"},
+	}
+	for _, migration := range migrations {
+		if err := migration.apply(db); err != nil {
+			return nil, err
+		}
+	}
 	return db, err
 }
 
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+func (m columnMigration) apply(db *sql.DB) error {
+	logger := utils.GetLogger()
+	query := "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;"
+	logger.Trace(query)
+	var count int
+	if err := db.QueryRow(query, m.table, m.column).Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+	query = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", m.table, m.column, m.definition)
+	logger.Trace(query)
+	_, err := db.Exec(query)
+	return err
+}
+
+func (c Connection) GetURL() string {
+	if c.UseSSL {
+		return fmt.Sprintf("https://%s", c.Endpoint)
+	}
+	return fmt.Sprintf("http://%s", c.Endpoint)
+}
+
 func (t Task) GetType() (int64, error) {
 	taskType := strings.ToLower(t.Type)
 	if taskType == "mapper" {
@@ -2,16 +2,22 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 
 	"github.com/Assifar-Karim/apollo/internal/utils"
 )
 
 type JobRepository interface {
-	CreateJob(nReducers int, startTime int64, id, inputPath, inputType, outputPath string, useSSL bool) (Job, error)
+	CreateJob(nReducers int, startTime int64, id, inputPath, inputType, outputPath, owner, project string, useSSL bool) (Job, error)
 	FetchJobs() ([]Job, error)
 	FetchJobByID(id string) (*Job, error)
 	UpdateJobEndTimeByID(id string, endTs int64) error
+	UpdateJobStatusByID(id, status string) (bool, error)
+	FetchJobIDsByStatus(status string) ([]string, error)
+	CountJobsByStatus(status string) (int, error)
+	CountRunningJobsByProject(project string) (int, error)
+	UpdateJobCountersByID(id string, counters map[string]int64) error
 }
 
 type SQLiteJobRepository struct {
@@ -21,7 +27,7 @@ type SQLiteJobRepository struct {
 
 func (r *SQLiteJobRepository) CreateJob(
 	nReducers int, startTime int64,
-	id, inputPath, inputType, outputPath string,
+	id, inputPath, inputType, outputPath, owner, project string,
 	useSSL bool) (Job, error) {
 	inputDataID := 0
 	transactionLogic := func(tx *sql.Tx) error {
@@ -36,7 +42,7 @@ func (r *SQLiteJobRepository) CreateJob(
 			}
 		}
 
-		query = "INSERT INTO input_data VALUES (NULL, ?, ?, NULL, NULL);"
+		query = "INSERT INTO input_data (id, path, type) VALUES (NULL, ?, ?);"
 		r.logger.Trace(query)
 		res, err := tx.Exec(query, inputPath, inputType)
 		if err != nil {
@@ -48,9 +54,11 @@ func (r *SQLiteJobRepository) CreateJob(
 		if err != nil {
 			return err
 		}
-		query = "INSERT INTO job VALUES (?, ?, ?, ?, ?, NULL);"
+		// Jobs always start queued until the coordinator has the capacity to run them
+		query = `INSERT INTO job (id, n_reducers, output_path, input_id, start_time, owner, project, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
 		r.logger.Trace(query)
-		_, err = tx.Exec(query, id, nReducers, outputPath, inputId, startTime)
+		_, err = tx.Exec(query, id, nReducers, outputPath, inputId, startTime, owner, project, JobQueued)
 		return err
 	}
 
@@ -72,12 +80,15 @@ func (r *SQLiteJobRepository) CreateJob(
 			Type: inputType,
 		},
 		StartTime: startTime,
+		Owner:     owner,
+		Project:   project,
+		Status:    JobQueued,
 	}, nil
 }
 
 func (r *SQLiteJobRepository) FetchJobs() ([]Job, error) {
 	query := `SELECT j.id, j.n_reducers, o.location, o.use_ssl, i.id,
-	i.path, i.type, i.split_start, i.split_end, j.start_time, j.end_time FROM job j
+	i.path, i.type, i.split_start, i.split_end, j.start_time, j.end_time, j.owner, j.project, j.status, j.counters FROM job j
 	JOIN input_data i ON i.id = j.input_id
 	JOIN output_location o ON o.location = j.output_path;`
 
@@ -92,6 +103,7 @@ func (r *SQLiteJobRepository) FetchJobs() ([]Job, error) {
 		job := Job{}
 		inputData := InputData{}
 		outputLocation := OutputLocation{}
+		var owner, status, counters sql.NullString
 
 		err := rows.Scan(
 			&job.Id,
@@ -104,7 +116,11 @@ func (r *SQLiteJobRepository) FetchJobs() ([]Job, error) {
 			&inputData.SplitStart,
 			&inputData.SplitEnd,
 			&job.StartTime,
-			&job.EndTime)
+			&job.EndTime,
+			&owner,
+			&job.Project,
+			&status,
+			&counters)
 
 		if err != nil {
 			r.logger.Error(err.Error())
@@ -113,6 +129,12 @@ func (r *SQLiteJobRepository) FetchJobs() ([]Job, error) {
 
 		job.InputData = inputData
 		job.OutputLocation = outputLocation
+		job.Owner = owner.String
+		job.Status = getJobStatus(job, status)
+		if job.Counters, err = decodeCounters(counters); err != nil {
+			r.logger.Error(err.Error())
+			return []Job{}, err
+		}
 		jobs = append(jobs, job)
 	}
 	return jobs, nil
@@ -120,7 +142,7 @@ func (r *SQLiteJobRepository) FetchJobs() ([]Job, error) {
 
 func (r *SQLiteJobRepository) FetchJobByID(id string) (*Job, error) {
 	query := `SELECT j.id, j.n_reducers, o.location, o.use_ssl, i.id,
-	i.path, i.type, i.split_start, i.split_end, j.start_time, j.end_time FROM job j
+	i.path, i.type, i.split_start, i.split_end, j.start_time, j.end_time, j.owner, j.project, j.status, j.counters FROM job j
 	JOIN input_data i ON i.id = j.input_id
 	JOIN output_location o ON o.location = j.output_path
 	WHERE j.id = ?;`
@@ -131,6 +153,7 @@ func (r *SQLiteJobRepository) FetchJobByID(id string) (*Job, error) {
 	job := Job{}
 	inputData := InputData{}
 	outputLocation := OutputLocation{}
+	var owner, status, counters sql.NullString
 	err := row.Scan(
 		&job.Id,
 		&job.NReducers,
@@ -142,7 +165,11 @@ func (r *SQLiteJobRepository) FetchJobByID(id string) (*Job, error) {
 		&inputData.SplitStart,
 		&inputData.SplitEnd,
 		&job.StartTime,
-		&job.EndTime)
+		&job.EndTime,
+		&owner,
+		&job.Project,
+		&status,
+		&counters)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		r.logger.Warn("No job with id %s was found", id)
@@ -154,6 +181,12 @@ func (r *SQLiteJobRepository) FetchJobByID(id string) (*Job, error) {
 	}
 	job.InputData = inputData
 	job.OutputLocation = outputLocation
+	job.Owner = owner.String
+	job.Status = getJobStatus(job, status)
+	if job.Counters, err = decodeCounters(counters); err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
 	return &job, nil
 
 }
@@ -165,6 +198,101 @@ func (r *SQLiteJobRepository) UpdateJobEndTimeByID(id string, endTs int64) error
 	return err
 }
 
+// UpdateJobStatusByID never changes the status of a job that already reached a final state
+func (r *SQLiteJobRepository) UpdateJobStatusByID(id, status string) (bool, error) {
+	query := "UPDATE job SET status = ? WHERE id = ? AND status IN (?, ?);"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, status, id, JobQueued, JobRunning)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	return count != 0, nil
+}
+
+func (r *SQLiteJobRepository) FetchJobIDsByStatus(status string) ([]string, error) {
+	query := "SELECT id FROM job WHERE status = ?;"
+	r.logger.Trace(query)
+	rows, err := r.db.Query(query, status)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return []string{}, err
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			r.logger.Error(err.Error())
+			return []string{}, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *SQLiteJobRepository) CountJobsByStatus(status string) (int, error) {
+	query := "SELECT COUNT(*) FROM job WHERE status = ?;"
+	r.logger.Trace(query)
+	var count int
+	err := r.db.QueryRow(query, status).Scan(&count)
+	if err != nil {
+		r.logger.Error(err.Error())
+	}
+	return count, err
+}
+
+func (r *SQLiteJobRepository) CountRunningJobsByProject(project string) (int, error) {
+	query := "SELECT COUNT(*) FROM job WHERE project = ? AND status = ?;"
+	r.logger.Trace(query)
+	var count int
+	err := r.db.QueryRow(query, project, JobRunning).Scan(&count)
+	if err != nil {
+		r.logger.Error(err.Error())
+	}
+	return count, err
+}
+
+// getJobStatus derives the status of the jobs created before statuses were tracked, back then only the
+// successful and stopped jobs got an end time
+func (r *SQLiteJobRepository) UpdateJobCountersByID(id string, counters map[string]int64) error {
+	encodedCounters, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE job SET counters = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err = r.db.Exec(query, string(encodedCounters), id)
+	return err
+}
+
+// decodeCounters reads the JSON encoded counters of a job or a task, nil is returned when there are none
+func decodeCounters(counters sql.NullString) (map[string]int64, error) {
+	if !counters.Valid {
+		return nil, nil
+	}
+	decoded := map[string]int64{}
+	if err := json.Unmarshal([]byte(counters.String), &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func getJobStatus(job Job, status sql.NullString) string {
+	if status.Valid {
+		return status.String
+	}
+	if job.EndTime != nil {
+		return JobCompleted
+	}
+	return JobFailed
+}
+
 func NewSQLiteJobsRepository(db *sql.DB) JobRepository {
 	return &SQLiteJobRepository{
 		db:     db,
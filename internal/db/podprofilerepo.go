@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+type PodProfileRepository interface {
+	CreatePodProfile(profile PodProfile) (PodProfile, error)
+	FetchPodProfiles() ([]PodProfile, error)
+	FetchPodProfileByName(name string) (*PodProfile, error)
+	UpdatePodProfile(profile PodProfile) (PodProfile, error)
+	DeletePodProfile(name string) (bool, error)
+}
+
+type SQLitePodProfileRepository struct {
+	db     *sql.DB
+	logger *utils.Logger
+}
+
+func (r SQLitePodProfileRepository) CreatePodProfile(profile PodProfile) (PodProfile, error) {
+	query := "INSERT INTO pod_profile (name, settings) VALUES (?, ?);"
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query, profile.Name, string(profile.Settings))
+	if err != nil {
+		r.logger.Error(err.Error())
+		return PodProfile{}, err
+	}
+	return profile, nil
+}
+
+func (r SQLitePodProfileRepository) FetchPodProfiles() ([]PodProfile, error) {
+	query := "SELECT name, settings FROM pod_profile;"
+	r.logger.Trace(query)
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return []PodProfile{}, err
+	}
+	defer rows.Close()
+	profiles := []PodProfile{}
+	for rows.Next() {
+		profile := PodProfile{}
+		var settings string
+		if err := rows.Scan(&profile.Name, &settings); err != nil {
+			r.logger.Error(err.Error())
+			return []PodProfile{}, err
+		}
+		profile.Settings = []byte(settings)
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (r SQLitePodProfileRepository) FetchPodProfileByName(name string) (*PodProfile, error) {
+	query := "SELECT name, settings FROM pod_profile WHERE name = ?;"
+	r.logger.Trace(query)
+	row := r.db.QueryRow(query, name)
+	profile := PodProfile{}
+	var settings string
+	err := row.Scan(&profile.Name, &settings)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("No pod profile with name %s was found", name)
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
+	profile.Settings = []byte(settings)
+	return &profile, nil
+}
+
+func (r SQLitePodProfileRepository) UpdatePodProfile(profile PodProfile) (PodProfile, error) {
+	query := "UPDATE pod_profile SET settings = ? WHERE name = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, string(profile.Settings), profile.Name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return PodProfile{}, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return PodProfile{}, err
+	}
+	if count == 0 {
+		return PodProfile{}, sql.ErrNoRows
+	}
+	return profile, nil
+}
+
+func (r SQLitePodProfileRepository) DeletePodProfile(name string) (bool, error) {
+	query := "DELETE FROM pod_profile WHERE name = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	return count != 0, nil
+}
+
+func NewSQLitePodProfileRepository(db *sql.DB) PodProfileRepository {
+	return &SQLitePodProfileRepository{
+		db:     db,
+		logger: utils.GetLogger(),
+	}
+}
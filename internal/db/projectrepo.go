@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+type ProjectRepository interface {
+	CreateProject(project Project) (Project, error)
+	FetchProjects() ([]Project, error)
+	FetchProjectByName(name string) (*Project, error)
+	UpdateProject(project Project) (Project, error)
+	DeleteProject(name string) (bool, error)
+}
+
+type SQLiteProjectRepository struct {
+	db     *sql.DB
+	logger *utils.Logger
+}
+
+func (r SQLiteProjectRepository) CreateProject(project Project) (Project, error) {
+	query := "INSERT INTO project (name, namespace, max_pods, max_running_jobs, weight) VALUES (?, ?, ?, ?, ?);"
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query, project.Name, project.Namespace, project.MaxPods, project.MaxRunningJobs, project.Weight)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Project{}, err
+	}
+	return project, nil
+}
+
+func (r SQLiteProjectRepository) FetchProjects() ([]Project, error) {
+	query := "SELECT name, namespace, max_pods, max_running_jobs, weight FROM project;"
+	r.logger.Trace(query)
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return []Project{}, err
+	}
+	defer rows.Close()
+	projects := []Project{}
+	for rows.Next() {
+		project := Project{}
+		err := rows.Scan(&project.Name, &project.Namespace, &project.MaxPods, &project.MaxRunningJobs, &project.Weight)
+		if err != nil {
+			r.logger.Error(err.Error())
+			return []Project{}, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+func (r SQLiteProjectRepository) FetchProjectByName(name string) (*Project, error) {
+	query := "SELECT name, namespace, max_pods, max_running_jobs, weight FROM project WHERE name = ?;"
+	r.logger.Trace(query)
+	row := r.db.QueryRow(query, name)
+	project := Project{}
+	err := row.Scan(&project.Name, &project.Namespace, &project.MaxPods, &project.MaxRunningJobs, &project.Weight)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("No project with name %s was found", name)
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r SQLiteProjectRepository) UpdateProject(project Project) (Project, error) {
+	query := "UPDATE project SET max_pods = ?, max_running_jobs = ?, weight = ? WHERE name = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, project.MaxPods, project.MaxRunningJobs, project.Weight, project.Name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Project{}, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return Project{}, err
+	}
+	if count == 0 {
+		return Project{}, sql.ErrNoRows
+	}
+	updatedProject, err := r.FetchProjectByName(project.Name)
+	if err != nil {
+		return Project{}, err
+	}
+	if updatedProject == nil {
+		return Project{}, sql.ErrNoRows
+	}
+	return *updatedProject, nil
+}
+
+func (r SQLiteProjectRepository) DeleteProject(name string) (bool, error) {
+	query := "DELETE FROM project WHERE name = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, name)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	return count != 0, nil
+}
+
+func NewSQLiteProjectRepository(db *sql.DB) ProjectRepository {
+	return &SQLiteProjectRepository{
+		db:     db,
+		logger: utils.GetLogger(),
+	}
+}
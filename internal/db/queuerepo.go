@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+type QueueRepository interface {
+	CreateQueueEntry(entry QueueEntry) (QueueEntry, error)
+	FetchQueueEntries() ([]QueueEntry, error)
+	DeleteQueueEntry(jobId string) (bool, error)
+}
+
+type SQLiteQueueRepository struct {
+	db     *sql.DB
+	logger *utils.Logger
+}
+
+func (r SQLiteQueueRepository) CreateQueueEntry(entry QueueEntry) (QueueEntry, error) {
+	query := `INSERT INTO job_queue (job_id, priority, enqueue_time, mapper_name, reducer_name,
+	split_size, prefetch_parallelism, credentials, pod_settings, pod_template, skip_bad_records_after, archive_logs,
+	program_concurrency) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query,
+		entry.JobId,
+		entry.Priority,
+		entry.EnqueueTime,
+		entry.MapperName,
+		entry.ReducerName,
+		entry.SplitSize,
+		entry.PrefetchParallelism,
+		entry.Credentials,
+		entry.PodSettings,
+		entry.PodTemplate,
+		entry.SkipBadRecordsAfter,
+		entry.ArchiveLogs,
+		entry.ProgramConcurrency)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return QueueEntry{}, err
+	}
+	return entry, nil
+}
+
+// FetchQueueEntries returns the queued jobs in the order they should be started, highest priority first and
+// oldest first within the same priority
+func (r SQLiteQueueRepository) FetchQueueEntries() ([]QueueEntry, error) {
+	query := `SELECT job_id, priority, enqueue_time, mapper_name, reducer_name,
+	split_size, prefetch_parallelism, credentials, pod_settings, pod_template, skip_bad_records_after, archive_logs,
+	program_concurrency FROM job_queue
+	ORDER BY priority DESC, enqueue_time ASC, job_id ASC;`
+	r.logger.Trace(query)
+	rows, err := r.db.Query(query)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return []QueueEntry{}, err
+	}
+	defer rows.Close()
+	entries := []QueueEntry{}
+	for rows.Next() {
+		entry := QueueEntry{}
+		err := rows.Scan(
+			&entry.JobId,
+			&entry.Priority,
+			&entry.EnqueueTime,
+			&entry.MapperName,
+			&entry.ReducerName,
+			&entry.SplitSize,
+			&entry.PrefetchParallelism,
+			&entry.Credentials,
+			&entry.PodSettings,
+			&entry.PodTemplate,
+			&entry.SkipBadRecordsAfter,
+			&entry.ArchiveLogs,
+			&entry.ProgramConcurrency)
+		if err != nil {
+			r.logger.Error(err.Error())
+			return []QueueEntry{}, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (r SQLiteQueueRepository) DeleteQueueEntry(jobId string) (bool, error) {
+	query := "DELETE FROM job_queue WHERE job_id = ?;"
+	r.logger.Trace(query)
+	res, err := r.db.Exec(query, jobId)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, err
+	}
+	return count != 0, nil
+}
+
+func NewSQLiteQueueRepository(db *sql.DB) QueueRepository {
+	return &SQLiteQueueRepository{
+		db:     db,
+		logger: utils.GetLogger(),
+	}
+}
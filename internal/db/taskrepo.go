@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/Assifar-Karim/apollo/internal/utils"
@@ -12,6 +14,12 @@ type TaskRepository interface {
 	FetchTasksByJobID(jobId string) ([]Task, error)
 	UpdateTaskStatusByID(id, status string) error
 	UpdateTaskEndTimeByID(id string, endTs int64) error
+	FailTaskByID(id, reason string) error
+	UpdateTaskPodNameByID(id, podName string) error
+	UpdateTaskProgressByID(id string, progress TaskProgress) error
+	UpdateTaskCountersByID(id string, counters map[string]int64) error
+	UpdateTaskLogsByID(id string, logs []InvocationLog) error
+	FetchTaskLogsByID(jobId, id string) ([]InvocationLog, bool, error)
 	UpdateUnfinishedTasksStatusByJobID(status, jobId string) error
 }
 
@@ -26,11 +34,12 @@ func (r *SQLiteTaskRepository) CreateTasksBatch(jobId, taskType string,
 	tasks := make([]Task, count)
 	transactionLogic := func(tx *sql.Tx) error {
 		if len(inputs) > 0 {
-			query := `INSERT INTO input_data (id, path, type, split_start, split_end) VALUES `
+			query := `INSERT INTO input_data (id, path, type, split_start, split_end, etag, version_id) VALUES `
 			queryParams := []any{}
 			for _, inputData := range inputs {
-				queryParams = append(queryParams, inputData.Path, inputData.Type, inputData.SplitStart, inputData.SplitEnd)
-				query += `(NULL, ?, ?, ?, ?),`
+				queryParams = append(queryParams, inputData.Path, inputData.Type, inputData.SplitStart, inputData.SplitEnd,
+					inputData.ETag, inputData.VersionId)
+				query += `(NULL, ?, ?, ?, ?, ?, ?),`
 			}
 			query = query[:len(query)-1] + ";"
 			r.logger.Trace(query)
@@ -98,9 +107,9 @@ func (r *SQLiteTaskRepository) CreateTasksBatch(jobId, taskType string,
 }
 
 func (r *SQLiteTaskRepository) FetchTasksByJobID(jobId string) ([]Task, error) {
-	query := `SELECT t.id, t.type, t.status, t.pod_name, t.start_time, t.end_time,
+	query := `SELECT t.id, t.type, t.status, t.pod_name, t.start_time, t.end_time, t.failure_reason, t.progress, t.counters,
 	a.name, a.type, a.size, a.hash,
-	i.id, i.path, i.type, i.split_start, i.split_end
+	i.id, i.path, i.type, i.split_start, i.split_end, i.etag, i.version_id
 	FROM task t 
 	JOIN artifact a ON a.name = t.program_name
 	LEFT OUTER JOIN input_data i ON i.id = t.input_data_id
@@ -121,6 +130,7 @@ func (r *SQLiteTaskRepository) FetchTasksByJobID(jobId string) ([]Task, error) {
 		// input data scan verification vars
 		var iId sql.NullInt32
 		var iPath, iType sql.NullString
+		var progress, counters sql.NullString
 		err := rows.Scan(
 			&task.Id,
 			&task.Type,
@@ -128,6 +138,9 @@ func (r *SQLiteTaskRepository) FetchTasksByJobID(jobId string) ([]Task, error) {
 			&task.PodName,
 			&task.StartTime,
 			&task.EndTime,
+			&task.FailureReason,
+			&progress,
+			&counters,
 			&artifact.Name,
 			&artifact.Type,
 			&artifact.Size,
@@ -136,13 +149,28 @@ func (r *SQLiteTaskRepository) FetchTasksByJobID(jobId string) ([]Task, error) {
 			&iPath,
 			&iType,
 			&inputData.SplitStart,
-			&inputData.SplitEnd)
+			&inputData.SplitEnd,
+			&inputData.ETag,
+			&inputData.VersionId)
 
 		if err != nil {
 			r.logger.Error(err.Error())
 			return []Task{}, err
 		}
 
+		if progress.Valid {
+			task.Progress = &TaskProgress{}
+			if err := json.Unmarshal([]byte(progress.String), task.Progress); err != nil {
+				r.logger.Error(err.Error())
+				return []Task{}, err
+			}
+		}
+
+		if task.Counters, err = decodeCounters(counters); err != nil {
+			r.logger.Error(err.Error())
+			return []Task{}, err
+		}
+
 		if iId.Valid && iPath.Valid && iType.Valid {
 			inputData.Id = int(iId.Int32)
 			inputData.Path = iPath.String
@@ -170,6 +198,75 @@ func (r *SQLiteTaskRepository) UpdateTaskEndTimeByID(id string, endTs int64) err
 	return err
 }
 
+func (r *SQLiteTaskRepository) UpdateTaskPodNameByID(id, podName string) error {
+	query := "UPDATE task SET pod_name = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query, podName, id)
+	return err
+}
+
+func (r *SQLiteTaskRepository) UpdateTaskProgressByID(id string, progress TaskProgress) error {
+	encodedProgress, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE task SET progress = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err = r.db.Exec(query, string(encodedProgress), id)
+	return err
+}
+
+func (r *SQLiteTaskRepository) UpdateTaskCountersByID(id string, counters map[string]int64) error {
+	encodedCounters, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE task SET counters = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err = r.db.Exec(query, string(encodedCounters), id)
+	return err
+}
+
+func (r *SQLiteTaskRepository) UpdateTaskLogsByID(id string, logs []InvocationLog) error {
+	encodedLogs, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE task SET invocation_logs = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err = r.db.Exec(query, string(encodedLogs), id)
+	return err
+}
+
+// FetchTaskLogsByID returns the logs of the failed invocations of a task and whether the task belongs to the job
+func (r *SQLiteTaskRepository) FetchTaskLogsByID(jobId, id string) ([]InvocationLog, bool, error) {
+	query := "SELECT invocation_logs FROM task WHERE id = ? AND job_id = ?;"
+	r.logger.Trace(query)
+	var encodedLogs sql.NullString
+	err := r.db.QueryRow(query, id, jobId).Scan(&encodedLogs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, false, err
+	}
+	logs := []InvocationLog{}
+	if encodedLogs.Valid {
+		if err := json.Unmarshal([]byte(encodedLogs.String), &logs); err != nil {
+			return nil, false, err
+		}
+	}
+	return logs, true, nil
+}
+
+func (r *SQLiteTaskRepository) FailTaskByID(id, reason string) error {
+	query := "UPDATE task SET status = 'failed', failure_reason = ? WHERE id = ?;"
+	r.logger.Trace(query)
+	_, err := r.db.Exec(query, reason, id)
+	return err
+}
+
 func (r *SQLiteTaskRepository) UpdateUnfinishedTasksStatusByJobID(status, jobId string) error {
 	query := "UPDATE task SET status = ? WHERE job_id = ? AND status != completed"
 	r.logger.Trace(query)
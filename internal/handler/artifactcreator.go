@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 type artifactHandler struct {
 	artifactManager coordinator.ArtifactManager
+	projectManager  coordinator.ProjectManager
 }
 
 func (h *artifactHandler) CreateArtifact(w http.ResponseWriter, r *http.Request) {
@@ -22,7 +25,33 @@ func (h *artifactHandler) CreateArtifact(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	defer file.Close()
-	artifact, err := h.artifactManager.CreateArtifact(fHandler.Filename, "executable", fHandler.Size, file)
+	projectName := r.FormValue("project")
+	if projectName == "" {
+		projectName = coordinator.DefaultProject
+	}
+	project, err := h.projectManager.GetProjectByName(projectName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.Error(w, fmt.Sprintf("%s project can't be found!", projectName), http.StatusNotFound)
+		return
+	}
+	existing, err := h.artifactManager.GetArtifactDetailsByName(fHandler.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing != nil && !canModify(r, existing.Owner) {
+		http.Error(w, fmt.Sprintf("%s artifact belongs to another user", fHandler.Filename), http.StatusForbidden)
+		return
+	}
+	if existing != nil && existing.Project != project.Name {
+		http.Error(w, fmt.Sprintf("%s artifact already exists in the %s project", fHandler.Filename, existing.Project), http.StatusConflict)
+		return
+	}
+	artifact, err := h.artifactManager.CreateArtifact(fHandler.Filename, "executable", getPrincipal(r).User, project.Name, fHandler.Size, file)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -43,6 +72,9 @@ func (h *artifactHandler) GetArtifacts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if project := r.URL.Query().Get("project"); project != "" {
+		artifacts = slices.DeleteFunc(artifacts, func(artifact db.Artifact) bool { return artifact.Project != project })
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(artifacts)
@@ -74,7 +106,16 @@ func (h *artifactHandler) GetArtifactByName(w http.ResponseWriter, r *http.Reque
 
 func (h *artifactHandler) DeleteArtifact(w http.ResponseWriter, r *http.Request) {
 	name := chi.URLParam(r, "filename")
-	_, err := h.artifactManager.DeleteArtifact(name)
+	artifact, err := h.artifactManager.GetArtifactDetailsByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if artifact != nil && !canModify(r, artifact.Owner) {
+		http.Error(w, fmt.Sprintf("%s artifact belongs to another user", name), http.StatusForbidden)
+		return
+	}
+	_, err = h.artifactManager.DeleteArtifact(name)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -83,18 +124,19 @@ func (h *artifactHandler) DeleteArtifact(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func NewArtifactHandler(artifactManager coordinator.ArtifactManager) *Controller {
+func NewArtifactHandler(artifactManager coordinator.ArtifactManager, projectManager coordinator.ProjectManager) *Controller {
 	router := chi.NewRouter()
 	router.Use(middleware.AllowContentType("application/json", "multipart/form-data"))
 	handler := artifactHandler{
 		artifactManager: artifactManager,
+		projectManager:  projectManager,
 	}
 
 	// Endpoints definition
-	router.Put("/", handler.CreateArtifact)
-	router.Get("/", handler.GetArtifacts)
-	router.Get("/{filename}", handler.GetArtifactByName)
-	router.Delete("/{filename}", handler.DeleteArtifact)
+	router.With(RequireRole(coordinator.RoleSubmitter)).Put("/", handler.CreateArtifact)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/", handler.GetArtifacts)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{filename}", handler.GetArtifactByName)
+	router.With(RequireRole(coordinator.RoleSubmitter)).Delete("/{filename}", handler.DeleteArtifact)
 
 	return &Controller{
 		Pattern: "/api/v1/artifacts",
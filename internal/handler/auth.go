@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+)
+
+type principalKey struct{}
+
+// Authenticate attaches the caller principal to the request context, every caller is considered an admin when
+// no authentication method is configured
+func Authenticate(authenticator coordinator.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &coordinator.Principal{Role: coordinator.RoleAdmin}
+			if authenticator.IsEnabled() {
+				var err error
+				principal, err = authenticator.Authenticate(r.Header.Get("Authorization"))
+				if err != nil {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+			}
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func RequireRole(role coordinator.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := getPrincipal(r)
+			if principal == nil || principal.Role < role {
+				http.Error(w, "insufficient permissions", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func getPrincipal(r *http.Request) *coordinator.Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*coordinator.Principal)
+	return principal
+}
+
+// canModify checks whether the request principal owns a resource, admins can modify any resource
+func canModify(r *http.Request, owner string) bool {
+	principal := getPrincipal(r)
+	if principal == nil {
+		return false
+	}
+	return principal.Role == coordinator.RoleAdmin || (owner != "" && owner == principal.User)
+}
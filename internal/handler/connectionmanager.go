@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type connectionHandler struct {
+	connectionManager coordinator.ConnectionManager
+}
+
+type connectionInfo struct {
+	Name           string          `json:"name"`
+	Endpoint       string          `json:"endpoint"`
+	UseSSL         bool            `json:"useSSL"`
+	Region         string          `json:"region,omitempty"`
+	ForcePathStyle bool            `json:"forcePathStyle,omitempty"`
+	CABundle       string          `json:"caBundle,omitempty"`
+	Credentials    *io.Credentials `json:"credentials,omitempty"`
+	SecretRef      *string         `json:"secretRef,omitempty"`
+}
+
+func (h *connectionHandler) createConnection(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var body connectionInfo
+	err := decoder.Decode(&body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" || body.Endpoint == "" {
+		http.Error(w, "connection name and endpoint can't be empty", http.StatusBadRequest)
+		return
+	}
+	if (body.Credentials == nil) == (body.SecretRef == nil) {
+		http.Error(w, "either credentials or secretRef must be provided", http.StatusBadRequest)
+		return
+	}
+
+	connection, err := h.connectionManager.PersistConnection(db.Connection{
+		Name:           body.Name,
+		Endpoint:       body.Endpoint,
+		UseSSL:         body.UseSSL,
+		Region:         body.Region,
+		ForcePathStyle: body.ForcePathStyle,
+		CABundle:       body.CABundle,
+		SecretRef:      body.SecretRef,
+	}, body.Credentials)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(connection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *connectionHandler) getConnections(w http.ResponseWriter, r *http.Request) {
+	connections, err := h.connectionManager.GetAllConnections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(connections)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *connectionHandler) getConnectionByName(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	connection, err := h.connectionManager.GetConnectionByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if connection == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&connection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *connectionHandler) deleteConnection(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	found, err := h.connectionManager.DeleteConnection(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewConnectionHandler(connectionManager coordinator.ConnectionManager) *Controller {
+	router := chi.NewRouter()
+	router.Use(middleware.AllowContentType("application/json"))
+	handler := connectionHandler{
+		connectionManager: connectionManager,
+	}
+
+	// Endpoints definition
+	router.With(RequireRole(coordinator.RoleAdmin)).Put("/", handler.createConnection)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/", handler.getConnections)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{name}", handler.getConnectionByName)
+	router.With(RequireRole(coordinator.RoleAdmin)).Delete("/{name}", handler.deleteConnection)
+
+	return &Controller{
+		Pattern: "/api/v1/connections",
+		Router:  router,
+	}
+}
@@ -2,35 +2,56 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Assifar-Karim/apollo/internal/coordinator"
 	"github.com/Assifar-Karim/apollo/internal/db"
 	"github.com/Assifar-Karim/apollo/internal/io"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	_ "modernc.org/sqlite"
 )
 
 type jobManagerHandler struct {
 	jobMetadataManager coordinator.JobMetadataManager
 	artifactManager    coordinator.ArtifactManager
+	connectionManager  coordinator.ConnectionManager
+	projectManager     coordinator.ProjectManager
+	podProfileManager  coordinator.PodProfileManager
+	podTemplates       coordinator.PodTemplateLoader
 	jobScheduler       coordinator.JobScheduler
+	jobQueue           coordinator.JobQueue
+	workerLogs         coordinator.WorkerLogs
 }
 
 type jobInfo struct {
-	NReducers                int            `json:"nReducers"`
-	InputPath                string         `json:"inputPath"`
-	InputType                string         `json:"inputType"`
-	OutputPath               string         `json:"outputPath"`
-	UseSSL                   bool           `json:"useSSL"`
-	MapperName               string         `json:"mapperName"`
-	ReducerName              string         `json:"reducerName"`
-	InputStorageCredentials  io.Credentials `json:"inputStorageCredentials"`
-	OutputStorageCredentials io.Credentials `json:"outputStorageCredentials"`
-	SplitSize                *int64         `json:"splitSize,omitempty"`
+	NReducers                int                      `json:"nReducers"`
+	InputPath                string                   `json:"inputPath"`
+	InputType                string                   `json:"inputType"`
+	OutputPath               string                   `json:"outputPath"`
+	UseSSL                   bool                     `json:"useSSL"`
+	MapperName               string                   `json:"mapperName"`
+	ReducerName              string                   `json:"reducerName"`
+	InputStorageCredentials  io.Credentials           `json:"inputStorageCredentials"`
+	OutputStorageCredentials io.Credentials           `json:"outputStorageCredentials"`
+	InputConnection          string                   `json:"inputConnection,omitempty"`
+	OutputConnection         string                   `json:"outputConnection,omitempty"`
+	SplitSize                *int64                   `json:"splitSize,omitempty"`
+	PrefetchParallelism      *int64                   `json:"prefetchParallelism,omitempty"`
+	SkipBadRecordsAfter      *int64                   `json:"skipBadRecordsAfter,omitempty"`
+	ProgramConcurrency       *int64                   `json:"programConcurrency,omitempty"`
+	ArchiveLogs              bool                     `json:"archiveLogs,omitempty"`
+	Project                  string                   `json:"project,omitempty"`
+	Priority                 string                   `json:"priority,omitempty"`
+	PodProfile               string                   `json:"podProfile,omitempty"`
+	PodSettings              *coordinator.PodSettings `json:"podSettings,omitempty"`
+	PodTemplate              string                   `json:"podTemplate,omitempty"`
 }
 
 type ScheduleDTO struct {
@@ -47,6 +68,9 @@ func (h *jobManagerHandler) getJobs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if project := r.URL.Query().Get("project"); project != "" {
+		jobs = slices.DeleteFunc(jobs, func(job db.Job) bool { return job.Project != project })
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(jobs)
@@ -91,6 +115,68 @@ func (h *jobManagerHandler) scheduleJob(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if body.PrefetchParallelism != nil &&
+		(*body.PrefetchParallelism < 1 || *body.PrefetchParallelism > io.MaxPrefetchParallelism) {
+		errMsg := fmt.Sprintf("prefetchParallelism must be between 1 and %v", io.MaxPrefetchParallelism)
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+	if body.SkipBadRecordsAfter != nil && *body.SkipBadRecordsAfter < 1 {
+		http.Error(w, "skipBadRecordsAfter must be at least 1", http.StatusBadRequest)
+		return
+	}
+	if body.ProgramConcurrency != nil && *body.ProgramConcurrency < 1 {
+		http.Error(w, "programConcurrency must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	priority, err := coordinator.ParsePriority(body.Priority)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Project == "" {
+		body.Project = coordinator.DefaultProject
+	}
+	project, err := h.projectManager.GetProjectByName(body.Project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.Error(w, fmt.Sprintf("%s project can't be found!", body.Project), http.StatusNotFound)
+		return
+	}
+
+	// Named connections replace the inline credentials and complete the storage locations, a location carrying a scheme
+	// is kept as is while any other location is relative to the connection URL
+	if body.InputConnection != "" {
+		connection, creds, status, err := h.resolveConnection(body.InputConnection)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		body.InputStorageCredentials = creds
+		body.InputPath = completeLocation(*connection, body.InputPath)
+	}
+	if body.OutputConnection != "" {
+		connection, creds, status, err := h.resolveConnection(body.OutputConnection)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		body.OutputStorageCredentials = creds
+		if !strings.Contains(body.OutputPath, "://") {
+			// The output location is the storage endpoint, the connection one can't be nested in a path
+			if strings.Trim(body.OutputPath, "/") != "" {
+				http.Error(w, "outputPath must be empty or a full URL when outputConnection is set", http.StatusBadRequest)
+				return
+			}
+			body.UseSSL = connection.UseSSL
+		}
+		body.OutputPath = completeLocation(*connection, body.OutputPath)
+	}
+
 	artifactNames := []string{body.MapperName, body.ReducerName}
 	artifacts := make([]db.Artifact, 2)
 	for idx, name := range artifactNames {
@@ -104,24 +190,72 @@ func (h *jobManagerHandler) scheduleJob(w http.ResponseWriter, r *http.Request)
 			http.Error(w, errMsg, http.StatusNotFound)
 			return
 		}
+		if artifact.Project != project.Name {
+			errMsg := fmt.Sprintf("%s artifact doesn't belong to the %s project", name, project.Name)
+			http.Error(w, errMsg, http.StatusForbidden)
+			return
+		}
 		artifacts[idx] = *artifact
 	}
 
-	job, err := h.jobMetadataManager.PersistJob(body.NReducers, body.InputPath, body.InputType, body.OutputPath, body.UseSSL)
+	podSettings, err := h.podProfileManager.ResolvePodSettings(body.PodProfile, body.PodSettings)
+	if errors.Is(err, coordinator.ErrPodProfileNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	creds := []io.Credentials{body.InputStorageCredentials, body.OutputStorageCredentials}
-	go func() {
-		_, err := h.jobScheduler.ScheduleJob(job, artifacts, creds, body.SplitSize)
-		if err == nil {
-			h.jobMetadataManager.SetJobEndTimestamp(job.Id)
+	// The named template is checked early so that a typo doesn't fail the job once it leaves the queue
+	if body.PodTemplate != "" {
+		_, err := h.podTemplates.LoadPodTemplate(body.PodTemplate)
+		if errors.Is(err, coordinator.ErrPodTemplateNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
 		}
-	}()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Jobs that could never fit in their project quota are refused instead of waiting in the queue forever, only the
+	// reducers are known before the input is split and the scheduler checks the mappers
+	if project.MaxPods > 0 && body.NReducers > project.MaxPods {
+		errMsg := fmt.Sprintf("%v reducers exceed the %v pods allowed in the %s project", body.NReducers, project.MaxPods, project.Name)
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobMetadataManager.PersistJob(body.NReducers, body.InputPath, body.InputType, body.OutputPath,
+		getPrincipal(r).User, project.Name, body.UseSSL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = h.jobQueue.Enqueue(job, coordinator.JobRequest{
+		Priority:    priority,
+		MapperName:  artifacts[0].Name,
+		ReducerName: artifacts[1].Name,
+		Credentials: []io.Credentials{body.InputStorageCredentials, body.OutputStorageCredentials},
+		Options: coordinator.JobOptions{
+			SplitSize:           body.SplitSize,
+			PrefetchParallelism: body.PrefetchParallelism,
+			SkipBadRecordsAfter: body.SkipBadRecordsAfter,
+			ProgramConcurrency:  body.ProgramConcurrency,
+			ArchiveLogs:         body.ArchiveLogs,
+			PodSettings:         podSettings,
+			PodTemplate:         body.PodTemplate,
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// NOTE (KARIM): Add a way to save the credentials in a vault later for restarting jobs in case of failure
 	response := ScheduleDTO{
 		Job:           job,
 		MapProgram:    artifacts[0],
@@ -137,6 +271,32 @@ func (h *jobManagerHandler) scheduleJob(w http.ResponseWriter, r *http.Request)
 
 }
 
+// completeLocation prefixes the locations without scheme with the URL of their connection
+func completeLocation(connection db.Connection, location string) string {
+	if strings.Contains(location, "://") {
+		return location
+	}
+	if location = strings.TrimPrefix(location, "/"); location == "" {
+		return connection.GetURL()
+	}
+	return fmt.Sprintf("%s/%s", connection.GetURL(), location)
+}
+
+func (h *jobManagerHandler) resolveConnection(name string) (*db.Connection, io.Credentials, int, error) {
+	connection, err := h.connectionManager.GetConnectionByName(name)
+	if err != nil {
+		return nil, io.Credentials{}, http.StatusInternalServerError, err
+	}
+	if connection == nil {
+		return nil, io.Credentials{}, http.StatusNotFound, fmt.Errorf("%s connection can't be found!", name)
+	}
+	creds, err := h.connectionManager.ResolveCredentials(*connection)
+	if err != nil {
+		return nil, io.Credentials{}, http.StatusInternalServerError, err
+	}
+	return connection, creds, http.StatusOK, nil
+}
+
 func (h *jobManagerHandler) getTasksByJobId(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	tasks, err := h.jobMetadataManager.GetTasksByJobID(id)
@@ -153,6 +313,75 @@ func (h *jobManagerHandler) getTasksByJobId(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+func (h *jobManagerHandler) getTaskLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	taskId := chi.URLParam(r, "taskId")
+	logs, found, err := h.jobMetadataManager.GetTaskLogs(id, taskId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("No task with id %s was found in job %s!", taskId, id), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(logs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// streamTaskPodLogs copies the logs of a task worker pod as Kubernetes sends them, the follow query parameter keeps
+// the response open until the pod stops
+func (h *jobManagerHandler) streamTaskPodLogs(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	taskId := chi.URLParam(r, "taskId")
+	opts := coordinator.PodLogOptions{Follow: r.URL.Query().Get("follow") == "true"}
+	if tailLines := r.URL.Query().Get("tailLines"); tailLines != "" {
+		n, err := strconv.ParseInt(tailLines, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("%s isn't a valid number of lines", tailLines), http.StatusBadRequest)
+			return
+		}
+		opts.TailLines = &n
+	}
+	logs, err := h.workerLogs.StreamTaskLogs(r.Context(), id, taskId, opts)
+	if errors.Is(err, coordinator.ErrTaskNotFound) || apierrors.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, coordinator.ErrTaskPodUnknown) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer logs.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 func (h *jobManagerHandler) stopJob(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	job, err := h.jobMetadataManager.GetJobById(id)
@@ -164,11 +393,25 @@ func (h *jobManagerHandler) stopJob(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("No job with id %s was found!", id), http.StatusNotFound)
 		return
 	}
+	if !canModify(r, job.Owner) {
+		http.Error(w, fmt.Sprintf("Job %s belongs to another user", id), http.StatusForbidden)
+		return
+	}
 	if job.EndTime != nil {
 		http.Error(w, fmt.Sprintf("Job %s already finished its workload!", id), http.StatusNotAcceptable)
 		return
 	}
-	if err := h.jobScheduler.StopJob(id); err != nil {
+	// The stopped status is set first so that the job doesn't get reported as failed once its pods are gone
+	if _, err := h.jobMetadataManager.SetJobStatus(id, db.JobStopped); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if job.Status == db.JobQueued {
+		_, err = h.jobQueue.Remove(id)
+	} else {
+		err = h.jobScheduler.StopJob(*job)
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -188,20 +431,34 @@ func (h *jobManagerHandler) stopJob(w http.ResponseWriter, r *http.Request) {
 func NewJobManagerHandler(
 	jobMetadataManager coordinator.JobMetadataManager,
 	artifactManager coordinator.ArtifactManager,
-	jobScheduler coordinator.JobScheduler) *Controller {
+	connectionManager coordinator.ConnectionManager,
+	projectManager coordinator.ProjectManager,
+	podProfileManager coordinator.PodProfileManager,
+	podTemplates coordinator.PodTemplateLoader,
+	jobScheduler coordinator.JobScheduler,
+	jobQueue coordinator.JobQueue,
+	workerLogs coordinator.WorkerLogs) *Controller {
 	router := chi.NewRouter()
 	router.Use(middleware.AllowContentType("application/json"))
 	handler := jobManagerHandler{
 		jobMetadataManager: jobMetadataManager,
 		artifactManager:    artifactManager,
+		connectionManager:  connectionManager,
+		projectManager:     projectManager,
+		podProfileManager:  podProfileManager,
+		podTemplates:       podTemplates,
 		jobScheduler:       jobScheduler,
+		jobQueue:           jobQueue,
+		workerLogs:         workerLogs,
 	}
 	// Endpoints definition
-	router.Get("/", handler.getJobs)
-	router.Get("/{id}", handler.getJobById)
-	router.Get("/{id}/tasks", handler.getTasksByJobId)
-	router.Post("/", handler.scheduleJob)
-	router.Delete("/{id}", handler.stopJob)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/", handler.getJobs)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{id}", handler.getJobById)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{id}/tasks", handler.getTasksByJobId)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{id}/tasks/{taskId}/logs", handler.getTaskLogs)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{id}/tasks/{taskId}/pod-logs", handler.streamTaskPodLogs)
+	router.With(RequireRole(coordinator.RoleSubmitter)).Post("/", handler.scheduleJob)
+	router.With(RequireRole(coordinator.RoleSubmitter)).Delete("/{id}", handler.stopJob)
 
 	return &Controller{
 		Pattern: "/api/v1/jobs",
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type podProfileInfo struct {
+	Name     string                  `json:"name"`
+	Settings coordinator.PodSettings `json:"settings"`
+}
+
+type podProfileHandler struct {
+	podProfileManager coordinator.PodProfileManager
+}
+
+func (h *podProfileHandler) createPodProfile(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var body podProfileInfo
+	err := decoder.Decode(&body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" {
+		http.Error(w, "pod profile name can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.podProfileManager.PersistPodProfile(body.Name, body.Settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *podProfileHandler) getPodProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.podProfileManager.GetAllPodProfiles()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(profiles)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *podProfileHandler) getPodProfileByName(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	profile, err := h.podProfileManager.GetPodProfileByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *podProfileHandler) deletePodProfile(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	_, err := h.podProfileManager.DeletePodProfile(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewPodProfileHandler(podProfileManager coordinator.PodProfileManager) *Controller {
+	router := chi.NewRouter()
+	router.Use(middleware.AllowContentType("application/json"))
+	handler := podProfileHandler{
+		podProfileManager: podProfileManager,
+	}
+
+	// Endpoints definition
+	router.With(RequireRole(coordinator.RoleAdmin)).Put("/", handler.createPodProfile)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/", handler.getPodProfiles)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{name}", handler.getPodProfileByName)
+	router.With(RequireRole(coordinator.RoleAdmin)).Delete("/{name}", handler.deletePodProfile)
+
+	return &Controller{
+		Pattern: "/api/v1/podprofiles",
+		Router:  router,
+	}
+}
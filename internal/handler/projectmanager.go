@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+type projectHandler struct {
+	projectManager coordinator.ProjectManager
+}
+
+func (h *projectHandler) createProject(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var body db.Project
+	err := decoder.Decode(&body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Name == "" {
+		http.Error(w, "project name can't be empty", http.StatusBadRequest)
+		return
+	}
+	if errs := validation.IsDNS1123Label(body.Namespace); len(errs) != 0 {
+		http.Error(w, errs[0], http.StatusBadRequest)
+		return
+	}
+	if body.MaxPods < 0 || body.MaxRunningJobs < 0 || body.Weight < 0 {
+		http.Error(w, "project limits and weight can't be negative", http.StatusBadRequest)
+		return
+	}
+	if body.Weight == 0 {
+		body.Weight = 1
+	}
+
+	project, err := h.projectManager.PersistProject(body)
+	if errors.Is(err, coordinator.ErrNamespaceInUse) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *projectHandler) getProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.projectManager.GetAllProjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(projects)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *projectHandler) getProjectByName(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	project, err := h.projectManager.GetProjectByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(&project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *projectHandler) deleteProject(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	found, err := h.projectManager.DeleteProject(name)
+	if errors.Is(err, coordinator.ErrProjectInUse) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewProjectHandler(projectManager coordinator.ProjectManager) *Controller {
+	router := chi.NewRouter()
+	router.Use(middleware.AllowContentType("application/json"))
+	handler := projectHandler{
+		projectManager: projectManager,
+	}
+
+	// Endpoints definition
+	router.With(RequireRole(coordinator.RoleAdmin)).Put("/", handler.createProject)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/", handler.getProjects)
+	router.With(RequireRole(coordinator.RoleViewer)).Get("/{name}", handler.getProjectByName)
+	router.With(RequireRole(coordinator.RoleAdmin)).Delete("/{name}", handler.deleteProject)
+
+	return &Controller{
+		Pattern: "/api/v1/projects",
+		Router:  router,
+	}
+}
@@ -1,7 +1,9 @@
 package handler
 
 import (
-	"sync"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Assifar-Karim/apollo/internal/proto"
 	"github.com/Assifar-Karim/apollo/internal/utils"
@@ -12,39 +14,40 @@ import (
 
 type TaskCreatorHandler struct {
 	proto.UnimplementedTaskCreatorServer
-	worker *worker.Worker
+	// Only set when the worker runs a single task as an Indexed Job pod
+	completionIndex *int64
+	done            chan error
 }
 
 func (h TaskCreatorHandler) StartTask(task *proto.Task, stream proto.TaskCreator_StartTaskServer) error {
 	logger := utils.GetLogger()
-	workerType := task.GetType()
-	var workerAlgorithm worker.WorkerAlgorithm
-	var err error = nil
+	if h.completionIndex != nil {
+		// The task ids end with their index, an Indexed Job pod only accepts the task matching its completion index
+		expectedSuffix := fmt.Sprintf("-%v", *h.completionIndex)
+		if !strings.HasSuffix(task.GetId(), expectedSuffix) {
+			return status.Errorf(codes.FailedPrecondition, "task %s doesn't match completion index %v", task.GetId(), *h.completionIndex)
+		}
+	}
 	var resultingFiles []*proto.FileData
-
-	if workerType == 0 {
-		workerAlgorithm = worker.NewMapper()
-		logger.Info("Map task assigned")
-	} else if workerType == 1 {
-		workerAlgorithm = worker.NewReducer()
-		logger.Info("Reduce task assigned")
-	} else {
-		return status.Error(codes.InvalidArgument, "illegal worker type")
+	workerAlgorithm, err := worker.NewWorkerAlgorithm(task.GetType())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
-	h.worker.SetWorkerAlgorithm(workerAlgorithm)
+	logger.Info("Task %s assigned", task.GetId())
+	// Every task gets its own worker so that the tasks sent at once don't share their algorithm and progress
+	taskWorker := &worker.Worker{}
+	taskWorker.SetWorkerAlgorithm(workerAlgorithm)
 
 	stream.Send(&proto.TaskStatusInfo{
 		TaskStatus:     "idle",
 		ResultingFiles: []*proto.FileData{},
 	})
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
+	computed := make(chan struct{})
 	go func() {
-		defer wg.Done()
-		resultingFiles, err = h.worker.Compute(task)
+		defer close(computed)
 		logger.Info("Task started")
+		resultingFiles, err = taskWorker.Compute(task)
 	}()
 
 	stream.Send(&proto.TaskStatusInfo{
@@ -52,25 +55,49 @@ func (h TaskCreatorHandler) StartTask(task *proto.Task, stream proto.TaskCreator
 		ResultingFiles: []*proto.FileData{},
 	})
 
-	wg.Wait()
+	// The heartbeats tell the coordinator that the worker is still alive while the task runs, they keep going while a
+	// program hangs which is left to the timeout of its invocation
+	heartbeats := time.NewTicker(worker.GetHeartbeatInterval(task))
+	defer heartbeats.Stop()
+	for running := true; running; {
+		select {
+		case <-computed:
+			running = false
+		case <-heartbeats.C:
+			stream.Send(&proto.TaskStatusInfo{
+				TaskStatus:     "in-progress",
+				ResultingFiles: []*proto.FileData{},
+				Progress:       taskWorker.Progress(),
+			})
+		}
+	}
 
 	if err != nil {
-		stream.Send(&proto.TaskStatusInfo{
-			TaskStatus:     "failed",
-			ResultingFiles: []*proto.FileData{},
-		})
+		stream.Send(taskWorker.StatusInfo("failed", []*proto.FileData{}))
 		logger.Error("Task failed")
 		logger.Error(err.Error())
 	} else {
-		stream.Send(&proto.TaskStatusInfo{
-			TaskStatus:     "completed",
-			ResultingFiles: resultingFiles,
-		})
+		stream.Send(taskWorker.StatusInfo("completed", resultingFiles))
 		logger.Info("Task completed succesfully")
 	}
+	if h.done != nil {
+		select {
+		case h.done <- err:
+		default:
+		}
+	}
 	return err
 }
 
-func NewTaskCreatorHandler(worker *worker.Worker) *TaskCreatorHandler {
-	return &TaskCreatorHandler{worker: worker}
+// Done reports the outcome of the task of an Indexed Job pod, it is nil for the workers serving several tasks
+func (h TaskCreatorHandler) Done() <-chan error {
+	return h.done
+}
+
+func NewTaskCreatorHandler(completionIndex *int64) *TaskCreatorHandler {
+	handler := &TaskCreatorHandler{completionIndex: completionIndex}
+	if completionIndex != nil {
+		handler.done = make(chan error, 1)
+	}
+	return handler
 }
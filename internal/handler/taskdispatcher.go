@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type TaskDispatcherHandler struct {
+	proto.UnimplementedTaskDispatcherServer
+	dispatcher coordinator.TaskDispatcher
+}
+
+func (h TaskDispatcherHandler) RegisterWorker(ctx context.Context, registration *proto.WorkerRegistration) (*proto.RegistrationAck, error) {
+	if registration.GetWorkerId() == "" || registration.GetJobId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "worker id and job id are required")
+	}
+	if registration.GetType() != 0 && registration.GetType() != 1 {
+		return nil, status.Error(codes.InvalidArgument, "illegal worker type")
+	}
+	if !isJobPeer(ctx, registration.GetJobId()) {
+		return nil, status.Errorf(codes.PermissionDenied, "worker certificate wasn't issued for job %s", registration.GetJobId())
+	}
+	// A worker id can't be taken over by the workers of another job
+	if jobId, err := h.dispatcher.WorkerJob(registration.GetWorkerId()); err == nil && jobId != registration.GetJobId() {
+		return nil, status.Errorf(codes.PermissionDenied, "worker %s is registered for another job", registration.GetWorkerId())
+	}
+	if err := h.dispatcher.RegisterWorker(registration.GetWorkerId(), registration.GetJobId(), registration.GetType()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	heartbeatInterval := coordinator.GetConfig().GetHeartbeatInterval()
+	return &proto.RegistrationAck{HeartbeatIntervalMs: heartbeatInterval.Milliseconds()}, nil
+}
+
+func (h TaskDispatcherHandler) SendHeartbeat(ctx context.Context, heartbeat *proto.Heartbeat) (*proto.Ack, error) {
+	if err := h.authorizeWorker(ctx, heartbeat.GetWorkerId()); err != nil {
+		return nil, err
+	}
+	if err := h.dispatcher.RecordHeartbeat(heartbeat.GetWorkerId(), heartbeat.TaskId, heartbeat.Progress); err != nil {
+		return nil, dispatcherError(err)
+	}
+	return &proto.Ack{}, nil
+}
+
+func (h TaskDispatcherHandler) PollTask(ctx context.Context, request *proto.TaskRequest) (*proto.TaskAssignment, error) {
+	if err := h.authorizeWorker(ctx, request.GetWorkerId()); err != nil {
+		return nil, err
+	}
+	task, err := h.dispatcher.PollTask(ctx, request.GetWorkerId(), request.CompletionIndex)
+	if err != nil {
+		return nil, dispatcherError(err)
+	}
+	return &proto.TaskAssignment{Task: task}, nil
+}
+
+func (h TaskDispatcherHandler) ReportTaskStatus(ctx context.Context, report *proto.TaskStatusReport) (*proto.Ack, error) {
+	if err := h.authorizeWorker(ctx, report.GetWorkerId()); err != nil {
+		return nil, err
+	}
+	err := h.dispatcher.ReportTaskStatus(report.GetWorkerId(), report.GetTaskId(), report.GetStatusInfo(), report.Error)
+	if err != nil {
+		return nil, dispatcherError(err)
+	}
+	return &proto.Ack{}, nil
+}
+
+// authorizeWorker only lets a registered worker be acted for by the workers of its own job
+func (h TaskDispatcherHandler) authorizeWorker(ctx context.Context, workerId string) error {
+	jobId, err := h.dispatcher.WorkerJob(workerId)
+	if err != nil {
+		return dispatcherError(err)
+	}
+	if !isJobPeer(ctx, jobId) {
+		return status.Errorf(codes.PermissionDenied, "worker certificate wasn't issued for job %s", jobId)
+	}
+	return nil
+}
+
+// isJobPeer tells whether the client certificate was issued by the coordinator for the workers of the job
+func isJobPeer(ctx context.Context, jobId string) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return false
+	}
+	return hasCommonName(tlsInfo.State, utils.WorkerCommonNamePrefix+jobId)
+}
+
+func hasCommonName(state tls.ConnectionState, commonName string) bool {
+	for _, chain := range state.VerifiedChains {
+		if len(chain) > 0 && chain[0].Subject.CommonName == commonName {
+			return true
+		}
+	}
+	return false
+}
+
+func dispatcherError(err error) error {
+	if errors.Is(err, coordinator.ErrUnknownWorker) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
+func NewTaskDispatcherHandler(dispatcher coordinator.TaskDispatcher) *TaskDispatcherHandler {
+	return &TaskDispatcherHandler{dispatcher: dispatcher}
+}
@@ -0,0 +1,224 @@
+package io
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	MaxReadRetries       = 5
+	DefaultPrefetchChunk = 8 * 1024 * 1024
+	// Every chunk being prefetched is held in memory, the parallelism is capped to bound the memory of a worker
+	MaxPrefetchParallelism = 16
+)
+
+// RangeOpener opens a reader over the inclusive [start, end] byte range of an object
+type RangeOpener func(ctx context.Context, start, end int64) (io.ReadCloser, error)
+
+// NewRangeReader reads the inclusive [start, end] byte range opened by open, retrying the transient errors from the
+// last consumed offset, chunks of the range are prefetched in parallel when the parallelism is above 1
+func NewRangeReader(ctx context.Context, open RangeOpener, start, end, chunkSize int64, parallelism, maxRetries int) io.ReadCloser {
+	parallelism = min(parallelism, MaxPrefetchParallelism)
+	if parallelism > 1 {
+		return newPrefetchReader(ctx, open, start, end, chunkSize, parallelism, maxRetries)
+	}
+	return &resumableReader{
+		ctx:        ctx,
+		open:       open,
+		offset:     start,
+		end:        end,
+		maxRetries: maxRetries,
+		logger:     utils.GetLogger(),
+	}
+}
+
+// resumableReader reads a byte range and transparently reopens it from the last
+// consumed offset whenever a transient error interrupts the stream.
+type resumableReader struct {
+	ctx        context.Context
+	open       RangeOpener
+	offset     int64
+	end        int64
+	body       io.ReadCloser
+	maxRetries int
+	logger     *utils.Logger
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	if r.offset > r.end {
+		return 0, io.EOF
+	}
+	if remaining := r.end - r.offset + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	retries := 0
+	for {
+		var err error
+		if r.body == nil {
+			r.body, err = r.open(r.ctx, r.offset, r.end)
+		}
+		n := 0
+		if err == nil {
+			n, err = r.body.Read(p)
+			r.offset += int64(n)
+			if errors.Is(err, io.EOF) && r.offset <= r.end {
+				// The stream ended before the end of the requested range
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		if err == nil || (errors.Is(err, io.EOF) && r.offset > r.end) {
+			return n, err
+		}
+		if !isTransient(err) || retries >= r.maxRetries {
+			return n, err
+		}
+		r.closeBody()
+		if n > 0 {
+			// Hand over what was read; the range is reopened on the next call
+			return n, nil
+		}
+		retries++
+		backoff := time.Duration(1<<(retries-1)) * 500 * time.Millisecond
+		r.logger.Warn("Read attempt %v at offset %v failed with error %v, retrying in %v", retries, r.offset, err, backoff)
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (r *resumableReader) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+}
+
+func (r *resumableReader) Close() error {
+	r.closeBody()
+	return nil
+}
+
+type chunkResult struct {
+	data []byte
+	err  error
+}
+
+// prefetchReader splits a byte range into chunks that are fetched in parallel
+// while being handed over to the consumer in their original order.
+type prefetchReader struct {
+	cancel  context.CancelFunc
+	results chan chan chunkResult
+	current []byte
+	err     error
+}
+
+func newPrefetchReader(ctx context.Context, open RangeOpener, start, end, chunkSize int64, parallelism, maxRetries int) *prefetchReader {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &prefetchReader{
+		cancel:  cancel,
+		results: make(chan chan chunkResult, parallelism-1),
+	}
+	logger := utils.GetLogger()
+	go func() {
+		defer close(r.results)
+		for chunkStart := start; chunkStart <= end; chunkStart += chunkSize {
+			chunkEnd := min(chunkStart+chunkSize-1, end)
+			result := make(chan chunkResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case r.results <- result:
+			}
+			go func(chunkStart, chunkEnd int64) {
+				reader := &resumableReader{
+					ctx:        ctx,
+					open:       open,
+					offset:     chunkStart,
+					end:        chunkEnd,
+					maxRetries: maxRetries,
+					logger:     logger,
+				}
+				defer reader.Close()
+				data, err := io.ReadAll(reader)
+				result <- chunkResult{data: data, err: err}
+			}(chunkStart, chunkEnd)
+		}
+	}()
+	return r
+}
+
+func (r *prefetchReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		result, ok := <-r.results
+		if !ok {
+			r.err = io.EOF
+			continue
+		}
+		chunk := <-result
+		r.current, r.err = chunk.data, chunk.err
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+func (r *prefetchReader) Close() error {
+	r.cancel()
+	// Drain pending chunks so that no fetching goroutine stays blocked
+	go func() {
+		for range r.results {
+		}
+	}()
+	return nil
+}
+
+func withRetries(ctx context.Context, maxRetries int, operation func() error) error {
+	logger := utils.GetLogger()
+	err := operation()
+	for retries := 1; retries <= maxRetries && err != nil && isTransient(err); retries++ {
+		backoff := time.Duration(1<<(retries-1)) * 500 * time.Millisecond
+		logger.Warn("Attempt %v failed with error %v, retrying in %v", retries, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		err = operation()
+	}
+	return err
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	errResponse := minio.ToErrorResponse(err)
+	switch errResponse.StatusCode {
+	case 408, 429, 500, 502, 503, 504:
+		return true
+	}
+	switch errResponse.Code {
+	case "SlowDown", "InternalError", "ServiceUnavailable", "RequestTimeout":
+		return true
+	}
+	return false
+}
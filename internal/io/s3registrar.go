@@ -4,7 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	stdio "io"
+	"net/http"
+	"os"
 	"strings"
 
 	"github.com/Assifar-Karim/apollo/internal/proto"
@@ -16,12 +22,79 @@ import (
 )
 
 type Credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	SessionToken   string `json:"sessionToken,omitempty"`
+	Region         string `json:"region,omitempty"`
+	ForcePathStyle bool   `json:"forcePathStyle,omitempty"`
+	CABundle       string `json:"caBundle,omitempty"` // PEM encoded certificates trusted on top of the system ones
+}
+
+const (
+	CredentialsDir  = "/apollo/credentials"
+	CredentialsFile = "storage.json"
+)
+
+// ReadMountedCredentials loads the object storage credentials that the coordinator mounts in the worker pods
+func ReadMountedCredentials() (*proto.Credentials, error) {
+	content, err := os.ReadFile(fmt.Sprintf("%s/%s", CredentialsDir, CredentialsFile))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("can't read mounted object storage credentials: %s", err))
+	}
+	var creds Credentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("can't parse mounted object storage credentials: %s", err))
+	}
+	return creds.ToProto(), nil
+}
+
+func (c Credentials) ToProto() *proto.Credentials {
+	credentials := &proto.Credentials{
+		Username:     c.Username,
+		Password:     c.Password,
+		SessionToken: c.SessionToken,
+	}
+	if c.Region != "" {
+		credentials.Region = &c.Region
+	}
+	if c.ForcePathStyle {
+		credentials.ForcePathStyle = &c.ForcePathStyle
+	}
+	if c.CABundle != "" {
+		credentials.CaBundle = []byte(c.CABundle)
+	}
+	return credentials
+}
+
+func CredentialsFromProto(credentials *proto.Credentials) Credentials {
+	return Credentials{
+		Username:       credentials.GetUsername(),
+		Password:       credentials.GetPassword(),
+		SessionToken:   credentials.GetSessionToken(),
+		Region:         credentials.GetRegion(),
+		ForcePathStyle: credentials.GetForcePathStyle(),
+		CABundle:       string(credentials.GetCaBundle()),
+	}
+}
+
+type FileMetadata struct {
+	Size      int64
+	ETag      string
+	VersionId string
 }
 
 type S3Registrar struct {
-	minioClient *minio.Client
+	minioClient         *minio.Client
+	maxRetries          int
+	prefetchParallelism int
+	prefetchChunkSize   int64
+}
+
+// SetPrefetch enables the parallel fetching of sub-ranges of a split, a parallelism of 1 or less disables it and it is
+// capped to MaxPrefetchParallelism
+func (r *S3Registrar) SetPrefetch(parallelism int, chunkSize int64) {
+	r.prefetchParallelism = parallelism
+	r.prefetchChunkSize = chunkSize
 }
 
 func (r S3Registrar) GetFile(fileData *proto.FileData) (*bufio.Scanner, Closeable, error) {
@@ -36,31 +109,65 @@ func (r S3Registrar) GetFile(fileData *proto.FileData) (*bufio.Scanner, Closeabl
 	if splitStart == splitEnd && splitStart == 0 {
 		return nil, nil, status.Error(codes.FailedPrecondition, "can't handle empty split")
 	}
-	objectOptions := minio.GetObjectOptions{}
-	objectOptions.SetRange(splitStart, splitEnd)
-
+	ctx := context.Background()
 	pathInfo := strings.Split(fileData.GetPath(), "/")
+	bucket := pathInfo[len(pathInfo)-2]
+	filename := pathInfo[len(pathInfo)-1]
 
-	// This check is added to verify whether the stored file trully exists in the object storage or not and if the app can access it
-	_, err := r.minioClient.StatObject(context.Background(), pathInfo[len(pathInfo)-2], pathInfo[len(pathInfo)-1], objectOptions)
+	snapshotOptions, err := getSnapshotOptions(fileData)
 	if err != nil {
-		return nil, nil, status.Error(codes.Internal, err.Error())
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	object, err := r.minioClient.GetObject(context.Background(), pathInfo[len(pathInfo)-2], pathInfo[len(pathInfo)-1], objectOptions)
+	// This check is added to verify whether the stored file trully exists in the object storage or not and if the app can access it
+	var stats minio.ObjectInfo
+	err = withRetries(ctx, r.maxRetries, func() error {
+		var err error
+		stats, err = r.minioClient.StatObject(ctx, bucket, filename, snapshotOptions)
+		return err
+	})
 	if err != nil {
-		return nil, nil, status.Error(codes.Internal, err.Error())
+		return nil, nil, snapshotError(fileData, err)
 	}
+	// The last split end points right after the end of the object
+	splitEnd = min(splitEnd, stats.Size-1)
+
+	open := func(ctx context.Context, start, end int64) (stdio.ReadCloser, error) {
+		objectOptions, err := getSnapshotOptions(fileData)
+		if err != nil {
+			return nil, err
+		}
+		if err := objectOptions.SetRange(start, end); err != nil {
+			return nil, err
+		}
+		object, err := r.minioClient.GetObject(ctx, bucket, filename, objectOptions)
+		if err != nil {
+			return nil, err
+		}
+		return &snapshotReader{object: object, fileData: fileData}, nil
+	}
+
+	object := NewRangeReader(ctx, open, splitStart, splitEnd, r.prefetchChunkSize, r.prefetchParallelism, r.maxRetries)
 	scanner := utils.NewScanner(object)
-	return scanner, object, err
+	return scanner, object, nil
 }
 
-func (r S3Registrar) GetFileSize(bucket, filename string) (int64, error) {
-	stats, err := r.minioClient.StatObject(context.Background(), bucket, filename, minio.GetObjectOptions{})
+func (r S3Registrar) GetFileMetadata(bucket, filename string) (FileMetadata, error) {
+	ctx := context.Background()
+	var stats minio.ObjectInfo
+	err := withRetries(ctx, r.maxRetries, func() error {
+		var err error
+		stats, err = r.minioClient.StatObject(ctx, bucket, filename, minio.StatObjectOptions{})
+		return err
+	})
 	if err != nil {
-		return 0, err
+		return FileMetadata{}, err
 	}
-	return stats.Size, nil
+	return FileMetadata{
+		Size:      stats.Size,
+		ETag:      stats.ETag,
+		VersionId: stats.VersionID,
+	}, nil
 }
 
 func (r S3Registrar) WriteFile(path string, content []byte) error {
@@ -68,7 +175,8 @@ func (r S3Registrar) WriteFile(path string, content []byte) error {
 	splittedPath := strings.Split(path, "/")[1:]
 	topBucket := splittedPath[0]
 	jobFolder := splittedPath[1]
-	filename := splittedPath[2]
+	// The files can be nested in folders of the job folder
+	filename := strings.Join(splittedPath[2:], "/")
 	exists, err := r.minioClient.BucketExists(ctx, topBucket)
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
@@ -79,22 +187,104 @@ func (r S3Registrar) WriteFile(path string, content []byte) error {
 			return status.Error(codes.Internal, err.Error())
 		}
 	}
-	_, err = r.minioClient.PutObject(ctx, topBucket, fmt.Sprintf("%v/%v", jobFolder, filename), bytes.NewReader(content), -1, minio.PutObjectOptions{})
+	err = withRetries(ctx, r.maxRetries, func() error {
+		_, err := r.minioClient.PutObject(ctx, topBucket, fmt.Sprintf("%v/%v", jobFolder, filename), bytes.NewReader(content), -1, minio.PutObjectOptions{})
+		return err
+	})
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 	return nil
 }
 
-func NewS3Registrar(endpoint, accessKeyID, secretAccessKey string, useSSL bool) (*S3Registrar, error) {
+func newTransport(useSSL bool, caBundle string) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+	if caBundle == "" {
+		return transport, nil
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, fmt.Errorf("the CA bundle doesn't contain any valid PEM certificate")
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	transport.TLSClientConfig.RootCAs = rootCAs
+	return transport, nil
+}
+
+func NewS3Registrar(endpoint string, useSSL bool, creds Credentials) (*S3Registrar, error) {
+	transport, err := newTransport(useSSL, creds.CABundle)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Couldn't configure %s object storage TLS: %s", endpoint, err))
+	}
+	bucketLookup := minio.BucketLookupAuto
+	if creds.ForcePathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
 	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: useSSL,
+		Creds:        credentials.NewStaticV4(creds.Username, creds.Password, creds.SessionToken),
+		Secure:       useSSL,
+		Region:       creds.Region,
+		BucketLookup: bucketLookup,
+		Transport:    transport,
 	})
 	if err != nil {
 		err = status.Error(codes.PermissionDenied, fmt.Sprintf("Couldn't connect to %s object storage: %s", endpoint, err))
 	}
 	return &S3Registrar{
-		minioClient: client,
+		minioClient:         client,
+		maxRetries:          MaxReadRetries,
+		prefetchParallelism: 1,
+		prefetchChunkSize:   DefaultPrefetchChunk,
 	}, err
 }
+
+// getSnapshotOptions pins reads to the object snapshot observed by the coordinator when the splits were planned
+func getSnapshotOptions(fileData *proto.FileData) (minio.GetObjectOptions, error) {
+	options := minio.GetObjectOptions{VersionID: fileData.GetVersionId()}
+	if etag := fileData.GetEtag(); etag != "" && options.VersionID == "" {
+		if err := options.SetMatchETag(etag); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// snapshotReader reports reads that were rejected because the pinned object snapshot changed
+type snapshotReader struct {
+	object   *minio.Object
+	fileData *proto.FileData
+}
+
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	n, err := r.object.Read(p)
+	if err != nil && isSnapshotMismatch(err) {
+		err = snapshotError(r.fileData, err)
+	}
+	return n, err
+}
+
+func (r *snapshotReader) Close() error {
+	return r.object.Close()
+}
+
+func isSnapshotMismatch(err error) bool {
+	errResponse := minio.ToErrorResponse(err)
+	return errResponse.StatusCode == 412 || errResponse.Code == "PreconditionFailed"
+}
+
+func snapshotError(fileData *proto.FileData, err error) error {
+	if !isSnapshotMismatch(err) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	errMsg := fmt.Sprintf("input %s changed since the job was scheduled (expected ETag %q, version %q)",
+		fileData.GetPath(), fileData.GetEtag(), fileData.GetVersionId())
+	return status.Error(codes.FailedPrecondition, errMsg)
+}
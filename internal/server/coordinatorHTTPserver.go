@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
 	"github.com/Assifar-Karim/apollo/internal/handler"
 	"github.com/Assifar-Karim/apollo/internal/utils"
 	"github.com/go-chi/chi/v5"
@@ -17,7 +18,7 @@ type CoordinatorHTTPSrv struct {
 	router chi.Router
 }
 
-func NewHttpServer(port string, controllers ...*handler.Controller) (*CoordinatorHTTPSrv, error) {
+func NewHttpServer(port string, authenticator coordinator.Authenticator, controllers ...*handler.Controller) (*CoordinatorHTTPSrv, error) {
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		return nil, err
@@ -25,6 +26,7 @@ func NewHttpServer(port string, controllers ...*handler.Controller) (*Coordinato
 
 	router := chi.NewRouter()
 	router.Use(middleware.Logger)
+	router.Use(handler.Authenticate(authenticator))
 	for _, controller := range controllers {
 		router.Mount(controller.Pattern, controller.Router)
 	}
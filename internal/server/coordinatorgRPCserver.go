@@ -0,0 +1,45 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+
+	"github.com/Assifar-Karim/apollo/internal/handler"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type CoordinatorGrpcSrv struct {
+	port        string
+	lis         net.Listener
+	concreteSrv *grpc.Server
+}
+
+// NewCoordinatorGrpcServer serves the task dispatcher the pulling workers talk to, they must present the certificate
+// issued for their job
+func NewCoordinatorGrpcServer(port string, tlsConfig *tls.Config, taskDispatcherHandler *handler.TaskDispatcherHandler) (*CoordinatorGrpcSrv, error) {
+	lis, err := net.Listen("tcp", port)
+	if err != nil {
+		return nil, err
+	}
+	serverRegistrar := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	proto.RegisterTaskDispatcherServer(serverRegistrar, taskDispatcherHandler)
+	return &CoordinatorGrpcSrv{
+		port:        port,
+		lis:         lis,
+		concreteSrv: serverRegistrar,
+	}, nil
+}
+
+func (c CoordinatorGrpcSrv) Serve() error {
+	logger := utils.GetLogger()
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	logger.Info("Task Dispatcher Running: %s%s", hostname, c.port)
+	return c.concreteSrv.Serve(c.lis)
+}
@@ -3,11 +3,13 @@ package server
 import (
 	"net"
 	"os"
+	"slices"
 
 	"github.com/Assifar-Karim/apollo/internal/handler"
 	"github.com/Assifar-Karim/apollo/internal/proto"
 	"github.com/Assifar-Karim/apollo/internal/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type WorkerGrpcSrv struct {
@@ -21,7 +23,19 @@ func NewGrpcServer(port string, taskCreatorHandler handler.TaskCreatorHandler) (
 	if err != nil {
 		return nil, err
 	}
-	serverRegistrar := grpc.NewServer()
+	// Only the coordinator holding a certificate signed by the job CA is allowed to start tasks
+	var serverRegistrar *grpc.Server
+	if slices.Contains(os.Args[1:], "--insecure") {
+		utils.GetLogger().Warn("Worker server running without mutual TLS")
+		serverRegistrar = grpc.NewServer()
+	} else {
+		tlsConfig, err := utils.NewWorkerTLSConfig(utils.TLSDir)
+		if err != nil {
+			lis.Close()
+			return nil, err
+		}
+		serverRegistrar = grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 	proto.RegisterTaskCreatorServer(serverRegistrar, taskCreatorHandler)
 	return &WorkerGrpcSrv{
 		port:        port,
@@ -39,3 +53,8 @@ func (w WorkerGrpcSrv) Serve() error {
 	logger.Info("Worker Server Running: %s%s", hostname, w.port)
 	return w.concreteSrv.Serve(w.lis)
 }
+
+// GracefulStop stops accepting connections and returns once the running tasks answered the coordinator
+func (w WorkerGrpcSrv) GracefulStop() {
+	w.concreteSrv.GracefulStop()
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+const (
+	TLSDir                 = "/apollo/tls"
+	CoordinatorCommonName  = "apollo-coordinator"
+	WorkerCommonNamePrefix = "apollo-worker-"
+)
+
+// NewWorkerTLSConfig builds the worker gRPC server TLS configuration that only accepts the coordinator as a client
+func NewWorkerTLSConfig(dir string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(fmt.Sprintf("%s/tls.crt", dir), fmt.Sprintf("%s/tls.key", dir))
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := os.ReadFile(fmt.Sprintf("%s/ca.crt", dir))
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s/ca.crt doesn't contain any valid PEM certificate", dir)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		VerifyPeerCertificate: func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+			for _, chain := range verifiedChains {
+				if len(chain) > 0 && chain[0].Subject.CommonName == CoordinatorCommonName {
+					return nil
+				}
+			}
+			return fmt.Errorf("only the coordinator is allowed to submit tasks")
+		},
+	}, nil
+}
+
+// NewWorkerClientTLSConfig builds the configuration used by a pulling worker to reach the coordinator task dispatcher
+// with the certificate of its job
+func NewWorkerClientTLSConfig(dir string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(fmt.Sprintf("%s/tls.crt", dir), fmt.Sprintf("%s/tls.key", dir))
+	if err != nil {
+		return nil, err
+	}
+	caPEM, err := os.ReadFile(fmt.Sprintf("%s/ca.crt", dir))
+	if err != nil {
+		return nil, err
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s/ca.crt doesn't contain any valid PEM certificate", dir)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      rootCAs,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
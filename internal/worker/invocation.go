@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+)
+
+// Bounds of the program output kept for the failed invocations of a task
+const (
+	MaxOutputTail     = 4 * 1024
+	MaxInvocationLogs = 16
+)
+
+// tailWriter keeps the last bytes written to it
+type tailWriter struct {
+	limit int
+	buf   []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.limit {
+		w.buf = w.buf[len(w.buf)-w.limit:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	return string(w.buf)
+}
+
+// programOutput captures the tails of the standard output and error of a program invocation
+type programOutput struct {
+	cmd        *exec.Cmd
+	invocation int64
+	stdout     tailWriter
+	stderr     tailWriter
+}
+
+// fail records the log of a failed invocation and returns the failure along with the last line the program wrote to
+// its standard error
+func (o *programOutput) fail(err error, progress *Progress) error {
+	exitCode := int64(-1)
+	if o.cmd.ProcessState != nil {
+		exitCode = int64(o.cmd.ProcessState.ExitCode())
+	}
+	stderr := o.stderr.String()
+	progress.AddInvocationLog(&proto.InvocationLog{
+		Invocation: o.invocation,
+		ExitCode:   exitCode,
+		Stdout:     o.stdout.String(),
+		Stderr:     stderr,
+	})
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if lastLine := lines[len(lines)-1]; lastLine != "" {
+		return fmt.Errorf("%w: %s", err, lastLine)
+	}
+	return err
+}
+
+func captureOutput(cmd *exec.Cmd, invocation int64) *programOutput {
+	output := &programOutput{
+		cmd:        cmd,
+		invocation: invocation,
+		stdout:     tailWriter{limit: MaxOutputTail},
+		stderr:     tailWriter{limit: MaxOutputTail},
+	}
+	cmd.Stdout = &output.stdout
+	cmd.Stderr = &output.stderr
+	return output
+}
@@ -4,12 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Assifar-Karim/apollo/internal/io"
 	"github.com/Assifar-Karim/apollo/internal/proto"
@@ -19,15 +20,22 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// socketDrainTimeout bounds the time spent accepting the connections left once all the programs have exited
+const socketDrainTimeout = 100 * time.Millisecond
+
 type Mapper struct {
 	inputFSRegistrar  io.FSRegistrar
 	outputFSRegistrar io.FSRegistrar
 	output            map[int][]KVPair
+	progress          *Progress
+	workspace         *Workspace
 	logger            *utils.Logger
 }
 
 type KVPairArray struct {
 	Pairs []KVPair `json:"pairs"`
+	// Named counters the programs increment along with the pairs they emit
+	Counters map[string]int64 `json:"counters,omitempty"`
 }
 type KVPair struct {
 	Key   any `json:"key"`
@@ -39,7 +47,7 @@ type partitionPayload struct {
 	pair         KVPair
 }
 
-func (m *Mapper) setinputFSRegistrar(fileData *proto.FileData, credentials *proto.Credentials) error {
+func (m *Mapper) setinputFSRegistrar(fileData *proto.FileData, credentials *proto.Credentials, prefetchParallelism int64) error {
 	path := fileData.GetPath()
 	if path == "" {
 		return status.Error(codes.InvalidArgument, "empty path")
@@ -57,8 +65,9 @@ func (m *Mapper) setinputFSRegistrar(fileData *proto.FileData, credentials *prot
 		return status.Error(codes.InvalidArgument, "wrong protocol, please make sure the protocol is either HTTP or HTTPS")
 	}
 
-	inputFSRegistrar, err := io.NewS3Registrar(endpoint, credentials.GetUsername(), credentials.GetPassword(), useSSL)
+	inputFSRegistrar, err := io.NewS3Registrar(endpoint, useSSL, io.CredentialsFromProto(credentials))
 	if err == nil {
+		inputFSRegistrar.SetPrefetch(int(prefetchParallelism), io.DefaultPrefetchChunk)
 		m.inputFSRegistrar = inputFSRegistrar
 	}
 	return err
@@ -81,74 +90,57 @@ func (m *Mapper) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 	if pContent == nil {
 		return status.Error(codes.InvalidArgument, "empty program content")
 	}
-	err := os.WriteFile(pName, pContent, 0744)
+	// The programs may run as another user than the worker, they have to be able to run the program and reach the socket
+	programPath := m.workspace.ProgramPath(pName)
+	err := os.WriteFile(programPath, pContent, 0755)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	sandbox, err := NewSandbox(task.GetProgramLimits(), m.workspace)
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 
-	socket, err := net.Listen("unix", "/tmp/map.sock")
+	socketPath := m.workspace.SocketPath("map.sock")
+	socket, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 	defer socket.Close()
-	m.logger.Info("listening on \033[33m/tmp/map.sock\033[0m socket")
+	if err := os.Chmod(socketPath, 0777); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	m.logger.Info("listening on \033[33m%s\033[0m socket", socketPath)
 
+	m.progress.SetPhase(PhaseMap)
+	concurrency := GetProgramConcurrency(task)
+	m.logger.Info("Running up to %v programs at once", concurrency)
+	skipRecords := make(map[int64]bool, len(task.GetSkipRecords()))
+	for _, offset := range task.GetSkipRecords() {
+		skipRecords[offset] = true
+	}
 	output := make(map[int][]KVPair)
 	endLine := ""
+	// Offset of the record currently read, it identifies the record across the attempts of the task
+	offset := task.InputData[0].GetSplitStart()
 	for idx, scanner := range input {
 		lineNumber := 0
 		// Skip the first line of every input split that doesn't start at offset 0
 		if task.InputData[idx].GetSplitStart() != 0 {
 			scanner.Scan()
-		}
-		// Producers
-		pairsChan := make(chan partitionPayload)
-		var eg errgroup.Group
-		for idx == 0 && scanner.Scan() {
-			line := scanner.Text()
-			// Check if the line is incomplete unless it's in the final input split
-			rLine := []rune(line)
-			if len(input) > 1 && rLine[len(line)-1] != '\n' {
-				endLine += line
-				break
+			if idx == 0 {
+				offset += int64(len(scanner.Bytes()))
 			}
-			// Remove the newline character from the line
-			if rLine[len(line)-1] == '\n' {
-				line = line[0 : len(line)-1]
-			}
-			eg.Go(func() error {
-				cmd := exec.Command(pName, fmt.Sprintf("%v", lineNumber), line)
-				if err := cmd.Start(); err != nil {
-					return err
-				}
-				return cmd.Wait()
-			})
-
-			lineNumber++
-		}
-		if idx == 1 {
-			line := endLine + scanner.Text()
-			// Remove the newline character from the line
-			line = line[0 : len(line)-1]
-			eg.Go(func() error {
-				cmd := exec.Command(pName, fmt.Sprintf("%v", lineNumber), line)
-				if err := cmd.Start(); err != nil {
-					return err
-				}
-				return cmd.Wait()
-			})
-			lineNumber++
 		}
-		// Consumers
-		for i := 0; i < lineNumber; i++ {
-			eg.Go(func() error {
-				fd, err := socket.Accept()
-				if err != nil {
-					return err
-				}
-
+		pairsChan := make(chan partitionPayload)
+		// Consumers are started first and aren't bounded, the producers waiting for a free slot can't leave the
+		// connections of the programs that already ran unaccepted
+		var consumers errgroup.Group
+		accepted := make(chan error, 1)
+		go func() {
+			accepted <- acceptConnections(socket, &consumers, func(fd net.Conn) error {
 				buf := make([]byte, 1024)
-				_, err = fd.Read(buf)
+				_, err := fd.Read(buf)
 				if err != nil {
 					return err
 				}
@@ -159,6 +151,8 @@ func (m *Mapper) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 					return err
 				}
 				fd.Close()
+				m.progress.AddRecords(1)
+				m.progress.AddCounters(pairsArray.Counters)
 
 				for _, pair := range pairsArray.Pairs {
 					paritionKey, err := utils.Hash(pair.Key)
@@ -166,6 +160,7 @@ func (m *Mapper) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 						return err
 					}
 					paritionKey = paritionKey % int(nReducers)
+					m.progress.AddPairs(int64(paritionKey), 1)
 					pairsChan <- partitionPayload{
 						partitionKey: paritionKey,
 						pair:         pair,
@@ -173,7 +168,7 @@ func (m *Mapper) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 				}
 				return nil
 			})
-		}
+		}()
 		var wg sync.WaitGroup
 		wg.Add(1)
 		go func() {
@@ -184,16 +179,102 @@ func (m *Mapper) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 			}
 			wg.Done()
 		}()
-		if err = eg.Wait(); err != nil {
-			return status.Error(codes.Internal, err.Error())
+		// Producers
+		var eg errgroup.Group
+		eg.SetLimit(concurrency)
+		runProgram := func(recordOffset int64, line string) {
+			if skipRecords[recordOffset] {
+				m.logger.Warn("Skipping the record at offset %v", recordOffset)
+				m.progress.AddSkippedRecord(recordOffset, line)
+				return
+			}
+			recordNumber := lineNumber
+			eg.Go(func() error {
+				invocation, err := sandbox.Command(programPath, fmt.Sprintf("%v", recordNumber), line)
+				if err != nil {
+					return err
+				}
+				defer invocation.Close()
+				output := captureOutput(invocation.cmd, int64(recordNumber))
+				if err := invocation.cmd.Start(); err != nil {
+					return output.fail(err, m.progress)
+				}
+				if err := invocation.cmd.Wait(); err != nil {
+					m.progress.AddBadRecord(recordOffset)
+					return fmt.Errorf("the program failed on the record at offset %v -> %w", recordOffset,
+						output.fail(invocation.explain(err), m.progress))
+				}
+				return nil
+			})
+			lineNumber++
+		}
+		for idx == 0 && scanner.Scan() {
+			line := scanner.Text()
+			m.progress.AddBytes(int64(len(line)))
+			// Check if the line is incomplete unless it's in the final input split
+			rLine := []rune(line)
+			if len(input) > 1 && rLine[len(line)-1] != '\n' {
+				endLine += line
+				break
+			}
+			recordOffset := offset
+			offset += int64(len(line))
+			// Remove the newline character from the line
+			if rLine[len(line)-1] == '\n' {
+				line = line[0 : len(line)-1]
+			}
+			runProgram(recordOffset, line)
 		}
+		if idx == 1 {
+			line := endLine + scanner.Text()
+			m.progress.AddBytes(int64(len(line) - len(endLine)))
+			// Remove the newline character from the line
+			line = line[0 : len(line)-1]
+			runProgram(offset, line)
+		}
+		producersErr := eg.Wait()
+		// Every program has exited by now, the connections they opened are already waiting to be accepted
+		socket.SetDeadline(time.Now().Add(socketDrainTimeout))
+		acceptErr := <-accepted
+		consumersErr := consumers.Wait()
 		close(pairsChan)
 		wg.Wait()
+		for _, err := range []error{producersErr, acceptErr, consumersErr} {
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		socket.SetDeadline(time.Time{})
 	}
 	m.output = output
 	return nil
 }
 
+// acceptConnections hands the connections of the programs to consumers until the socket deadline is reached, the
+// programs crashing before connecting don't leave a consumer waiting forever
+func acceptConnections(socket *net.UnixListener, consumers *errgroup.Group, consume func(fd net.Conn) error) error {
+	for {
+		fd, err := socket.Accept()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		consumers.Go(func() error {
+			return consume(fd)
+		})
+	}
+}
+
+func (m *Mapper) SetProgress(progress *Progress) {
+	m.progress = progress
+}
+
+func (m *Mapper) SetWorkspace(workspace *Workspace) {
+	m.workspace = workspace
+}
+
 func (m *Mapper) FetchInputData(task *proto.Task) ([]*bufio.Scanner, []io.Closeable, error) {
 	inputData := task.GetInputData()
 	if len(inputData) == 0 {
@@ -202,13 +283,20 @@ func (m *Mapper) FetchInputData(task *proto.Task) ([]*bufio.Scanner, []io.Closea
 	m.logger.Info("Fetching the following input data: %v", inputData)
 	creds := task.GetObjectStorageCreds()
 	if creds == nil {
-		return nil, nil, status.Error(codes.InvalidArgument, "can't find object storage credential infos")
+		var err error
+		if creds, err = io.ReadMountedCredentials(); err != nil {
+			return nil, nil, err
+		}
 	}
 
+	// The following split is only read up to the end of the last line of the split
+	if inputData[0].SplitEnd != nil {
+		m.progress.SetBytesTotal(inputData[0].GetSplitEnd() - inputData[0].GetSplitStart())
+	}
 	scanners := make([]*bufio.Scanner, 0)
 	closeables := make([]io.Closeable, 0)
 	for _, fileData := range inputData {
-		err := m.setinputFSRegistrar(fileData, creds)
+		err := m.setinputFSRegistrar(fileData, creds, task.GetPrefetchParallelism())
 		if err != nil {
 			return nil, nil, err
 		}
@@ -246,6 +334,7 @@ func (m *Mapper) PersistOutputData(task *proto.Task) ([]*proto.FileData, error)
 			resultingFiles[partitionKey] = &proto.FileData{
 				Path: path,
 			}
+			m.progress.AddOutput(int64(len(partition)), int64(len(jsonPartition)))
 			return m.outputFSRegistrar.WriteFile(path, jsonPartition)
 		})
 	}
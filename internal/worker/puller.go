@@ -0,0 +1,234 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Environment variables the coordinator sets on the workers pulling their tasks
+const (
+	CoordinatorAddressEnv = "COORDINATOR_ADDRESS"
+	WorkerIdEnv           = "APOLLO_WORKER_ID"
+	JobIdEnv              = "APOLLO_JOB_ID"
+	TaskTypeEnv           = "APOLLO_TASK_TYPE"
+)
+
+const (
+	registrationAttempts = 30
+	registrationDelay    = 2 * time.Second
+	// The final status of a task is reported again with a doubling delay until the coordinator acknowledges it
+	reportRetryDelay    = 500 * time.Millisecond
+	maxReportRetryDelay = 30 * time.Second
+)
+
+// errTaskTakenBack is returned for the tasks the coordinator handed to another poll before this worker acknowledged them
+var errTaskTakenBack = errors.New("the task was handed out again")
+
+// TaskPuller registers a worker to the coordinator task dispatcher and runs the tasks it hands out
+type TaskPuller struct {
+	client   proto.TaskDispatcherClient
+	worker   *Worker
+	workerId string
+	jobId    string
+	taskType int64
+	// Only set when the worker runs a single task as an Indexed Job pod
+	completionIndex *int64
+	logger          *utils.Logger
+	taskLock        sync.Mutex
+	taskId          *string
+}
+
+// Run pulls tasks until the worker is deleted, an Indexed Job worker returns the outcome of its single task
+func (p *TaskPuller) Run() error {
+	heartbeatInterval, err := p.register()
+	if err != nil {
+		return err
+	}
+	go p.sendHeartbeats(heartbeatInterval)
+	for {
+		assignment, err := p.client.PollTask(context.Background(), &proto.TaskRequest{
+			WorkerId:        p.workerId,
+			CompletionIndex: p.completionIndex,
+		})
+		if status.Code(err) == codes.NotFound {
+			// The coordinator restarted and forgot about this worker
+			if _, err := p.register(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			p.logger.Warn("Could not poll a task -> %v", err)
+			time.Sleep(registrationDelay)
+			continue
+		}
+		if assignment.Task == nil {
+			continue
+		}
+		taskErr := p.runTask(assignment.Task)
+		if errors.Is(taskErr, errTaskTakenBack) {
+			continue
+		}
+		if p.completionIndex != nil {
+			return taskErr
+		}
+	}
+}
+
+func (p *TaskPuller) register() (time.Duration, error) {
+	registration := &proto.WorkerRegistration{
+		WorkerId: p.workerId,
+		JobId:    p.jobId,
+		Type:     p.taskType,
+	}
+	var err error
+	for attempt := 1; attempt <= registrationAttempts; attempt++ {
+		var ack *proto.RegistrationAck
+		ack, err = p.client.RegisterWorker(context.Background(), registration)
+		if err == nil {
+			p.logger.Info("Worker %s registered to the task dispatcher", p.workerId)
+			return time.Duration(ack.GetHeartbeatIntervalMs()) * time.Millisecond, nil
+		}
+		p.logger.Warn("Registration attempt %v failed -> %v", attempt, err)
+		time.Sleep(registrationDelay)
+	}
+	return 0, fmt.Errorf("worker %s couldn't register to the task dispatcher -> %w", p.workerId, err)
+}
+
+// sendHeartbeats keeps the worker alive for the coordinator, a program that hangs doesn't stop them and is failed by the
+// timeout of its invocation instead
+func (p *TaskPuller) sendHeartbeats(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.sendHeartbeat(interval)
+	}
+}
+
+// sendHeartbeat holds the task lock until the coordinator got the heartbeat, a heartbeat that doesn't name the task
+// the worker is running would make the coordinator fail it as lost. The heartbeat is given up after an interval so
+// that the task doesn't wait for it
+func (p *TaskPuller) sendHeartbeat(timeout time.Duration) {
+	p.taskLock.Lock()
+	defer p.taskLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	heartbeat := &proto.Heartbeat{WorkerId: p.workerId, TaskId: p.taskId}
+	if heartbeat.TaskId != nil {
+		heartbeat.Progress = p.worker.Progress()
+	}
+	if _, err := p.client.SendHeartbeat(ctx, heartbeat); err != nil {
+		p.logger.Warn("Could not send a heartbeat -> %v", err)
+	}
+}
+
+func (p *TaskPuller) runTask(task *proto.Task) error {
+	p.setTaskId(&task.Id)
+	defer p.setTaskId(nil)
+	p.logger.Info("Task %s pulled", task.GetId())
+	workerAlgorithm, err := NewWorkerAlgorithm(task.GetType())
+	if err != nil {
+		p.reportOutcome(task.GetId(), &proto.TaskStatusInfo{TaskStatus: "failed"}, err)
+		return err
+	}
+	p.worker.SetWorkerAlgorithm(workerAlgorithm)
+	if err := p.report(task.GetId(), &proto.TaskStatusInfo{TaskStatus: "idle"}, nil); isRejectedReport(err) {
+		// The task was handed to another worker since this one took too long to acknowledge it
+		p.logger.Warn("Task %s was taken back by the coordinator -> %v", task.GetId(), err)
+		return errTaskTakenBack
+	}
+	p.report(task.GetId(), &proto.TaskStatusInfo{TaskStatus: "in-progress"}, nil)
+	resultingFiles, err := p.worker.Compute(task)
+	if err != nil {
+		p.logger.Error("Task failed")
+		p.logger.Error(err.Error())
+		p.reportOutcome(task.GetId(), p.worker.StatusInfo("failed", nil), err)
+		return err
+	}
+	p.logger.Info("Task completed succesfully")
+	p.reportOutcome(task.GetId(), p.worker.StatusInfo("completed", resultingFiles), nil)
+	return nil
+}
+
+// reportOutcome reports the final status of a task until the coordinator acknowledges it or rejects it for good, a
+// lost outcome would leave the task assigned to this worker
+func (p *TaskPuller) reportOutcome(taskId string, statusInfo *proto.TaskStatusInfo, taskErr error) {
+	delay := reportRetryDelay
+	for {
+		err := p.report(taskId, statusInfo, taskErr)
+		if err == nil || isRejectedReport(err) {
+			return
+		}
+		time.Sleep(delay)
+		delay = min(2*delay, maxReportRetryDelay)
+	}
+}
+
+func (p *TaskPuller) report(taskId string, statusInfo *proto.TaskStatusInfo, taskErr error) error {
+	if statusInfo.ResultingFiles == nil {
+		statusInfo.ResultingFiles = []*proto.FileData{}
+	}
+	report := &proto.TaskStatusReport{
+		WorkerId:   p.workerId,
+		TaskId:     taskId,
+		StatusInfo: statusInfo,
+	}
+	if taskErr != nil {
+		reason := taskErr.Error()
+		report.Error = &reason
+	}
+	_, err := p.client.ReportTaskStatus(context.Background(), report)
+	if err != nil {
+		p.logger.Error("Could not report task %s status %s -> %v", taskId, statusInfo.TaskStatus, err)
+	}
+	return err
+}
+
+// isRejectedReport tells whether the coordinator doesn't know about the worker or its task anymore, reporting again
+// can't succeed
+func isRejectedReport(err error) bool {
+	code := status.Code(err)
+	return code == codes.NotFound || code == codes.FailedPrecondition
+}
+
+func (p *TaskPuller) setTaskId(taskId *string) {
+	p.taskLock.Lock()
+	defer p.taskLock.Unlock()
+	p.taskId = taskId
+}
+
+// NewTaskPuller builds a puller from the identity the coordinator gave to the worker pod through its environment
+func NewTaskPuller(conn grpc.ClientConnInterface, worker *Worker, completionIndex *int64) (*TaskPuller, error) {
+	workerId := os.Getenv(WorkerIdEnv)
+	jobId := os.Getenv(JobIdEnv)
+	if workerId == "" || jobId == "" {
+		return nil, errors.New("the worker and job ids of a pulling worker are required")
+	}
+	taskType, err := strconv.ParseInt(os.Getenv(TaskTypeEnv), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskPuller{
+		client:          proto.NewTaskDispatcherClient(conn),
+		worker:          worker,
+		workerId:        workerId,
+		jobId:           jobId,
+		taskType:        taskType,
+		completionIndex: completionIndex,
+		logger:          utils.GetLogger(),
+	}, nil
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"regexp"
 	"sort"
 	"strings"
@@ -26,12 +25,15 @@ type Reducer struct {
 	outputFSRegistrar io.FSRegistrar
 	idRegs            []*regexp.Regexp
 	output            []KVPair
+	progress          *Progress
+	workspace         *Workspace
 	logger            *utils.Logger
 }
 
 type OrderedKVPair struct {
-	Key   KVPair `json:"key"`
-	Value any    `json:"value"`
+	Key      KVPair           `json:"key"`
+	Value    any              `json:"value"`
+	Counters map[string]int64 `json:"counters,omitempty"`
 }
 
 func (r *Reducer) setOutputFSRegistrar(storageData *proto.OutputStorageInfo, credentials *proto.Credentials) error {
@@ -51,19 +53,20 @@ func (r *Reducer) setOutputFSRegistrar(storageData *proto.OutputStorageInfo, cre
 	} else {
 		useSSL = storageData.GetUseSSL()
 	}
-	outputFSRegistrar, err := io.NewS3Registrar(location, credentials.GetUsername(), credentials.GetPassword(), useSSL)
+	outputFSRegistrar, err := io.NewS3Registrar(location, useSSL, io.CredentialsFromProto(credentials))
 	if err == nil {
 		r.outputFSRegistrar = outputFSRegistrar
 	}
 	return err
 }
 
-func fuse(scanners []*bufio.Scanner) ([]KVPair, error) {
+func fuse(scanners []*bufio.Scanner, progress *Progress) ([]KVPair, error) {
 	pairs := make([]KVPair, 0)
 	for _, scanner := range scanners {
 		buf := make([]byte, 0)
 		for scanner.Scan() {
 			buf = append(buf, scanner.Bytes()...)
+			progress.AddBytes(int64(len(scanner.Bytes())))
 		}
 		var scannerPairsArray KVPairArray
 		if err := json.Unmarshal(buf, &scannerPairsArray); err != nil {
@@ -98,6 +101,14 @@ func shuffle(pairs []KVPair) []KVPair {
 	return res
 }
 
+func (r *Reducer) SetProgress(progress *Progress) {
+	r.progress = progress
+}
+
+func (r *Reducer) SetWorkspace(workspace *Workspace) {
+	r.workspace = workspace
+}
+
 func (r *Reducer) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 	program := task.GetProgram()
 	if program == nil {
@@ -111,30 +122,70 @@ func (r *Reducer) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 	if pContent == nil {
 		return status.Error(codes.InvalidArgument, "empty program content")
 	}
-	err := os.WriteFile(pName, pContent, 0744)
+	// The programs may run as another user than the worker, they have to be able to run the program and reach the socket
+	programPath := r.workspace.ProgramPath(pName)
+	err := os.WriteFile(programPath, pContent, 0755)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	sandbox, err := NewSandbox(task.GetProgramLimits(), r.workspace)
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 
-	fusedPairs, err := fuse(input)
+	fusedPairs, err := fuse(input, r.progress)
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
+	r.progress.SetPhase(PhaseSort)
 	pairs := shuffle(fusedPairs)
+	r.progress.SetPhase(PhaseReduce)
 
-	socket, err := net.Listen("unix", "/tmp/reduce.sock")
+	socketPath := r.workspace.SocketPath("reduce.sock")
+	socket, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
 	if err != nil {
 		return status.Error(codes.Internal, err.Error())
 	}
 	defer socket.Close()
-	r.logger.Info("listening on \033[33m/tmp/reduce.sock\033[0m socket")
+	if err := os.Chmod(socketPath, 0777); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	r.logger.Info("listening on \033[33m%s\033[0m socket", socketPath)
 
 	output := make([]KVPair, len(pairs))
 
+	concurrency := GetProgramConcurrency(task)
+	r.logger.Info("Running up to %v programs at once", concurrency)
 	var producerGroup errgroup.Group
-	producerGroup.SetLimit(50)
+	producerGroup.SetLimit(concurrency)
+	// Consumers aren't bounded, a program whose connection waits for a free consumer slot would hold its producer
+	// slot until the consumers it's queued behind are done
 	var consumerGroup errgroup.Group
-	consumerGroup.SetLimit(50)
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- acceptConnections(socket, &consumerGroup, func(fd net.Conn) error {
+			buf := make([]byte, 1024)
+			_, err := fd.Read(buf)
+			if err != nil {
+				return err
+			}
+			buf = bytes.Trim(buf, "\x00")
+			var pair OrderedKVPair
+			err = json.Unmarshal(buf, &pair)
+			if err != nil {
+				return err
+			}
+			fd.Close()
+			output[int(pair.Key.Value.(float64))] = KVPair{
+				Key:   pair.Key.Key,
+				Value: pair.Value,
+			}
+			r.progress.AddRecords(1)
+			r.progress.AddCounters(pair.Counters)
+
+			return nil
+		})
+	}()
 	for idx, p := range pairs {
 		order := idx
 		pair := p
@@ -148,12 +199,18 @@ func (r *Reducer) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 			if err != nil {
 				return err
 			}
-			cmd := exec.Command(pName, fmt.Sprintf("%v", order))
-			if err = cmd.Start(); err != nil {
+			invocation, err := sandbox.Command(programPath, fmt.Sprintf("%v", order))
+			if err != nil {
 				return err
 			}
+			defer invocation.Close()
+			cmd := invocation.cmd
+			output := captureOutput(cmd, int64(order))
+			if err = cmd.Start(); err != nil {
+				return output.fail(err, r.progress)
+			}
 			retry := 0
-			socketLocation := fmt.Sprintf("/tmp/reduce-input-%v.sock", order)
+			socketLocation := r.workspace.SocketPath(fmt.Sprintf("reduce-input-%v.sock", order))
 			r.logger.Info("Trying to connect to %s socket", socketLocation)
 			fd, err := net.Dial("unix", socketLocation)
 			for err != nil && retry < 3 {
@@ -163,44 +220,29 @@ func (r *Reducer) HandleTask(task *proto.Task, input []*bufio.Scanner) error {
 				time.Sleep(time.Duration(retry*5) * time.Second)
 			}
 			if err != nil {
-				return status.Error(codes.Internal, err.Error())
+				// The program most likely crashed before listening, its output tells why
+				cmd.Process.Kill()
+				cmd.Wait()
+				return status.Error(codes.Internal, output.fail(err, r.progress).Error())
 			}
 			defer fd.Close()
 			fd.Write(buf)
-			return cmd.Wait()
-		})
-		// Consumer
-		consumerGroup.Go(func() error {
-			fd, err := socket.Accept()
-			if err != nil {
-				return err
-			}
-			buf := make([]byte, 1024)
-			_, err = fd.Read(buf)
-			if err != nil {
-				return err
-			}
-			buf = bytes.Trim(buf, "\x00")
-			var pair OrderedKVPair
-			err = json.Unmarshal(buf, &pair)
-			if err != nil {
-				return err
+			if err := cmd.Wait(); err != nil {
+				return output.fail(invocation.explain(err), r.progress)
 			}
-			fd.Close()
-			output[int(pair.Key.Value.(float64))] = KVPair{
-				Key:   pair.Key.Key,
-				Value: pair.Value,
-			}
-
 			return nil
 		})
 	}
 
-	if err = producerGroup.Wait(); err != nil {
-		return status.Error(codes.Internal, err.Error())
-	}
-	if err = consumerGroup.Wait(); err != nil {
-		return status.Error(codes.Internal, err.Error())
+	producersErr := producerGroup.Wait()
+	// Every program has exited by now, the connections they opened are already waiting to be accepted
+	socket.SetDeadline(time.Now().Add(socketDrainTimeout))
+	acceptErr := <-accepted
+	consumersErr := consumerGroup.Wait()
+	for _, err := range []error{producersErr, acceptErr, consumersErr} {
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
 	}
 
 	r.output = output
@@ -241,7 +283,10 @@ func (r *Reducer) PersistOutputData(task *proto.Task) ([]*proto.FileData, error)
 	}
 	creds := task.GetObjectStorageCreds()
 	if creds == nil {
-		return nil, status.Error(codes.InvalidArgument, "can't find object storage credential info")
+		var err error
+		if creds, err = io.ReadMountedCredentials(); err != nil {
+			return nil, err
+		}
 	}
 	storageData := task.GetOutputStorageInfo()
 	if storageData == nil {
@@ -264,6 +309,7 @@ func (r *Reducer) PersistOutputData(task *proto.Task) ([]*proto.FileData, error)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	r.progress.AddOutput(int64(len(r.output)), int64(len(buf)))
 	path := fmt.Sprintf("/reducers/%v/%v.json", jobId, reducerNumber)
 	r.logger.Info("Persisting reducer %v to %v", taskId, path)
 	return []*proto.FileData{{Path: path}}, r.outputFSRegistrar.WriteFile(path, buf)
@@ -0,0 +1,196 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+// SandboxCommand is the first argument the worker binary is run with to apply the limits of a program before becoming
+// it, the limits have to be set from within the process since Go can't set them between fork and exec
+const SandboxCommand = "apollo-sandbox"
+
+// Environment variables of the worker setting the unprivileged user the programs run as
+const (
+	ProgramUIDEnv = "PROGRAM_UID"
+	ProgramGIDEnv = "PROGRAM_GID"
+)
+
+// DefaultProgramID is the user and group the programs run as when the worker doesn't set them, nobody on most systems
+const DefaultProgramID = 65534
+
+// programPathEnv is the only environment the programs inherit along with their private HOME and TMPDIR and the socket
+// directory of their task
+const programPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// DefaultProgramTimeout bounds the invocations of the tasks that don't carry a timeout, the heartbeats only tell that
+// the worker is alive so the timeout is what fails a program that hangs
+const DefaultProgramTimeout = time.Hour
+
+// programWaitDelay bounds the time a killed program is given to release its output pipes
+const programWaitDelay = 5 * time.Second
+
+// Sandbox starts the program invocations of a task with the limits the coordinator set and as an unprivileged user
+// when the worker runs as root
+type Sandbox struct {
+	limits     *proto.ProgramLimits
+	credential *syscall.Credential
+	launcher   string
+	workspace  *Workspace
+	logger     *utils.Logger
+}
+
+// Invocation is a program run in its own working directory and process group
+type Invocation struct {
+	cmd     *exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	dir     string
+	timeout time.Duration
+}
+
+// Command returns an invocation of a program that has to be closed once it has exited
+func (s *Sandbox) Command(program string, args ...string) (*Invocation, error) {
+	program, err := filepath.Abs(program)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(s.workspace.ScratchDir(), "program-")
+	if err != nil {
+		return nil, err
+	}
+	if s.credential != nil {
+		if err := os.Chown(dir, int(s.credential.Uid), int(s.credential.Gid)); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	invocation := &Invocation{
+		dir:     dir,
+		timeout: time.Duration(s.limits.GetTimeoutMs()) * time.Millisecond,
+	}
+	if invocation.timeout <= 0 {
+		invocation.timeout = DefaultProgramTimeout
+	}
+	invocation.ctx, invocation.cancel = context.WithTimeout(context.Background(), invocation.timeout)
+	launcherArgs := append([]string{
+		SandboxCommand,
+		strconv.FormatInt(s.limits.GetMemoryBytes(), 10),
+		strconv.FormatInt(s.limits.GetCpuSeconds(), 10),
+		strconv.FormatInt(s.limits.GetOpenFiles(), 10),
+		program,
+	}, args...)
+	cmd := exec.CommandContext(invocation.ctx, s.launcher, launcherArgs...)
+	cmd.Dir = dir
+	cmd.Env = []string{programPathEnv, "HOME=" + dir, "TMPDIR=" + dir, SocketDirEnv + "=" + s.workspace.SocketDir()}
+	// The program gets its own process group so that the processes it forks are killed along with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: s.credential}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = programWaitDelay
+	invocation.cmd = cmd
+	return invocation, nil
+}
+
+// explain tells apart the invocations killed for running past their timeout from the ones that failed on their own
+func (i *Invocation) explain(err error) error {
+	if errors.Is(i.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("the program exceeded its %v timeout -> %w", i.timeout, err)
+	}
+	return err
+}
+
+// Output runs the invocation and returns what the program wrote to its standard output
+func (i *Invocation) Output() ([]byte, error) {
+	output, err := i.cmd.Output()
+	if err != nil {
+		return output, i.explain(err)
+	}
+	return output, nil
+}
+
+// Close kills what's left of the invocation and removes its working directory
+func (i *Invocation) Close() {
+	i.cancel()
+	os.RemoveAll(i.dir)
+}
+
+func NewSandbox(limits *proto.ProgramLimits, workspace *Workspace) (*Sandbox, error) {
+	launcher, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	sandbox := &Sandbox{
+		limits:    limits,
+		launcher:  launcher,
+		workspace: workspace,
+		logger:    utils.GetLogger(),
+	}
+	if os.Geteuid() != 0 {
+		sandbox.logger.Warn("The worker doesn't run as root, the programs run as its user")
+		return sandbox, nil
+	}
+	uid, err := programID(ProgramUIDEnv)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := programID(ProgramGIDEnv)
+	if err != nil {
+		return nil, err
+	}
+	sandbox.credential = &syscall.Credential{Uid: uid, Gid: gid, NoSetGroups: true}
+	// The reduce programs create their input sockets next to the ones of the worker, LegacySocketDir is already
+	// writable by everyone
+	if !workspace.legacySockets {
+		if err := os.Chown(workspace.SocketDir(), int(uid), int(gid)); err != nil {
+			return nil, err
+		}
+	}
+	return sandbox, nil
+}
+
+func programID(env string) (uint32, error) {
+	value, exists := os.LookupEnv(env)
+	if !exists {
+		return DefaultProgramID, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s has to be a user or group id -> %w", env, err)
+	}
+	return uint32(id), nil
+}
+
+// RunSandboxed applies the limits passed by a sandbox to the current process before replacing it with the program, it
+// only returns when the program can't be started
+func RunSandboxed(args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("usage: %s <memory bytes> <cpu seconds> <open files> <program> [args...]", SandboxCommand)
+	}
+	resources := []int{syscall.RLIMIT_AS, syscall.RLIMIT_CPU, syscall.RLIMIT_NOFILE}
+	for i, resource := range resources {
+		limit, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			return err
+		}
+		// A limit of 0 leaves the resource as the worker has it
+		if limit == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return fmt.Errorf("could not limit resource %v to %v -> %w", resource, limit, err)
+		}
+	}
+	program := args[3]
+	return syscall.Exec(program, args[3:], os.Environ())
+}
@@ -2,26 +2,280 @@ package worker
 
 import (
 	"bufio"
+	"errors"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Assifar-Karim/apollo/internal/io"
 	"github.com/Assifar-Karim/apollo/internal/proto"
 )
 
+// CompletionIndexEnv is set by Kubernetes on the pods of an Indexed Job to the index of the task they run
+const CompletionIndexEnv = "JOB_COMPLETION_INDEX"
+
+// CPUAllocationEnv is set by Kubernetes to the number of CPUs the worker container can use, rounded up
+const CPUAllocationEnv = "WORKER_CPU_ALLOCATION"
+
+// ProgramsPerCPU is the number of program invocations run at once per CPU when the job doesn't set it, the programs
+// spend part of their time starting and exchanging pairs over the sockets
+const ProgramsPerCPU = 2
+
+// DefaultHeartbeatInterval is used when the coordinator didn't set the heartbeat period of a task
+const DefaultHeartbeatInterval = 10 * time.Second
+
 type WorkerAlgorithm interface {
 	FetchInputData(task *proto.Task) ([]*bufio.Scanner, []io.Closeable, error)
 	HandleTask(task *proto.Task, input []*bufio.Scanner) error
 	PersistOutputData(task *proto.Task) ([]*proto.FileData, error)
+	SetProgress(progress *Progress)
+	SetWorkspace(workspace *Workspace)
+}
+
+// Phases a task goes through, map tasks skip the sort and reduce ones while reduce tasks skip the map one
+const (
+	PhaseFetch   = "fetch"
+	PhaseMap     = "map"
+	PhaseSort    = "sort"
+	PhaseReduce  = "reduce"
+	PhasePersist = "persist"
+)
+
+// Built-in counters reported along with the ones incremented by the map and reduce programs
+const (
+	CounterInputRecords = "apollo.input.records"
+	CounterInputBytes   = "apollo.input.bytes"
+	CounterOutputPairs  = "apollo.output.pairs"
+	CounterOutputBytes  = "apollo.output.bytes"
+	// Records a map task skipped because its program kept failing on them
+	CounterSkippedRecords = "apollo.input.skipped"
+)
+
+// Progress counts the work a task has done so far, it is read by the heartbeats while the task updates it
+type Progress struct {
+	recordsProcessed atomic.Int64
+	bytesRead        atomic.Int64
+	bytesTotal       atomic.Int64
+	outputPairs      atomic.Int64
+	outputBytes      atomic.Int64
+	lock             sync.Mutex
+	phase            string
+	pairsEmitted     map[int64]int64
+	counters         map[string]int64
+	badRecords       []int64
+	skippedRecords   []*proto.SkippedRecord
+	invocationLogs   []*proto.InvocationLog
+}
+
+func (p *Progress) SetPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.phase = phase
+}
+
+func (p *Progress) SetBytesTotal(n int64) {
+	if p != nil {
+		p.bytesTotal.Store(n)
+	}
+}
+
+func (p *Progress) AddBytes(n int64) {
+	if p != nil {
+		p.bytesRead.Add(n)
+	}
+}
+
+func (p *Progress) AddRecords(n int64) {
+	if p != nil {
+		p.recordsProcessed.Add(n)
+	}
+}
+
+func (p *Progress) AddPairs(partition, n int64) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.pairsEmitted == nil {
+		p.pairsEmitted = map[int64]int64{}
+	}
+	p.pairsEmitted[partition] += n
+}
+
+// AddCounters increments the counters sent by a map or reduce program
+func (p *Progress) AddCounters(counters map[string]int64) {
+	if p == nil || len(counters) == 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.counters == nil {
+		p.counters = map[string]int64{}
+	}
+	for name, n := range counters {
+		p.counters[name] += n
+	}
+}
+
+// AddBadRecord remembers the offset of a record the map program failed on
+func (p *Progress) AddBadRecord(offset int64) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.badRecords = append(p.badRecords, offset)
+}
+
+// AddSkippedRecord remembers a record the map program wasn't run on so that it ends up in the job dead-letter object
+func (p *Progress) AddSkippedRecord(offset int64, content string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.skippedRecords = append(p.skippedRecords, &proto.SkippedRecord{Offset: offset, Content: content})
+}
+
+// AddInvocationLog remembers the output of a failed program invocation, only the first MaxInvocationLogs are kept
+func (p *Progress) AddInvocationLog(log *proto.InvocationLog) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.invocationLogs) < MaxInvocationLogs {
+		p.invocationLogs = append(p.invocationLogs, log)
+	}
+}
+
+func (p *Progress) AddOutput(pairs, bytes int64) {
+	if p != nil {
+		p.outputPairs.Add(pairs)
+		p.outputBytes.Add(bytes)
+	}
+}
+
+// Counters returns the counters of the task, the built-in ones take precedence over the program counters sharing
+// their name
+func (p *Progress) Counters() map[string]int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	counters := make(map[string]int64, len(p.counters)+5)
+	for name, n := range p.counters {
+		counters[name] = n
+	}
+	if len(p.skippedRecords) != 0 {
+		counters[CounterSkippedRecords] = int64(len(p.skippedRecords))
+	}
+	counters[CounterInputRecords] = p.recordsProcessed.Load()
+	counters[CounterInputBytes] = p.bytesRead.Load()
+	counters[CounterOutputPairs] = p.outputPairs.Load()
+	counters[CounterOutputBytes] = p.outputBytes.Load()
+	return counters
+}
+
+func (p *Progress) Snapshot() *proto.TaskProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pairsEmitted := make(map[int64]int64, len(p.pairsEmitted))
+	for partition, n := range p.pairsEmitted {
+		pairsEmitted[partition] = n
+	}
+	return &proto.TaskProgress{
+		RecordsProcessed: p.recordsProcessed.Load(),
+		Phase:            p.phase,
+		BytesRead:        p.bytesRead.Load(),
+		BytesTotal:       p.bytesTotal.Load(),
+		PairsEmitted:     pairsEmitted,
+	}
+}
+
+func (p *Progress) reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.recordsProcessed.Store(0)
+	p.bytesRead.Store(0)
+	p.bytesTotal.Store(0)
+	p.outputPairs.Store(0)
+	p.outputBytes.Store(0)
+	p.phase = PhaseFetch
+	p.pairsEmitted = map[int64]int64{}
+	p.counters = map[string]int64{}
+	p.badRecords = nil
+	p.skippedRecords = nil
+	p.invocationLogs = nil
 }
 
 type Worker struct {
 	workerAlgorithm WorkerAlgorithm
+	progress        Progress
+}
+
+// NewWorkerAlgorithm returns the algorithm running the tasks of a type, 0 for map and 1 for reduce
+func NewWorkerAlgorithm(taskType int64) (WorkerAlgorithm, error) {
+	switch taskType {
+	case 0:
+		return NewMapper(), nil
+	case 1:
+		return NewReducer(), nil
+	}
+	return nil, errors.New("illegal worker type")
 }
 
 func (w *Worker) SetWorkerAlgorithm(algorithm WorkerAlgorithm) {
 	w.workerAlgorithm = algorithm
 }
 
-func (w Worker) Compute(task *proto.Task) ([]*proto.FileData, error) {
+// Progress returns the progress of the task being computed
+func (w *Worker) Progress() *proto.TaskProgress {
+	return w.progress.Snapshot()
+}
+
+// Counters returns the counters of the last computed task
+func (w *Worker) Counters() map[string]int64 {
+	return w.progress.Counters()
+}
+
+// StatusInfo returns the final status of the last computed task along with its counters, the records it failed on or
+// skipped and the output of its failed program invocations
+func (w *Worker) StatusInfo(taskStatus string, resultingFiles []*proto.FileData) *proto.TaskStatusInfo {
+	w.progress.lock.Lock()
+	badRecords := slices.Clone(w.progress.badRecords)
+	skippedRecords := slices.Clone(w.progress.skippedRecords)
+	invocationLogs := slices.Clone(w.progress.invocationLogs)
+	w.progress.lock.Unlock()
+	statusInfo := &proto.TaskStatusInfo{
+		TaskStatus:     taskStatus,
+		ResultingFiles: resultingFiles,
+		Counters:       w.Counters(),
+		SkippedRecords: skippedRecords,
+		InvocationLogs: invocationLogs,
+	}
+	if taskStatus == "failed" {
+		slices.Sort(badRecords)
+		statusInfo.BadRecords = badRecords
+	}
+	return statusInfo
+}
+
+func (w *Worker) Compute(task *proto.Task) ([]*proto.FileData, error) {
+	w.progress.reset()
+	w.workerAlgorithm.SetProgress(&w.progress)
+	workspace, err := NewWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	defer workspace.Remove()
+	w.workerAlgorithm.SetWorkspace(workspace)
 	scanners, closeables, err := w.workerAlgorithm.FetchInputData(task)
 	if err != nil {
 		return nil, err
@@ -34,9 +288,45 @@ func (w Worker) Compute(task *proto.Task) ([]*proto.FileData, error) {
 	if err != nil {
 		return nil, err
 	}
+	w.progress.SetPhase(PhasePersist)
 	resultingFiles, err := w.workerAlgorithm.PersistOutputData(task)
 	if err != nil {
 		return nil, err
 	}
 	return resultingFiles, nil
 }
+
+// GetHeartbeatInterval returns the period of the heartbeats sent while a task runs
+func GetHeartbeatInterval(task *proto.Task) time.Duration {
+	if task.GetHeartbeatIntervalMs() <= 0 {
+		return DefaultHeartbeatInterval
+	}
+	return time.Duration(task.GetHeartbeatIntervalMs()) * time.Millisecond
+}
+
+// GetProgramConcurrency returns the number of program invocations a task runs at once
+func GetProgramConcurrency(task *proto.Task) int {
+	if task.GetProgramConcurrency() > 0 {
+		return int(task.GetProgramConcurrency())
+	}
+	cpus := runtime.NumCPU()
+	if value, exists := os.LookupEnv(CPUAllocationEnv); exists {
+		if allocation, err := strconv.Atoi(value); err == nil && allocation > 0 {
+			cpus = allocation
+		}
+	}
+	return ProgramsPerCPU * cpus
+}
+
+// GetCompletionIndex returns the task index of a worker running as an Indexed Job pod, nil otherwise
+func GetCompletionIndex() (*int64, error) {
+	value, exists := os.LookupEnv(CompletionIndexEnv)
+	if !exists {
+		return nil, nil
+	}
+	index, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// SocketDirEnv is set for the programs to the directory holding the sockets of their task, map programs connect to
+// map.sock, reduce programs listen on reduce-input-<order>.sock and connect to reduce.sock
+const SocketDirEnv = "APOLLO_SOCKET_DIR"
+
+// LegacySocketDir is where the sockets used to be for every task, the programs that don't read SocketDirEnv keep
+// working on the workers that run their tasks one at a time
+const LegacySocketDir = "/tmp"
+
+// legacySocketPatterns match the sockets a task may leave in LegacySocketDir
+var legacySocketPatterns = []string{"map.sock", "reduce.sock", "reduce-input-*.sock"}
+
+// legacySocketsAllowed is only set on the workers that never run tasks at once, the programs of a task running along
+// the one holding LegacySocketDir would feed the sockets of the other task
+var legacySocketsAllowed atomic.Bool
+
+// legacySocketsTaken is set while a task has its sockets in LegacySocketDir
+var legacySocketsTaken atomic.Bool
+
+// AllowLegacySockets lets the tasks of the worker use LegacySocketDir, it must only be allowed when the worker runs its
+// tasks one at a time
+func AllowLegacySockets(allowed bool) {
+	legacySocketsAllowed.Store(allowed)
+}
+
+// Workspace is the private directory of a task, it holds the task program, its sockets and the scratch space of its
+// program invocations so that the tasks run at once by a worker don't collide
+type Workspace struct {
+	dir string
+	// The task got LegacySocketDir since no other task of the worker was using it
+	legacySockets bool
+}
+
+// ProgramPath returns where the program of the task is written
+func (w *Workspace) ProgramPath(name string) string {
+	return filepath.Join(w.dir, filepath.Base(name))
+}
+
+func (w *Workspace) SocketDir() string {
+	if w.legacySockets {
+		return LegacySocketDir
+	}
+	return filepath.Join(w.dir, "sock")
+}
+
+// SocketPath returns the path of a socket of the task, the socket paths have to stay short on Linux
+func (w *Workspace) SocketPath(name string) string {
+	return filepath.Join(w.SocketDir(), name)
+}
+
+// ScratchDir returns the directory the private working directories of the program invocations are created in
+func (w *Workspace) ScratchDir() string {
+	return filepath.Join(w.dir, "scratch")
+}
+
+// Remove deletes the workspace along with whatever the task left in it
+func (w *Workspace) Remove() error {
+	if w.legacySockets {
+		removeLegacySockets()
+		w.legacySockets = false
+		legacySocketsTaken.Store(false)
+	}
+	return os.RemoveAll(w.dir)
+}
+
+func NewWorkspace() (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "apollo-task-")
+	if err != nil {
+		return nil, err
+	}
+	workspace := &Workspace{dir: dir}
+	// The programs may run as another user than the worker, they have to be able to reach their program and sockets
+	if err := os.Chmod(dir, 0755); err != nil {
+		workspace.Remove()
+		return nil, err
+	}
+	subdirs := []string{workspace.ScratchDir()}
+	workspace.legacySockets = legacySocketsAllowed.Load() && legacySocketsTaken.CompareAndSwap(false, true)
+	if workspace.legacySockets {
+		// The sockets left by a task that didn't get to remove its workspace would prevent the new ones to be created
+		removeLegacySockets()
+	} else {
+		subdirs = append(subdirs, workspace.SocketDir())
+	}
+	for _, subdir := range subdirs {
+		if err := os.Mkdir(subdir, 0755); err != nil {
+			workspace.Remove()
+			return nil, err
+		}
+	}
+	return workspace, nil
+}
+
+func removeLegacySockets() {
+	for _, pattern := range legacySocketPatterns {
+		sockets, _ := filepath.Glob(filepath.Join(LegacySocketDir, pattern))
+		for _, socket := range sockets {
+			os.Remove(socket)
+		}
+	}
+}
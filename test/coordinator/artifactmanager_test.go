@@ -16,7 +16,7 @@ type artifactRepositoryMock struct {
 	calls int
 }
 
-func (r *artifactRepositoryMock) CreateArtifact(name, artifactType, hash string, size int64) (db.Artifact, error) {
+func (r *artifactRepositoryMock) CreateArtifact(name, artifactType, hash, owner, project string, size int64) (db.Artifact, error) {
 	r.calls += 1
 	if name == "new-case" {
 		return db.Artifact{
@@ -78,7 +78,7 @@ func TestCreateArtifactWhenArtifactDataFetchFails(t *testing.T) {
 	artifactManager := coordinator.NewArtifactManager(mockRepository)
 
 	// When
-	_, err := artifactManager.CreateArtifact(filename, "type", reader.Size(), reader)
+	_, err := artifactManager.CreateArtifact(filename, "type", "owner", "project", reader.Size(), reader)
 
 	// Then
 	if mockRepository.calls != 1 && err == nil {
@@ -97,7 +97,7 @@ func TestCreateArtifactWhenArtifactDoesNotExist(t *testing.T) {
 	artifactManager := coordinator.NewArtifactManager(mockRepository)
 
 	// When
-	_, err := artifactManager.CreateArtifact(filename, "type", reader.Size(), reader)
+	_, err := artifactManager.CreateArtifact(filename, "type", "owner", "project", reader.Size(), reader)
 	defer os.Remove(fmt.Sprintf("%s/%s", os.TempDir(), filename))
 
 	// Then
@@ -117,7 +117,7 @@ func TestCreateArtifactWhenArtifactExistsWithSameHash(t *testing.T) {
 	artifactManager := coordinator.NewArtifactManager(mockRepository)
 
 	// When
-	artifact, err := artifactManager.CreateArtifact(filename, "type", reader.Size(), reader)
+	artifact, err := artifactManager.CreateArtifact(filename, "type", "owner", "project", reader.Size(), reader)
 
 	// Then
 	if artifact.Hash != "1c87d5ffba8bd8a4143f34f99beb33dfeb18031a545dc43647f21f4c4b9e99a3" && mockRepository.calls != 1 && err != nil {
@@ -136,7 +136,7 @@ func TestCreateArtifactWhenArtifactExistsWithDifferentHash(t *testing.T) {
 	artifactManager := coordinator.NewArtifactManager(mockRepository)
 
 	// When
-	_, err := artifactManager.CreateArtifact(filename, "type", reader.Size(), reader)
+	_, err := artifactManager.CreateArtifact(filename, "type", "owner", "project", reader.Size(), reader)
 	defer os.Remove(fmt.Sprintf("%s/%s", os.TempDir(), filename))
 
 	// Then
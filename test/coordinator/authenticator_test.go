@@ -0,0 +1,108 @@
+package coordinator
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+)
+
+func writeJSON(t *testing.T, name string, content any) string {
+	path := filepath.Join(t.TempDir(), name)
+	buf, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticateWithAPIToken(t *testing.T) {
+	// Given
+	digest := sha256.Sum256([]byte("secret-token"))
+	tokensPath := writeJSON(t, "tokens.json", []map[string]string{
+		{"user": "alice", "role": "submitter", "tokenHash": fmt.Sprintf("%x", digest)},
+	})
+	authenticator, err := coordinator.NewAuthenticator(tokensPath, "", "", "", "roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	principal, err := authenticator.Authenticate("Bearer secret-token")
+	_, wrongTokenErr := authenticator.Authenticate("Bearer wrong-token")
+
+	// Then
+	if err != nil || principal.User != "alice" || principal.Role != coordinator.RoleSubmitter {
+		t.Errorf("Expected alice submitter principal but found %v (%v)", principal, err)
+	}
+	if wrongTokenErr == nil {
+		t.Errorf("Authentication with an unknown token was expected to fail but it didn't!")
+	}
+}
+
+func TestAuthenticateWithJWT(t *testing.T) {
+	// Given
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksPath := writeJSON(t, "jwks.json", map[string]any{
+		"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	authenticator, err := coordinator.NewAuthenticator("", jwksPath, "issuer", "apollo", "roles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]any{
+		"sub":   "bob",
+		"iss":   "issuer",
+		"aud":   []string{"apollo"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"viewer", "admin"},
+	}
+	validToken := signJWT(t, key, "key-1", claims)
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	expiredToken := signJWT(t, key, "key-1", claims)
+
+	// When
+	principal, err := authenticator.Authenticate("Bearer " + validToken)
+	_, expiredErr := authenticator.Authenticate("Bearer " + expiredToken)
+
+	// Then
+	if err != nil || principal.User != "bob" || principal.Role != coordinator.RoleAdmin {
+		t.Errorf("Expected bob admin principal but found %v (%v)", principal, err)
+	}
+	if expiredErr == nil {
+		t.Errorf("Authentication with an expired token was expected to fail but it didn't!")
+	}
+}
@@ -0,0 +1,84 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+)
+
+func TestRecordSkipperSkipsRecordsOnceTheyFailedEnoughTimes(t *testing.T) {
+	// Given
+	skipAfter := int64(2)
+	skipper := coordinator.NewRecordSkipper(&skipAfter)
+	err := &coordinator.BadRecordsError{Offsets: []int64{42, 7}, Reason: "the program failed"}
+
+	// When
+	firstRetry := skipper.Retry(err)
+	firstSkipList := skipper.SkipList()
+	secondRetry := skipper.Retry(&coordinator.BadRecordsError{Offsets: []int64{42}, Reason: "the program failed"})
+	secondSkipList := skipper.SkipList()
+	thirdRetry := skipper.Retry(err)
+
+	// Then
+	if !firstRetry || !secondRetry {
+		t.Errorf("Expected the first two failures to be retried but got %v and %v", firstRetry, secondRetry)
+	}
+	if len(firstSkipList) != 0 {
+		t.Errorf("Expected no record to be skipped after a single failure but got %v", firstSkipList)
+	}
+	if !reflect.DeepEqual(secondSkipList, []int64{42}) {
+		t.Errorf("Expected the record at offset 42 to be skipped but got %v", secondSkipList)
+	}
+	if thirdRetry {
+		t.Errorf("Expected the task not to be retried more than %v times", skipAfter)
+	}
+}
+
+func TestRecordSkipperOnlyRetriesBadRecordsFailures(t *testing.T) {
+	// Given
+	skipAfter := int64(1)
+	skipper := coordinator.NewRecordSkipper(&skipAfter)
+
+	// When
+	retried := skipper.Retry(errors.New("the pod was evicted"))
+
+	// Then
+	if retried {
+		t.Errorf("Expected a failure unrelated to the records not to be retried")
+	}
+	if skipper := coordinator.NewRecordSkipper(nil); skipper.Retry(&coordinator.BadRecordsError{Offsets: []int64{0}}) {
+		t.Errorf("Expected the bad records not to be skipped when the job didn't ask for it")
+	}
+}
+
+func TestMarshalDeadLetterOrdersRecordsByTaskAndOffset(t *testing.T) {
+	// Given
+	skipped := map[string][]*proto.SkippedRecord{
+		"job-m-1": {{Offset: 10, Content: "c"}},
+		"job-m-0": {{Offset: 30, Content: "b"}, {Offset: 0, Content: "a"}},
+	}
+
+	// When
+	content, err := coordinator.MarshalDeadLetter(skipped)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the dead-letter records to be encoded but got %v", err)
+	}
+	var records []coordinator.DeadLetterRecord
+	if err := json.Unmarshal(content, &records); err != nil {
+		t.Fatalf("Expected a JSON array of records but got %v", err)
+	}
+	expected := []coordinator.DeadLetterRecord{
+		{TaskId: "job-m-0", Offset: 0, Content: "a"},
+		{TaskId: "job-m-0", Offset: 30, Content: "b"},
+		{TaskId: "job-m-1", Offset: 10, Content: "c"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v but got %v", expected, records)
+	}
+}
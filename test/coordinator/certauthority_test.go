@@ -0,0 +1,171 @@
+package coordinator
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+// handshake runs a TLS handshake between both configurations over the loopback and returns the first error of
+// either side
+func handshake(serverConfig, clientConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		server := tls.Server(conn, serverConfig)
+		defer server.Close()
+		err = server.Handshake()
+		if err == nil {
+			// TLS 1.3 clients are done before the server verified their certificate, reading waits for the client
+			_, err = server.Read(make([]byte, 1))
+			if err == io.EOF {
+				err = nil
+			}
+		}
+		serverErr <- err
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return err
+	}
+	client := tls.Client(conn, clientConfig)
+	clientErr := client.Handshake()
+	client.Close()
+	if err := <-serverErr; err != nil {
+		return err
+	}
+	return clientErr
+}
+
+func newCertificateAuthority(t *testing.T) *coordinator.CertificateAuthority {
+	ca, err := coordinator.NewCertificateAuthority()
+	if err != nil {
+		t.Fatalf("The certificate authority creation failed! %v", err)
+	}
+	return ca
+}
+
+// writeWorkerTLS writes the files mounted in the worker pods of a job and returns their directory
+func writeWorkerTLS(t *testing.T, ca *coordinator.CertificateAuthority, jobId string) string {
+	certPEM, keyPEM, err := ca.IssueWorkerCertificate(jobId, "apollo-workers")
+	if err != nil {
+		t.Fatalf("The worker certificate creation failed! %v", err)
+	}
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM, "ca.crt": ca.GetCACertPEM()} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatalf("The %s write failed! %v", name, err)
+		}
+	}
+	return dir
+}
+
+func workerServerTLSConfig(t *testing.T, dir string) *tls.Config {
+	config, err := utils.NewWorkerTLSConfig(dir)
+	if err != nil {
+		t.Fatalf("The worker TLS configuration failed! %v", err)
+	}
+	return config
+}
+
+func workerClientTLSConfig(t *testing.T, dir string) *tls.Config {
+	config, err := utils.NewWorkerClientTLSConfig(dir)
+	if err != nil {
+		t.Fatalf("The worker client TLS configuration failed! %v", err)
+	}
+	config.ServerName = "localhost"
+	return config
+}
+
+func coordinatorClientTLSConfig(ca *coordinator.CertificateAuthority) *tls.Config {
+	config := ca.ClientTLSConfig()
+	config.ServerName = "localhost"
+	return config
+}
+
+func TestWorkerAcceptsTheCoordinator(t *testing.T) {
+	// Given
+	ca := newCertificateAuthority(t)
+	workerDir := writeWorkerTLS(t, ca, "job-1")
+
+	// When
+	err := handshake(workerServerTLSConfig(t, workerDir), coordinatorClientTLSConfig(ca))
+
+	// Then
+	if err != nil {
+		t.Errorf("Expected the coordinator to reach the worker but got %v", err)
+	}
+}
+
+func TestWorkerRejectsClientsOtherThanTheCoordinator(t *testing.T) {
+	// Given
+	ca := newCertificateAuthority(t)
+	workerDir := writeWorkerTLS(t, ca, "job-1")
+	// Another worker holds a certificate of the same CA but not the coordinator common name
+	otherWorkerDir := writeWorkerTLS(t, ca, "job-2")
+
+	// When
+	err := handshake(workerServerTLSConfig(t, workerDir), workerClientTLSConfig(t, otherWorkerDir))
+
+	// Then
+	if err == nil {
+		t.Error("Expected a worker certificate to be rejected as a task submitter")
+	}
+}
+
+func TestWorkerRejectsCertificatesOfForeignCA(t *testing.T) {
+	// Given
+	ca := newCertificateAuthority(t)
+	foreignCA := newCertificateAuthority(t)
+	workerDir := writeWorkerTLS(t, ca, "job-1")
+
+	// When
+	err := handshake(workerServerTLSConfig(t, workerDir), coordinatorClientTLSConfig(foreignCA))
+
+	// Then
+	if err == nil {
+		t.Error("Expected a coordinator certificate of another CA to be rejected")
+	}
+}
+
+func TestDispatcherOnlyAcceptsWorkersOfItsCA(t *testing.T) {
+	// Given
+	ca := newCertificateAuthority(t)
+	foreignCA := newCertificateAuthority(t)
+	dispatcherConfig, err := ca.DispatcherTLSConfig()
+	if err != nil {
+		t.Fatalf("The dispatcher TLS configuration failed! %v", err)
+	}
+	workerDir := writeWorkerTLS(t, ca, "job-1")
+	foreignWorkerDir := writeWorkerTLS(t, foreignCA, "job-1")
+	// The foreign worker trusts the dispatcher so that only the dispatcher can reject it
+	foreignClientConfig := workerClientTLSConfig(t, foreignWorkerDir)
+	foreignClientConfig.RootCAs = workerClientTLSConfig(t, workerDir).RootCAs
+
+	// When
+	workerErr := handshake(dispatcherConfig, workerClientTLSConfig(t, workerDir))
+	foreignWorkerErr := handshake(dispatcherConfig, foreignClientConfig)
+
+	// Then
+	if workerErr != nil {
+		t.Errorf("Expected the worker to reach the dispatcher but got %v", workerErr)
+	}
+	if foreignWorkerErr == nil {
+		t.Error("Expected a worker certificate of another CA to be rejected by the dispatcher")
+	}
+}
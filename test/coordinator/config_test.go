@@ -0,0 +1,21 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+)
+
+func TestProgramTimeoutCantBeLifted(t *testing.T) {
+	// Given
+	config := coordinator.GetConfig()
+
+	// When
+	limits := config.GetProgramLimits()
+
+	// Then
+	if limits.GetTimeoutMs() != worker.DefaultProgramTimeout.Milliseconds() {
+		t.Errorf("Expected the program timeout to default to %v but got %vms", worker.DefaultProgramTimeout, limits.GetTimeoutMs())
+	}
+}
@@ -0,0 +1,104 @@
+package coordinator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func acquireAsync(scheduler coordinator.FairShareScheduler, project db.Project, jobId string, nPods int) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- scheduler.Acquire(project, jobId, nPods)
+	}()
+	return result
+}
+
+func waitGrant(t *testing.T, result chan error, jobId string) {
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Job %s request failed with unexpected error %v", jobId, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Job %s request was expected to be granted but it wasn't!", jobId)
+	}
+}
+
+func expectPending(t *testing.T, result chan error, jobId string) {
+	select {
+	case <-result:
+		t.Fatalf("Job %s request was expected to wait but it was answered!", jobId)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestFairShareServesLeastLoadedProjectFirst(t *testing.T) {
+	// Given
+	heavy := db.Project{Name: "heavy", Weight: 1}
+	light := db.Project{Name: "light", Weight: 1}
+	scheduler := coordinator.NewFairShareScheduler(2)
+	waitGrant(t, acquireAsync(scheduler, heavy, "heavy-1", 1), "heavy-1")
+	waitGrant(t, acquireAsync(scheduler, heavy, "heavy-2", 1), "heavy-2")
+	heavyRequest := acquireAsync(scheduler, heavy, "heavy-3", 1)
+	expectPending(t, heavyRequest, "heavy-3")
+	lightRequest := acquireAsync(scheduler, light, "light-1", 1)
+	expectPending(t, lightRequest, "light-1")
+
+	// When
+	scheduler.Release("heavy-1")
+
+	// Then
+	waitGrant(t, lightRequest, "light-1")
+	expectPending(t, heavyRequest, "heavy-3")
+	scheduler.Release("heavy-2")
+	waitGrant(t, heavyRequest, "heavy-3")
+}
+
+func TestFairShareHonorsProjectWeights(t *testing.T) {
+	// Given
+	big := db.Project{Name: "big", Weight: 3}
+	small := db.Project{Name: "small", Weight: 1}
+	scheduler := coordinator.NewFairShareScheduler(3)
+	waitGrant(t, acquireAsync(scheduler, big, "big-1", 2), "big-1")
+	waitGrant(t, acquireAsync(scheduler, small, "small-1", 1), "small-1")
+	smallRequest := acquireAsync(scheduler, small, "small-2", 1)
+	expectPending(t, smallRequest, "small-2")
+	bigRequest := acquireAsync(scheduler, big, "big-2", 1)
+	expectPending(t, bigRequest, "big-2")
+
+	// When
+	scheduler.Release("small-1")
+
+	// Then
+	// big uses 2 pods for a weight of 3 while small uses none anymore
+	waitGrant(t, smallRequest, "small-2")
+	expectPending(t, bigRequest, "big-2")
+	scheduler.Release("small-2")
+	waitGrant(t, bigRequest, "big-2")
+}
+
+func TestFairShareCancelAbortsPendingRequest(t *testing.T) {
+	// Given
+	project := db.Project{Name: "project", Weight: 1}
+	scheduler := coordinator.NewFairShareScheduler(1)
+	waitGrant(t, acquireAsync(scheduler, project, "running", 1), "running")
+	request := acquireAsync(scheduler, project, "stopped", 1)
+	expectPending(t, request, "stopped")
+
+	// When
+	scheduler.Cancel("stopped")
+
+	// Then
+	select {
+	case err := <-request:
+		if !errors.Is(err, coordinator.ErrShareCancelled) {
+			t.Errorf("Expected %v but found %v", coordinator.ErrShareCancelled, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("The cancelled request was expected to be answered but it wasn't!")
+	}
+}
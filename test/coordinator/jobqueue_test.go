@@ -0,0 +1,118 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+type interruptedJobRepositoryMock struct {
+	db.JobRepository
+	jobs map[string]db.Job
+}
+
+func (r *interruptedJobRepositoryMock) FetchJobIDsByStatus(status string) ([]string, error) {
+	ids := []string{}
+	for id, job := range r.jobs {
+		if job.Status == status {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *interruptedJobRepositoryMock) FetchJobByID(id string) (*db.Job, error) {
+	job, exists := r.jobs[id]
+	if !exists {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *interruptedJobRepositoryMock) UpdateJobStatusByID(id, status string) (bool, error) {
+	job := r.jobs[id]
+	job.Status = status
+	r.jobs[id] = job
+	return true, nil
+}
+
+func (r *interruptedJobRepositoryMock) UpdateJobEndTimeByID(id string, endTime int64) error {
+	return nil
+}
+
+func (r *interruptedJobRepositoryMock) UpdateJobCountersByID(id string, counters map[string]int64) error {
+	return nil
+}
+
+func (r *interruptedJobRepositoryMock) CountJobsByStatus(status string) (int, error) {
+	return 0, nil
+}
+
+type emptyQueueRepositoryMock struct {
+	db.QueueRepository
+}
+
+func (r *emptyQueueRepositoryMock) FetchQueueEntries() ([]db.QueueEntry, error) {
+	return nil, nil
+}
+
+type noTaskRepositoryMock struct {
+	db.TaskRepository
+}
+
+func (r *noTaskRepositoryMock) FetchTasksByJobID(jobId string) ([]db.Task, error) {
+	return nil, nil
+}
+
+type releasingJobSchedulerMock struct {
+	coordinator.JobScheduler
+	released []string
+}
+
+func (m *releasingJobSchedulerMock) ReleaseJob(job db.Job) error {
+	m.released = append(m.released, job.Id)
+	return nil
+}
+
+type collectingWorkerCollectorMock struct {
+	coordinator.WorkerCollector
+	collected map[string]string
+}
+
+func (m *collectingWorkerCollectorMock) CollectJob(job db.Job, status string) {
+	m.collected[job.Id] = status
+}
+
+func TestStartCollectsInterruptedJobs(t *testing.T) {
+	// Given
+	jobRepository := &interruptedJobRepositoryMock{jobs: map[string]db.Job{
+		"interrupted": {Id: "interrupted", Project: "default", Status: db.JobRunning},
+		"done":        {Id: "done", Project: "default", Status: db.JobCompleted},
+	}}
+	jobScheduler := &releasingJobSchedulerMock{}
+	workerCollector := &collectingWorkerCollectorMock{collected: map[string]string{}}
+	jobQueue := coordinator.NewJobQueue(
+		&emptyQueueRepositoryMock{},
+		jobRepository,
+		&noTaskRepositoryMock{},
+		nil,
+		nil,
+		jobScheduler,
+		workerCollector,
+		nil)
+
+	// When
+	jobQueue.Start()
+
+	// Then
+	if status := jobRepository.jobs["interrupted"].Status; status != db.JobFailed {
+		t.Errorf("Expected the interrupted job to be %s but got %s", db.JobFailed, status)
+	}
+	if len(jobScheduler.released) != 1 || jobScheduler.released[0] != "interrupted" {
+		t.Errorf("Expected only the interrupted job to be released but got %v", jobScheduler.released)
+	}
+	if len(workerCollector.collected) != 1 || workerCollector.collected["interrupted"] != db.JobFailed {
+		t.Errorf("Expected only the interrupted job workers to be collected as failed but got %v", workerCollector.collected)
+	}
+}
@@ -0,0 +1,87 @@
+package coordinator
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// phaseEvents records in order what happens to the workers of a job and to their share
+type phaseEvents struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (e *phaseEvents) add(event string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.events = append(e.events, event)
+}
+
+type orderedFairShareMock struct {
+	coordinator.FairShareScheduler
+	events *phaseEvents
+}
+
+func (m *orderedFairShareMock) Acquire(project db.Project, jobId string, nPods int) error {
+	m.events.add("acquire")
+	return nil
+}
+
+func (m *orderedFairShareMock) Release(jobId string) {
+	m.events.add("release")
+}
+
+type orderedWorkerCollectorMock struct {
+	coordinator.WorkerCollector
+	events *phaseEvents
+}
+
+func (m *orderedWorkerCollectorMock) DeletePhaseWorkers(job db.Job, wType string) error {
+	m.events.add("delete " + wType)
+	return nil
+}
+
+type orderedWorkerLogsMock struct {
+	coordinator.WorkerLogs
+	events *phaseEvents
+}
+
+func (m *orderedWorkerLogsMock) ArchivePhaseLogs(job db.Job, creds coreio.Credentials, wType string) error {
+	m.events.add("archive " + wType)
+	return nil
+}
+
+func TestMapperShareIsReleasedOnceTheMappersAreDeleted(t *testing.T) {
+	// Given
+	job, artifacts := newScheduledJob(t)
+	k8sClient := fake.NewSimpleClientset()
+	events := &phaseEvents{}
+	jobScheduler := coordinator.NewJobScheduler(
+		k8sClient,
+		&scheduledTaskRepositoryMock{},
+		&scheduledProjectManagerMock{},
+		coordinator.NewPodTemplateLoader(k8sClient),
+		&orderedFairShareMock{events: events},
+		&orderedWorkerCollectorMock{events: events},
+		&orderedWorkerLogsMock{events: events},
+		&outcomeDispatcherMock{failingType: -1},
+		newCertificateAuthority(t))
+
+	// When
+	_, err := jobScheduler.ScheduleJob(job, artifacts, jobCredentials(), coordinator.JobOptions{ArchiveLogs: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("The job scheduling failed! %v", err)
+	}
+	expected := []string{"acquire", "archive mapper", "delete mapper", "release", "acquire", "release"}
+	if !reflect.DeepEqual(events.events, expected) {
+		t.Errorf("Expected the events %v but got %v", expected, events.events)
+	}
+}
@@ -0,0 +1,258 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestMain has the scheduler hand the tasks to the task dispatcher and find the programs in the temporary directory,
+// has the dispatcher give the unacknowledged tasks out again quickly and tries to lift the program timeout before the
+// configuration gets loaded by the first test
+func TestMain(m *testing.M) {
+	os.Setenv("TASK_ASSIGNMENT", "pull")
+	os.Setenv("ARTIFACTS_PATH", os.TempDir())
+	os.Setenv("TASK_ACK_TIMEOUT", "500ms")
+	os.Setenv("PROGRAM_TIMEOUT", "0")
+	os.Exit(m.Run())
+}
+
+type scheduledProjectManagerMock struct {
+	coordinator.ProjectManager
+}
+
+func (m *scheduledProjectManagerMock) GetProjectByName(name string) (*db.Project, error) {
+	return &db.Project{Name: name, Namespace: "apollo-workers"}, nil
+}
+
+func (m *scheduledProjectManagerMock) CheckPodQuota(project db.Project, nPods int) error {
+	return nil
+}
+
+type scheduledTaskRepositoryMock struct {
+	db.TaskRepository
+}
+
+func (r *scheduledTaskRepositoryMock) CreateTasksBatch(jobId, taskType string, pods []string, inputs []db.InputData,
+	program db.Artifact, startTime int64, count int) ([]db.Task, error) {
+	tasks := make([]db.Task, count)
+	for i := range tasks {
+		tasks[i] = db.Task{Id: fmt.Sprintf("%s-%c-%v", jobId, taskType[0], i), Type: taskType, Program: program}
+		if i < len(inputs) {
+			tasks[i].InputData = &inputs[i]
+		}
+	}
+	return tasks, nil
+}
+
+func (r *scheduledTaskRepositoryMock) UpdateTaskEndTimeByID(id string, endTs int64) error {
+	return nil
+}
+
+func (r *scheduledTaskRepositoryMock) FailTaskByID(id, reason string) error {
+	return nil
+}
+
+// outcomeDispatcherMock completes every task unless it is of the failing type
+type outcomeDispatcherMock struct {
+	coordinator.TaskDispatcher
+	failingType int64
+}
+
+func (d *outcomeDispatcherMock) Dispatch(jobId string, task *proto.Task) (*proto.TaskStatusInfo, error) {
+	if task.GetType() == d.failingType {
+		return &proto.TaskStatusInfo{TaskStatus: "failed"}, errors.New("the program crashed")
+	}
+	return &proto.TaskStatusInfo{TaskStatus: "completed"}, nil
+}
+
+func (d *outcomeDispatcherMock) AdmitJob(jobId string) {}
+
+func (d *outcomeDispatcherMock) CancelJob(jobId string) {}
+
+func (d *outcomeDispatcherMock) ForgetJob(jobId string) {}
+
+type stoppedWorkerCollectorMock struct {
+	coordinator.WorkerCollector
+}
+
+func (m *stoppedWorkerCollectorMock) DeleteJobWorkers(job db.Job) error {
+	return nil
+}
+
+func (m *stoppedWorkerCollectorMock) DeletePhaseWorkers(job db.Job, wType string) error {
+	return nil
+}
+
+func newScheduledJob(t *testing.T) (db.Job, []db.Artifact) {
+	content := []byte("first record\nsecond record\n")
+	modTime := time.Now()
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "input.txt", modTime, bytes.NewReader(content))
+	}))
+	t.Cleanup(storage.Close)
+	artifacts := []db.Artifact{{Name: "secrets-mapper"}, {Name: "secrets-reducer"}}
+	for _, artifact := range artifacts {
+		path := filepath.Join(os.TempDir(), artifact.Name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatalf("The program creation failed! %v", err)
+		}
+		t.Cleanup(func() { os.Remove(path) })
+	}
+	job := db.Job{
+		Id:             "job-1",
+		Project:        "default",
+		NReducers:      1,
+		InputData:      db.InputData{Path: storage.URL + "/input/input.txt", Type: "text"},
+		OutputLocation: db.OutputLocation{Location: "http://minio:9000/output"},
+	}
+	return job, artifacts
+}
+
+func newSecretsScheduler(t *testing.T, k8sClient *fake.Clientset, dispatcher coordinator.TaskDispatcher) coordinator.JobScheduler {
+	return coordinator.NewJobScheduler(
+		k8sClient,
+		&scheduledTaskRepositoryMock{},
+		&scheduledProjectManagerMock{},
+		coordinator.NewPodTemplateLoader(k8sClient),
+		coordinator.NewFairShareScheduler(0),
+		&stoppedWorkerCollectorMock{},
+		nil,
+		dispatcher,
+		newCertificateAuthority(t))
+}
+
+func jobCredentials() []coreio.Credentials {
+	return []coreio.Credentials{
+		{Username: "input", Password: "input", Region: "us-east-1"},
+		{Username: "output", Password: "output", Region: "us-east-1"},
+	}
+}
+
+// createdSecrets returns the secrets the scheduler created and whether they still exist
+func createdSecrets(k8sClient *fake.Clientset) map[string]bool {
+	secrets := map[string]bool{}
+	for _, action := range k8sClient.Actions() {
+		if createAction, ok := action.(k8stesting.CreateAction); ok && action.GetResource().Resource == "secrets" {
+			secrets[createAction.GetObject().(*corev1.Secret).Name] = false
+		}
+	}
+	for name := range secrets {
+		_, err := k8sClient.CoreV1().Secrets("apollo-workers").Get(context.Background(), name, metav1.GetOptions{})
+		secrets[name] = err == nil
+	}
+	return secrets
+}
+
+func TestWorkersOnlyMountTheCredentialsOfTheirStorage(t *testing.T) {
+	// Given
+	job, artifacts := newScheduledJob(t)
+	k8sClient := fake.NewSimpleClientset()
+	jobScheduler := newSecretsScheduler(t, k8sClient, &outcomeDispatcherMock{failingType: -1})
+
+	// When
+	_, err := jobScheduler.ScheduleJob(job, artifacts, jobCredentials(), coordinator.JobOptions{})
+
+	// Then
+	if err != nil {
+		t.Fatalf("The job scheduling failed! %v", err)
+	}
+	expectedKeys := map[string]string{"mapper": coordinator.InputCredentialsKey, "reducer": coordinator.OutputCredentialsKey}
+	podTypes := map[string]bool{}
+	for _, action := range k8sClient.Actions() {
+		createAction, ok := action.(k8stesting.CreateAction)
+		if !ok || action.GetResource().Resource != "pods" {
+			continue
+		}
+		pod := createAction.GetObject().(*corev1.Pod)
+		wType := pod.Labels["type"]
+		podTypes[wType] = true
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil && (volume.Secret.DefaultMode == nil || *volume.Secret.DefaultMode != 0400) {
+				t.Errorf("Expected the %s volume to only be readable by the worker but got %v", volume.Name, volume.Secret.DefaultMode)
+			}
+			if volume.Name != "credentials" {
+				continue
+			}
+			items := volume.Secret.Items
+			if len(items) != 1 || items[0].Key != expectedKeys[wType] || items[0].Path != coreio.CredentialsFile {
+				t.Errorf("Expected the %s pods to only mount %s but got %v", wType, expectedKeys[wType], items)
+			}
+		}
+	}
+	if !podTypes["mapper"] || !podTypes["reducer"] {
+		t.Errorf("Expected mapper and reducer pods to be created but got %v", podTypes)
+	}
+	secrets := createdSecrets(k8sClient)
+	if len(secrets) != 2 {
+		t.Errorf("Expected the credentials and TLS secrets to be created but got %v", secrets)
+	}
+	for name, exists := range secrets {
+		if exists {
+			t.Errorf("Expected secret %s to be deleted once the job completed", name)
+		}
+	}
+}
+
+func TestJobSecretsAreDeletedWhenTheJobFails(t *testing.T) {
+	// Given
+	job, artifacts := newScheduledJob(t)
+	k8sClient := fake.NewSimpleClientset()
+	jobScheduler := newSecretsScheduler(t, k8sClient, &outcomeDispatcherMock{failingType: 0})
+
+	// When
+	_, err := jobScheduler.ScheduleJob(job, artifacts, jobCredentials(), coordinator.JobOptions{})
+
+	// Then
+	if err == nil {
+		t.Fatal("Expected the job with a failing map task to fail")
+	}
+	secrets := createdSecrets(k8sClient)
+	if len(secrets) != 2 {
+		t.Errorf("Expected the credentials and TLS secrets to be created but got %v", secrets)
+	}
+	for name, exists := range secrets {
+		if exists {
+			t.Errorf("Expected secret %s to be deleted once the job failed", name)
+		}
+	}
+}
+
+func TestStopJobDeletesTheJobSecrets(t *testing.T) {
+	// Given
+	job, _ := newScheduledJob(t)
+	k8sClient := fake.NewSimpleClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "job-1-storage-credentials", Namespace: "apollo-workers"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "job-1-tls", Namespace: "apollo-workers"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "job-2-tls", Namespace: "apollo-workers"}},
+	)
+	jobScheduler := newSecretsScheduler(t, k8sClient, &outcomeDispatcherMock{failingType: -1})
+
+	// When
+	err := jobScheduler.StopJob(job)
+
+	// Then
+	if err != nil {
+		t.Fatalf("The job stop failed! %v", err)
+	}
+	secrets, _ := k8sClient.CoreV1().Secrets("apollo-workers").List(context.Background(), metav1.ListOptions{})
+	if len(secrets.Items) != 1 || secrets.Items[0].Name != "job-2-tls" {
+		t.Errorf("Expected only the secrets of the stopped job to be deleted but found %v", secrets.Items)
+	}
+}
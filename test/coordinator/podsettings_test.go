@@ -0,0 +1,39 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestOverridePodSettings(t *testing.T) {
+	// Given
+	profile := coordinator.PodSettings{
+		NodeSelector:      map[string]string{"pool": "batch"},
+		PriorityClassName: "low",
+		Labels:            map[string]string{"team": "data", "tier": "bronze"},
+		MapperResources:   &corev1.ResourceRequirements{},
+	}
+	overrides := coordinator.PodSettings{
+		PriorityClassName: "high",
+		Labels:            map[string]string{"tier": "gold"},
+	}
+
+	// When
+	settings := profile.Override(overrides)
+
+	// Then
+	if settings.PriorityClassName != "high" {
+		t.Errorf("Expected priority class high but found %s", settings.PriorityClassName)
+	}
+	if settings.NodeSelector["pool"] != "batch" || settings.MapperResources == nil {
+		t.Error("Expected the fields missing from the overrides to be kept from the profile")
+	}
+	if settings.Labels["team"] != "data" || settings.Labels["tier"] != "gold" {
+		t.Errorf("Expected the labels to be merged but found %v", settings.Labels)
+	}
+	if profile.Labels["tier"] != "bronze" {
+		t.Error("Expected the profile labels to be left untouched")
+	}
+}
@@ -0,0 +1,80 @@
+package coordinator
+
+import (
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyPodTemplateKeepsCoordinatorFields(t *testing.T) {
+	// Given
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "worker", "job": "job-id"},
+		},
+		Spec: corev1.PodSpec{
+			Hostname:  "worker-abc",
+			Subdomain: "workers",
+			Containers: []corev1.Container{{
+				Name:         "worker",
+				Image:        "apollo-worker",
+				VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/mappers"}},
+			}},
+			Volumes: []corev1.Volume{{Name: "data"}},
+		},
+	}
+	runAsNonRoot := true
+	template := &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "other", "team": "data"},
+		},
+		Spec: corev1.PodSpec{
+			Hostname:         "template-host",
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			InitContainers:   []corev1.Container{{Name: "init"}},
+			Containers: []corev1.Container{
+				{Name: "log-shipper", Image: "shipper"},
+				{
+					Name:            "worker",
+					Image:           "template-image",
+					SecurityContext: &corev1.SecurityContext{RunAsNonRoot: &runAsNonRoot},
+					VolumeMounts:    []corev1.VolumeMount{{Name: "data", MountPath: "/elsewhere"}, {Name: "logs", MountPath: "/logs"}},
+				},
+			},
+			Volumes: []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}, {Name: "logs"}},
+		},
+	}
+
+	// When
+	coordinator.ApplyPodTemplate(pod, template)
+
+	// Then
+	if pod.Spec.Hostname != "worker-abc" || pod.Spec.Subdomain != "workers" {
+		t.Errorf("Expected the coordinator hostname and subdomain but found %s.%s", pod.Spec.Hostname, pod.Spec.Subdomain)
+	}
+	if pod.ObjectMeta.Labels["app"] != "worker" || pod.ObjectMeta.Labels["team"] != "data" {
+		t.Errorf("Expected the coordinator labels to win over the template ones but found %v", pod.ObjectMeta.Labels)
+	}
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[0].Name != "worker" {
+		t.Fatalf("Expected the worker container to come first followed by the sidecar but found %v", pod.Spec.Containers)
+	}
+	worker := pod.Spec.Containers[0]
+	if worker.Image != "apollo-worker" || worker.SecurityContext == nil {
+		t.Error("Expected the worker container to keep the coordinator image and the template security context")
+	}
+	for _, mount := range worker.VolumeMounts {
+		if mount.Name == "data" && mount.MountPath != "/mappers" {
+			t.Errorf("Expected the data volume to be mounted on /mappers but found %s", mount.MountPath)
+		}
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == "data" && volume.EmptyDir != nil {
+			t.Error("Expected the data volume of the coordinator to replace the template one")
+		}
+	}
+	if len(pod.Spec.Volumes) != 2 || len(pod.Spec.InitContainers) != 1 || len(pod.Spec.ImagePullSecrets) != 1 {
+		t.Error("Expected the template volumes, init containers and image pull secrets to be kept")
+	}
+}
@@ -0,0 +1,76 @@
+package coordinator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodFailureReason(t *testing.T) {
+	// Given
+	testCases := []struct {
+		name           string
+		status         corev1.PodStatus
+		expectedReason string
+		expectedFatal  bool
+	}{
+		{
+			name: "pending",
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "worker",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				}},
+			},
+		},
+		{
+			name: "unschedulable",
+			status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:    corev1.PodScheduled,
+					Status:  corev1.ConditionFalse,
+					Reason:  corev1.PodReasonUnschedulable,
+					Message: "0/3 nodes are available",
+				}},
+			},
+			expectedReason: "Unschedulable",
+		},
+		{
+			name: "image pull back off",
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "worker",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				}},
+			},
+			expectedReason: "ImagePullBackOff",
+			expectedFatal:  true,
+		},
+		{
+			name: "oom killed",
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "worker",
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+				}},
+			},
+			expectedReason: "OOMKilled",
+			expectedFatal:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		// When
+		reason, fatal := coordinator.PodFailureReason(&corev1.Pod{Status: testCase.status})
+
+		// Then
+		if !strings.HasPrefix(reason, testCase.expectedReason) || (testCase.expectedReason == "" && reason != "") {
+			t.Errorf("%s: expected reason %s but found %s", testCase.name, testCase.expectedReason, reason)
+		}
+		if fatal != testCase.expectedFatal {
+			t.Errorf("%s: expected fatal to be %v but found %v", testCase.name, testCase.expectedFatal, fatal)
+		}
+	}
+}
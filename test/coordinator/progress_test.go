@@ -0,0 +1,58 @@
+package coordinator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestComputeJobCompletionDuringMapPhase(t *testing.T) {
+	// Given
+	job := db.Job{NReducers: 2, Status: db.JobRunning}
+	tasks := []db.Task{
+		{Type: "mapper", Status: "completed"},
+		{Type: "mapper", Status: "in-progress", Progress: &db.TaskProgress{Phase: "map", BytesRead: 50, BytesTotal: 100}},
+	}
+
+	// When
+	completion := coordinator.ComputeJobCompletion(job, tasks)
+
+	// Then
+	expected := 100 * (1 + 0.45) / 4
+	if completion != expected {
+		t.Errorf("Expected completion %v but got %v", expected, completion)
+	}
+}
+
+func TestComputeJobCompletionOfCompletedJob(t *testing.T) {
+	// Given
+	job := db.Job{NReducers: 1, Status: db.JobCompleted}
+
+	// When
+	completion := coordinator.ComputeJobCompletion(job, []db.Task{})
+
+	// Then
+	if completion != 100 {
+		t.Errorf("Expected completion 100 but got %v", completion)
+	}
+}
+
+func TestAggregateCountersOnlySumsCompletedTasks(t *testing.T) {
+	// Given
+	tasks := []db.Task{
+		{Status: "completed", Counters: map[string]int64{"malformed": 2, "apollo.input.records": 10}},
+		{Status: "completed", Counters: map[string]int64{"malformed": 1, "apollo.input.records": 5}},
+		{Status: "failed", Counters: map[string]int64{"malformed": 7}},
+	}
+
+	// When
+	totals := coordinator.AggregateCounters(tasks)
+
+	// Then
+	expected := map[string]int64{"malformed": 3, "apollo.input.records": 15}
+	if !reflect.DeepEqual(totals, expected) {
+		t.Errorf("Expected counters %v but got %v", expected, totals)
+	}
+}
@@ -0,0 +1,355 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+)
+
+type dispatchedTaskRepositoryMock struct {
+	db.TaskRepository
+	lock     sync.Mutex
+	statuses map[string][]string
+	pods     map[string]string
+}
+
+func (r *dispatchedTaskRepositoryMock) UpdateTaskStatusByID(id, status string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.statuses[id] = append(r.statuses[id], status)
+	return nil
+}
+
+func (r *dispatchedTaskRepositoryMock) UpdateTaskPodNameByID(id, podName string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pods[id] = podName
+	return nil
+}
+
+func newDispatchedTaskRepositoryMock() *dispatchedTaskRepositoryMock {
+	return &dispatchedTaskRepositoryMock{statuses: map[string][]string{}, pods: map[string]string{}}
+}
+
+func TestPollTaskOnlyHandsOutTasksOfTheWorkerJobAndType(t *testing.T) {
+	// Given
+	taskRepository := newDispatchedTaskRepositoryMock()
+	dispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatcher.RegisterWorker("worker-b", "job-1", 0)
+	dispatcher.AdmitJob("job-1")
+	dispatcher.AdmitJob("job-2")
+	index := int64(1)
+	dispatched := make(chan error, 3)
+	for _, task := range []*proto.Task{
+		{Id: "job-2-m-0", Type: 0},
+		{Id: "job-1-r-0", Type: 1},
+		{Id: "job-1-m-1", Type: 0},
+	} {
+		go func(task *proto.Task) {
+			_, err := dispatcher.Dispatch(task.Id[:5], task)
+			dispatched <- err
+		}(task)
+	}
+
+	// When
+	task, err := dispatcher.PollTask(context.Background(), "worker-a", &index)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the poll to succeed but got %v", err)
+	}
+	if task == nil || task.Id != "job-1-m-1" {
+		t.Fatalf("Expected task job-1-m-1 to be handed out but got %v", task)
+	}
+	dispatcher.CancelJob("job-1")
+	dispatcher.CancelJob("job-2")
+	for i := 0; i < 3; i++ {
+		if err := <-dispatched; !errors.Is(err, coordinator.ErrDispatchCanceled) {
+			t.Errorf("Expected the canceled dispatches to return %v but got %v", coordinator.ErrDispatchCanceled, err)
+		}
+	}
+}
+
+func TestDispatchReturnsTheOutcomeReportedByTheWorker(t *testing.T) {
+	// Given
+	taskRepository := newDispatchedTaskRepositoryMock()
+	dispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	dispatcher.RegisterWorker("worker-a", "job-1", 1)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-r-0", Type: 1})
+		dispatched <- err
+	}()
+	task, _ := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	reason := "reducer crashed"
+
+	// When
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "in-progress"}, nil)
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "failed"}, &reason)
+	err := <-dispatched
+
+	// Then
+	if err == nil || err.Error() != reason {
+		t.Errorf("Expected the dispatch to fail with %s but got %v", reason, err)
+	}
+	if taskRepository.pods["job-1-r-0"] != "worker-a" {
+		t.Errorf("Expected the task pod to be worker-a but got %s", taskRepository.pods["job-1-r-0"])
+	}
+	statuses := taskRepository.statuses["job-1-r-0"]
+	if len(statuses) != 2 || statuses[0] != "in-progress" || statuses[1] != "failed" {
+		t.Errorf("Expected the in-progress and failed statuses to be stored but got %v", statuses)
+	}
+}
+
+func TestPollTaskRejectsUnknownWorkers(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+
+	// When
+	_, err := dispatcher.PollTask(context.Background(), "worker-a", nil)
+
+	// Then
+	if !errors.Is(err, coordinator.ErrUnknownWorker) {
+		t.Errorf("Expected %v but got %v", coordinator.ErrUnknownWorker, err)
+	}
+}
+
+func TestExpireWorkersFailsTheTasksOfSilentWorkers(t *testing.T) {
+	// Given
+	taskRepository := newDispatchedTaskRepositoryMock()
+	dispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	dispatcher.PollTask(context.Background(), "worker-a", nil)
+	taskId := "job-1-m-0"
+	silentSince := time.Now().Add(time.Second)
+
+	// When
+	dispatcher.ExpireWorkers(silentSince)
+	err := <-dispatched
+
+	// Then
+	if statuses := taskRepository.statuses[taskId]; len(statuses) != 1 || statuses[0] != "failed" {
+		t.Errorf("Expected the lost task to be failed but got %v", statuses)
+	}
+	if err == nil || !strings.Contains(err.Error(), coordinator.ErrTaskLost.Error()) {
+		t.Errorf("Expected the dispatch to fail with %v but got %v", coordinator.ErrTaskLost, err)
+	}
+	if err := dispatcher.RecordHeartbeat("worker-a", &taskId, nil); !errors.Is(err, coordinator.ErrUnknownWorker) {
+		t.Errorf("Expected the lost worker to be forgotten but got %v", err)
+	}
+}
+
+func TestDispatchReturnsTheBadRecordsReportedByTheWorker(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, _ := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	reason := "the program failed on the record at offset 12"
+
+	// When
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "failed", BadRecords: []int64{12}}, &reason)
+	err := <-dispatched
+
+	// Then
+	var badRecords *coordinator.BadRecordsError
+	if !errors.As(err, &badRecords) || len(badRecords.Offsets) != 1 || badRecords.Offsets[0] != 12 {
+		t.Errorf("Expected the dispatch to fail on the record at offset 12 but got %v", err)
+	}
+}
+
+func TestForgetJobDropsTheJobCancellation(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatcher.AdmitJob("job-1")
+	dispatcher.CancelJob("job-1")
+	if _, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0}); !errors.Is(err, coordinator.ErrDispatchCanceled) {
+		t.Fatalf("Expected the dispatch of the canceled job to return %v but got %v", coordinator.ErrDispatchCanceled, err)
+	}
+
+	// When
+	dispatcher.ForgetJob("job-1")
+
+	// Then
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, err := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	if err != nil || task == nil || task.Id != "job-1-m-0" {
+		t.Fatalf("Expected the task of the forgotten job to be handed out but got %v, %v", task, err)
+	}
+	dispatcher.CancelJob("job-1")
+	if err := <-dispatched; !errors.Is(err, coordinator.ErrDispatchCanceled) {
+		t.Errorf("Expected the dispatch to return %v but got %v", coordinator.ErrDispatchCanceled, err)
+	}
+}
+
+func TestTasksThatAreNeverAcknowledgedAreHandedOutAgain(t *testing.T) {
+	// Given
+	taskRepository := newDispatchedTaskRepositoryMock()
+	dispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatcher.RegisterWorker("worker-b", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	// The poll response carrying the task never reaches worker-a
+	if task, err := dispatcher.PollTask(context.Background(), "worker-a", nil); err != nil || task == nil {
+		t.Fatalf("Expected worker-a to pull the task but got %v, %v", task, err)
+	}
+
+	// When
+	task, err := dispatcher.PollTask(context.Background(), "worker-b", nil)
+
+	// Then
+	if err != nil || task == nil || task.Id != "job-1-m-0" {
+		t.Fatalf("Expected the unacknowledged task to be handed to worker-b but got %v, %v", task, err)
+	}
+	if err := dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "idle"}, nil); err == nil {
+		t.Error("Expected the report of worker-a to be rejected once the task was handed out again")
+	}
+	dispatcher.ReportTaskStatus("worker-b", task.Id, &proto.TaskStatusInfo{TaskStatus: "completed"}, nil)
+	if err := <-dispatched; err != nil {
+		t.Errorf("Expected the dispatch to succeed but got %v", err)
+	}
+	taskRepository.lock.Lock()
+	defer taskRepository.lock.Unlock()
+	if taskRepository.pods[task.Id] != "worker-b" {
+		t.Errorf("Expected the task pod to be worker-b but got %s", taskRepository.pods[task.Id])
+	}
+}
+
+func TestAcknowledgedTasksStayWithTheirWorker(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatcher.RegisterWorker("worker-b", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, _ := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "idle"}, nil)
+
+	// When
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stolen, err := dispatcher.PollTask(ctx, "worker-b", nil)
+
+	// Then
+	if err != nil || stolen != nil {
+		t.Errorf("Expected the acknowledged task to stay with worker-a but worker-b got %v, %v", stolen, err)
+	}
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "completed"}, nil)
+	if err := <-dispatched; err != nil {
+		t.Errorf("Expected the dispatch to succeed but got %v", err)
+	}
+}
+
+func TestCancelJobIsNotRecordedForAForgottenJob(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatcher.AdmitJob("job-1")
+	dispatcher.ForgetJob("job-1")
+
+	// When
+	dispatcher.CancelJob("job-1")
+
+	// Then
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, err := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	if err != nil || task == nil || task.Id != "job-1-m-0" {
+		t.Fatalf("Expected the stop of the forgotten job not to be remembered but got %v, %v", task, err)
+	}
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "completed"}, nil)
+	if err := <-dispatched; err != nil {
+		t.Errorf("Expected the dispatch to succeed but got %v", err)
+	}
+}
+
+func TestHeartbeatsWithoutTheAcknowledgedTaskFailItAsLost(t *testing.T) {
+	// Given
+	taskRepository := newDispatchedTaskRepositoryMock()
+	dispatcher := coordinator.NewTaskDispatcher(taskRepository)
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, _ := dispatcher.PollTask(context.Background(), "worker-a", nil)
+	dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "in-progress"}, nil)
+
+	// When
+	err := dispatcher.RecordHeartbeat("worker-a", nil, nil)
+
+	// Then
+	if err != nil {
+		t.Fatalf("The heartbeat recording failed! %v", err)
+	}
+	if err := <-dispatched; err == nil || !strings.Contains(err.Error(), coordinator.ErrTaskLost.Error()) {
+		t.Errorf("Expected the dispatch to fail with %v but got %v", coordinator.ErrTaskLost, err)
+	}
+	taskRepository.lock.Lock()
+	if statuses := taskRepository.statuses[task.Id]; len(statuses) != 2 || statuses[1] != "failed" {
+		t.Errorf("Expected the lost task to be failed but got %v", statuses)
+	}
+	taskRepository.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := dispatcher.PollTask(ctx, "worker-a", nil); err != nil {
+		t.Errorf("Expected the worker to stay registered and poll again but got %v", err)
+	}
+}
+
+func TestHeartbeatsWithoutAnUnacknowledgedTaskKeepIt(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(newDispatchedTaskRepositoryMock())
+	dispatcher.RegisterWorker("worker-a", "job-1", 0)
+	dispatched := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.Dispatch("job-1", &proto.Task{Id: "job-1-m-0", Type: 0})
+		dispatched <- err
+	}()
+	task, _ := dispatcher.PollTask(context.Background(), "worker-a", nil)
+
+	// When
+	// The heartbeat was sent before the worker got the poll response
+	dispatcher.RecordHeartbeat("worker-a", nil, nil)
+
+	// Then
+	if err := dispatcher.ReportTaskStatus("worker-a", task.Id, &proto.TaskStatusInfo{TaskStatus: "completed"}, nil); err != nil {
+		t.Errorf("Expected the task to still be assigned to worker-a but got %v", err)
+	}
+	if err := <-dispatched; err != nil {
+		t.Errorf("Expected the dispatch to succeed but got %v", err)
+	}
+}
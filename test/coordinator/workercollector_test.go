@@ -0,0 +1,94 @@
+package coordinator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type sweptJobRepositoryMock struct {
+	db.JobRepository
+	jobs map[string]db.Job
+}
+
+func (r *sweptJobRepositoryMock) FetchJobByID(id string) (*db.Job, error) {
+	job, exists := r.jobs[id]
+	if !exists {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+type sweptProjectManagerMock struct {
+	coordinator.ProjectManager
+}
+
+func (m *sweptProjectManagerMock) GetAllProjects() ([]db.Project, error) {
+	return []db.Project{{Name: "default", Namespace: "apollo-workers"}}, nil
+}
+
+func workerPod(name, jobId string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "apollo-workers",
+		Labels:    map[string]string{"app": "worker", "job": jobId},
+	}}
+}
+
+func TestSweepDeletesWorkersOfMissingAndFinishedJobs(t *testing.T) {
+	// Given
+	endTime := time.Now().Add(-time.Hour).Unix()
+	k8sClient := fake.NewSimpleClientset(
+		workerPod("worker-orphan", "orphan"),
+		workerPod("worker-running", "running"),
+		workerPod("worker-done", "done"),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:      "dev-mode-service-orphan-m-0",
+			Namespace: "apollo-workers",
+			Labels:    map[string]string{"app": "worker", "job": "orphan"},
+		}},
+	)
+	jobRepository := &sweptJobRepositoryMock{jobs: map[string]db.Job{
+		"running": {Id: "running", Status: db.JobRunning},
+		"done":    {Id: "done", Status: db.JobCompleted, EndTime: &endTime},
+	}}
+	collector := coordinator.NewWorkerCollector(k8sClient, &sweptProjectManagerMock{}, jobRepository)
+
+	// When
+	collector.Sweep()
+
+	// Then
+	deletedJobs := map[string]bool{}
+	for _, action := range k8sClient.Actions() {
+		if deleteAction, ok := action.(k8stesting.DeleteCollectionAction); ok && action.GetVerb() == "delete-collection" &&
+			action.GetResource().Resource == "pods" {
+			selector := deleteAction.GetListRestrictions().Labels
+			for _, jobId := range []string{"orphan", "running", "done"} {
+				if selector.Matches(labels.Set{"app": "worker", "job": jobId}) {
+					deletedJobs[jobId] = true
+				}
+			}
+		}
+	}
+	if !deletedJobs["orphan"] || !deletedJobs["done"] {
+		t.Errorf("Expected the workers of the orphan and finished jobs to be deleted but found %v", deletedJobs)
+	}
+	if deletedJobs["running"] {
+		t.Error("Expected the workers of the running job to be kept")
+	}
+	services, err := k8sClient.CoreV1().Services("apollo-workers").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Could not list the services %v", err)
+	}
+	if len(services.Items) != 0 {
+		t.Errorf("Expected the orphan dev mode service to be deleted but found %v services", len(services.Items))
+	}
+}
@@ -0,0 +1,74 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type loggedTaskRepositoryMock struct {
+	db.TaskRepository
+	tasks []db.Task
+}
+
+func (r *loggedTaskRepositoryMock) FetchTasksByJobID(jobId string) ([]db.Task, error) {
+	return r.tasks, nil
+}
+
+type loggedProjectManagerMock struct {
+	coordinator.ProjectManager
+}
+
+func (m *loggedProjectManagerMock) GetProjectByName(name string) (*db.Project, error) {
+	return &db.Project{Name: name, Namespace: "apollo-workers"}, nil
+}
+
+func newWorkerLogs(tasks []db.Task) coordinator.WorkerLogs {
+	jobRepository := &sweptJobRepositoryMock{jobs: map[string]db.Job{
+		"job-1": {Id: "job-1", Project: "default"},
+	}}
+	return coordinator.NewWorkerLogs(fake.NewSimpleClientset(), &loggedProjectManagerMock{}, jobRepository,
+		&loggedTaskRepositoryMock{tasks: tasks})
+}
+
+func TestStreamTaskLogsReadsTheTaskPodLogs(t *testing.T) {
+	// Given
+	podName := "worker-job-1-m-0"
+	workerLogs := newWorkerLogs([]db.Task{{Id: "job-1-m-0", PodName: &podName}})
+
+	// When
+	stream, err := workerLogs.StreamTaskLogs(context.Background(), "job-1", "job-1-m-0", coordinator.PodLogOptions{})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the task pod logs to be streamed but got %v", err)
+	}
+	defer stream.Close()
+	logs, err := io.ReadAll(stream)
+	if err != nil || len(logs) == 0 {
+		t.Errorf("Expected to read the pod logs but got %q -> %v", logs, err)
+	}
+}
+
+func TestStreamTaskLogsRejectsUnknownTasksAndTasksWithoutPod(t *testing.T) {
+	// Given
+	workerLogs := newWorkerLogs([]db.Task{{Id: "job-1-r-0"}})
+
+	// When
+	_, unknownTaskErr := workerLogs.StreamTaskLogs(context.Background(), "job-1", "job-1-m-0", coordinator.PodLogOptions{})
+	_, unknownJobErr := workerLogs.StreamTaskLogs(context.Background(), "job-2", "job-1-r-0", coordinator.PodLogOptions{})
+	_, noPodErr := workerLogs.StreamTaskLogs(context.Background(), "job-1", "job-1-r-0", coordinator.PodLogOptions{})
+
+	// Then
+	if !errors.Is(unknownTaskErr, coordinator.ErrTaskNotFound) || !errors.Is(unknownJobErr, coordinator.ErrTaskNotFound) {
+		t.Errorf("Expected %v but got %v and %v", coordinator.ErrTaskNotFound, unknownTaskErr, unknownJobErr)
+	}
+	if !errors.Is(noPodErr, coordinator.ErrTaskPodUnknown) {
+		t.Errorf("Expected %v but got %v", coordinator.ErrTaskPodUnknown, noPodErr)
+	}
+}
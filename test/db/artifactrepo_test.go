@@ -26,12 +26,16 @@ func TestCreateArtifact(t *testing.T) {
 	name := "name"
 	artifactType := "artifact-type"
 	hash := "hash"
+	owner := "owner"
+	project := "project"
 	size := int64(10)
 	expectedResult := db.Artifact{
-		Name: name,
-		Type: artifactType,
-		Size: size,
-		Hash: hash,
+		Name:    name,
+		Type:    artifactType,
+		Size:    size,
+		Hash:    hash,
+		Owner:   owner,
+		Project: project,
 	}
 	database, dbName, err := setupDB()
 	t.Cleanup(func() { os.Remove(dbName) })
@@ -41,7 +45,7 @@ func TestCreateArtifact(t *testing.T) {
 	artifactRepo := db.NewSQLiteArtifactRepository(database)
 
 	// When
-	result, err := artifactRepo.CreateArtifact(name, artifactType, hash, size)
+	result, err := artifactRepo.CreateArtifact(name, artifactType, hash, owner, project, size)
 	if err != nil {
 		t.Fatalf("Couldn't create artifact %v", err)
 	}
@@ -50,7 +54,9 @@ func TestCreateArtifact(t *testing.T) {
 	if expectedResult.Name != result.Name ||
 		expectedResult.Type != result.Type ||
 		expectedResult.Size != result.Size ||
-		expectedResult.Hash != result.Hash {
+		expectedResult.Hash != result.Hash ||
+		expectedResult.Owner != result.Owner ||
+		expectedResult.Project != result.Project {
 		t.Errorf("Expected %v but found %v", expectedResult, result)
 	}
 	row := database.QueryRow("SELECT name, type, size, hash FROM artifact WHERE name = name;")
@@ -95,7 +101,7 @@ func TestFetchArtifactsWhenArtifactsExist(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	artifactRepo := db.NewSQLiteArtifactRepository(database)
-	artifact, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", 10)
+	artifact, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
 	if err != nil {
 		t.Fatalf("Couldn't create artifact for logic testing! %v", err)
 	}
@@ -141,7 +147,7 @@ func TestFetchArtifactByNameWhenArtifactExists(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	artifactRepo := db.NewSQLiteArtifactRepository(database)
-	artifact, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", 10)
+	artifact, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
 	if err != nil {
 		t.Fatalf("Couldn't create artifact for logic testing! %v", err)
 	}
@@ -189,7 +195,7 @@ func TestDeleteArtifactWhenArtifactExists(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	artifactRepo := db.NewSQLiteArtifactRepository(database)
-	_, err = artifactRepo.CreateArtifact("name", "artifact-type", "hash", 10)
+	_, err = artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
 	if err != nil {
 		t.Fatalf("Couldn't create artifact for logic testing! %v", err)
 	}
@@ -232,7 +238,7 @@ func TestUpdateArtifactWhenArtifactExists(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	artifactRepo := db.NewSQLiteArtifactRepository(database)
-	_, err = artifactRepo.CreateArtifact("name", "artifact-type", "hash", 10)
+	_, err = artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
 	if err != nil {
 		t.Fatalf("Couldn't create artifact for logic testing! %v", err)
 	}
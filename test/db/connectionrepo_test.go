@@ -0,0 +1,103 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestCreateConnection(t *testing.T) {
+	// Given
+	secretRef := "secret-ref"
+	connection := db.Connection{
+		Name:           "name",
+		Endpoint:       "endpoint:9000",
+		UseSSL:         true,
+		Region:         "region",
+		ForcePathStyle: true,
+		SecretRef:      &secretRef,
+	}
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	connectionRepo := db.NewSQLiteConnectionRepository(database)
+
+	// When
+	_, err = connectionRepo.CreateConnection(connection)
+	if err != nil {
+		t.Fatalf("The connection creation operation failed! %v", err)
+	}
+	fetchedConnection, err := connectionRepo.FetchConnectionByName(connection.Name)
+	if err != nil {
+		t.Fatalf("The connection fetch operation failed! %v", err)
+	}
+
+	// Then
+	if fetchedConnection == nil ||
+		fetchedConnection.Endpoint != connection.Endpoint ||
+		fetchedConnection.UseSSL != connection.UseSSL ||
+		fetchedConnection.Region != connection.Region ||
+		fetchedConnection.ForcePathStyle != connection.ForcePathStyle ||
+		fetchedConnection.SecretRef == nil || *fetchedConnection.SecretRef != secretRef ||
+		fetchedConnection.Credentials != nil {
+		t.Errorf("Expected %v but found %v!", connection, fetchedConnection)
+	}
+}
+
+func TestFetchConnectionByNameWhenConnectionDoesNotExist(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	connectionRepo := db.NewSQLiteConnectionRepository(database)
+
+	// When
+	connection, err := connectionRepo.FetchConnectionByName("name")
+	if err != nil {
+		t.Fatalf("The connection fetch operation failed! %v", err)
+	}
+
+	// Then
+	if connection != nil {
+		t.Errorf("Expected to find no connection but found %v", connection)
+	}
+}
+
+func TestUpdateConnectionKeepsEncryptedCredentials(t *testing.T) {
+	// Given
+	connection := db.Connection{
+		Name:        "name",
+		Endpoint:    "endpoint:9000",
+		Credentials: []byte("old-credentials"),
+	}
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	connectionRepo := db.NewSQLiteConnectionRepository(database)
+	if _, err = connectionRepo.CreateConnection(connection); err != nil {
+		t.Fatalf("The connection creation operation failed! %v", err)
+	}
+	connection.Credentials = []byte("new-credentials")
+
+	// When
+	_, err = connectionRepo.UpdateConnection(connection)
+	if err != nil {
+		t.Fatalf("The connection update operation failed! %v", err)
+	}
+	fetchedConnection, err := connectionRepo.FetchConnectionByName(connection.Name)
+	if err != nil {
+		t.Fatalf("The connection fetch operation failed! %v", err)
+	}
+
+	// Then
+	if fetchedConnection == nil || string(fetchedConnection.Credentials) != "new-credentials" {
+		t.Errorf("Expected updated credentials but found %v", fetchedConnection)
+	}
+}
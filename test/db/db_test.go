@@ -20,7 +20,7 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
 	driver := "sqlite"
 	dbName := fmt.Sprintf("%s/test.db", currentDir)
 
-	queries := make([]string, 5)
+	queries := make([]string, 9)
 
 	queries[0] = `CREATE TABLE output_location (
     location VARCHAR PRIMARY KEY NOT NULL,
@@ -31,7 +31,9 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
     path VARCHAR NOT NULL,
     type VARCHAR NOT NULL,
     split_start INTEGER,
-    split_end INTEGER)`
+    split_end INTEGER,
+    etag VARCHAR,
+    version_id VARCHAR)`
 
 	queries[2] = `CREATE TABLE job (
     id VARCHAR PRIMARY KEY NOT NULL,
@@ -40,6 +42,10 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
     input_id INTEGER NOT NULL,
     start_time DATETIME NOT NULL,
     end_time DATETIME,
+    owner VARCHAR,
+    project VARCHAR NOT NULL DEFAULT 'default',
+    status VARCHAR,
+    counters VARCHAR,
 	FOREIGN KEY(input_id) REFERENCES input_data(id),
 	FOREIGN KEY(output_path) REFERENCES output_location(location))`
 
@@ -47,7 +53,9 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
     name VARCHAR PRIMARY KEY NOT NULL,
     type VARCHAR NOT NULL DEFAULT executable,
     size INTEGER NOT NULL DEFAULT 0,
-	hash VARCHAR NOT NULL)`
+	hash VARCHAR NOT NULL,
+    owner VARCHAR,
+    project VARCHAR NOT NULL DEFAULT 'default')`
 
 	queries[4] = `CREATE TABLE task (
     id VARCHAR PRIMARY KEY NOT NULL,
@@ -59,9 +67,50 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
     pod_name VARCHAR,
     start_time DATETIME NOT NULL,
     end_time DATETIME,
+    failure_reason VARCHAR,
+    progress VARCHAR,
+    counters VARCHAR,
+    invocation_logs VARCHAR,
     FOREIGN KEY(job_id) REFERENCES job(id),
     FOREIGN KEY(input_data_id) REFERENCES input_data(id),
 	FOREIGN KEY(program_name) REFERENCES artifact(name))`
+
+	queries[5] = `CREATE TABLE connection (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    endpoint VARCHAR NOT NULL,
+    use_ssl BOOLEAN NOT NULL,
+    region VARCHAR,
+    force_path_style BOOLEAN NOT NULL DEFAULT FALSE,
+    ca_bundle VARCHAR,
+    secret_ref VARCHAR,
+    credentials BLOB)`
+
+	queries[6] = `CREATE TABLE project (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    namespace VARCHAR NOT NULL UNIQUE,
+    max_pods INTEGER NOT NULL DEFAULT 0,
+    max_running_jobs INTEGER NOT NULL DEFAULT 0,
+    weight INTEGER NOT NULL DEFAULT 1)`
+
+	queries[7] = `CREATE TABLE job_queue (
+    job_id VARCHAR PRIMARY KEY NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enqueue_time DATETIME NOT NULL,
+    mapper_name VARCHAR NOT NULL,
+    reducer_name VARCHAR NOT NULL,
+    split_size INTEGER,
+    prefetch_parallelism INTEGER,
+    credentials BLOB,
+    pod_settings VARCHAR,
+    pod_template VARCHAR NOT NULL DEFAULT '',
+    skip_bad_records_after INTEGER,
+    archive_logs BOOLEAN NOT NULL DEFAULT 0,
+    program_concurrency INTEGER,
+    FOREIGN KEY(job_id) REFERENCES job(id))`
+
+	queries[8] = `CREATE TABLE pod_profile (
+    name VARCHAR PRIMARY KEY NOT NULL,
+    settings VARCHAR NOT NULL)`
 	slices.Sort(queries)
 
 	// When
@@ -86,7 +135,7 @@ func TestSQLiteDBEagerLoad(t *testing.T) {
 		}
 	}
 	slices.Sort(fetchedQueries)
-	for i := 0; i < 4; i++ {
+	for i := 0; i < len(queries); i++ {
 		if queries[i] != fetchedQueries[i] {
 			t.Errorf("Expected %s but found %s", queries[i], fetchedQueries[i])
 		}
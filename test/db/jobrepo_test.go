@@ -2,6 +2,7 @@ package db
 
 import (
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -16,6 +17,8 @@ func TestCreateJob(t *testing.T) {
 	inputPath := "input-path"
 	inputType := "input-type"
 	outputPath := "output-path"
+	owner := "owner"
+	project := "project"
 	useSSL := false
 
 	expectedJob := db.Job{
@@ -31,6 +34,8 @@ func TestCreateJob(t *testing.T) {
 			Type: inputType,
 		},
 		StartTime: startTime,
+		Owner:     owner,
+		Project:   project,
 	}
 	database, dbName, err := setupDB()
 	t.Cleanup(func() { os.Remove(dbName) })
@@ -40,7 +45,7 @@ func TestCreateJob(t *testing.T) {
 	jobRepo := db.NewSQLiteJobsRepository(database)
 
 	// When
-	job, err := jobRepo.CreateJob(nReducers, startTime, id, inputPath, inputType, outputPath, useSSL)
+	job, err := jobRepo.CreateJob(nReducers, startTime, id, inputPath, inputType, outputPath, owner, project, useSSL)
 	if err != nil {
 		t.Fatalf("The job creation operation failed! %v", err)
 	}
@@ -53,7 +58,9 @@ func TestCreateJob(t *testing.T) {
 		job.OutputLocation.UseSSL != expectedJob.OutputLocation.UseSSL ||
 		job.InputData.Id != expectedJob.InputData.Id ||
 		job.InputData.Path != expectedJob.InputData.Path ||
-		job.InputData.Type != expectedJob.InputData.Type {
+		job.InputData.Type != expectedJob.InputData.Type ||
+		job.Owner != expectedJob.Owner ||
+		job.Project != expectedJob.Project {
 		t.Errorf("Expected %v but found %v!", expectedJob, job)
 	}
 }
@@ -87,7 +94,7 @@ func TestFetchJobsWhenJobsExist(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	jobRepo := db.NewSQLiteJobsRepository(database)
-	job, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), "id", "input-path", "input-type", "output-path", false)
+	job, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), "id", "input-path", "input-type", "output-path", "owner", "project", false)
 	if err != nil {
 		t.Fatal("Couldn't populate db with job for test logic!")
 	}
@@ -137,7 +144,7 @@ func TestFetchJobByIDWhenJobExists(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	jobRepo := db.NewSQLiteJobsRepository(database)
-	job, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), "id", "input-path", "input-type", "output-path", false)
+	job, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), "id", "input-path", "input-type", "output-path", "owner", "project", false)
 	if err != nil {
 		t.Fatal("Couldn't populate db with job for test logic!")
 	}
@@ -172,7 +179,7 @@ func TestUpdateJobEndTimeByIDWhenJobExists(t *testing.T) {
 		t.Fatalf("Can't connect to database: %s", err)
 	}
 	jobRepo := db.NewSQLiteJobsRepository(database)
-	_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), id, "input-path", "input-type", "output-path", false)
+	_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), id, "input-path", "input-type", "output-path", "owner", "project", false)
 	if err != nil {
 		t.Fatal("Couldn't populate db with job for test logic!")
 	}
@@ -192,3 +199,106 @@ func TestUpdateJobEndTimeByIDWhenJobExists(t *testing.T) {
 		t.Errorf("Expected %v but found %v", endTs, fetchedEndTs)
 	}
 }
+
+func TestCountRunningJobsByProject(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	for _, id := range []string{"queued", "running", "ended"} {
+		_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), id, "input-path", "input-type", "output-path", "owner", "project", false)
+		if err != nil {
+			t.Fatal("Couldn't populate db with job for test logic!")
+		}
+	}
+	_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), "other", "input-path", "input-type", "output-path", "owner", "other-project", false)
+	if err != nil {
+		t.Fatal("Couldn't populate db with job for test logic!")
+	}
+	for id, status := range map[string]string{"running": db.JobRunning, "ended": db.JobCompleted} {
+		if _, err := jobRepo.UpdateJobStatusByID(id, status); err != nil {
+			t.Fatalf("Update operation failed! %v", err)
+		}
+	}
+
+	// When
+	count, err := jobRepo.CountRunningJobsByProject("project")
+	if err != nil {
+		t.Fatalf("Count operation failed! %v", err)
+	}
+
+	// Then
+	if count != 1 {
+		t.Errorf("Expected 1 running job but found %v", count)
+	}
+}
+
+func TestUpdateJobStatusByIDKeepsFinalStatus(t *testing.T) {
+	// Given
+	id := "id"
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	job, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), id, "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatal("Couldn't populate db with job for test logic!")
+	}
+	if job.Status != db.JobQueued {
+		t.Errorf("Expected a %s job but found %s", db.JobQueued, job.Status)
+	}
+	if _, err := jobRepo.UpdateJobStatusByID(id, db.JobStopped); err != nil {
+		t.Fatalf("Update operation failed! %v", err)
+	}
+
+	// When
+	updated, err := jobRepo.UpdateJobStatusByID(id, db.JobFailed)
+	if err != nil {
+		t.Fatalf("Update operation failed! %v", err)
+	}
+	fetchedJob, err := jobRepo.FetchJobByID(id)
+	if err != nil {
+		t.Fatalf("The job fetch operation failed! %v", err)
+	}
+
+	// Then
+	if updated || fetchedJob == nil || fetchedJob.Status != db.JobStopped {
+		t.Errorf("Expected the %s status to be kept but found %v", db.JobStopped, fetchedJob)
+	}
+}
+
+func TestUpdateJobCountersByIDWhenJobExists(t *testing.T) {
+	// Given
+	id := "id"
+	counters := map[string]int64{"malformed": 3, "apollo.input.records": 120}
+
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), id, "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatal("Couldn't populate db with job for test logic!")
+	}
+
+	// When
+	if err := jobRepo.UpdateJobCountersByID(id, counters); err != nil {
+		t.Fatalf("Update operation failed! %v", err)
+	}
+	job, err := jobRepo.FetchJobByID(id)
+	if err != nil {
+		t.Fatalf("Data fetching operation failed for verification failed! %v", err)
+	}
+
+	// Then
+	if !reflect.DeepEqual(job.Counters, counters) {
+		t.Errorf("Expected %v but found %v", counters, job.Counters)
+	}
+}
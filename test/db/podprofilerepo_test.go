@@ -0,0 +1,72 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestUpdatePodProfile(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	podProfileRepo := db.NewSQLitePodProfileRepository(database)
+	_, err = podProfileRepo.CreatePodProfile(db.PodProfile{Name: "gpu", Settings: []byte(`{"priorityClassName":"low"}`)})
+	if err != nil {
+		t.Fatalf("The pod profile creation operation failed! %v", err)
+	}
+
+	// When
+	_, err = podProfileRepo.UpdatePodProfile(db.PodProfile{Name: "gpu", Settings: []byte(`{"priorityClassName":"high"}`)})
+	if err != nil {
+		t.Fatalf("The pod profile update operation failed! %v", err)
+	}
+	profile, err := podProfileRepo.FetchPodProfileByName("gpu")
+
+	// Then
+	if err != nil {
+		t.Fatalf("The pod profile fetch operation failed! %v", err)
+	}
+	if profile == nil {
+		t.Fatal("Expected the gpu pod profile to exist")
+	}
+	if string(profile.Settings) != `{"priorityClassName":"high"}` {
+		t.Errorf("Expected updated settings but found %s", profile.Settings)
+	}
+}
+
+func TestDeletePodProfile(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	podProfileRepo := db.NewSQLitePodProfileRepository(database)
+	_, err = podProfileRepo.CreatePodProfile(db.PodProfile{Name: "gpu", Settings: []byte(`{}`)})
+	if err != nil {
+		t.Fatalf("The pod profile creation operation failed! %v", err)
+	}
+
+	// When
+	deleted, err := podProfileRepo.DeletePodProfile("gpu")
+
+	// Then
+	if err != nil {
+		t.Fatalf("The pod profile delete operation failed! %v", err)
+	}
+	if !deleted {
+		t.Error("Expected the gpu pod profile to be deleted")
+	}
+	profiles, err := podProfileRepo.FetchPodProfiles()
+	if err != nil {
+		t.Fatalf("The pod profiles fetch operation failed! %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("Expected no pod profiles but found %v", len(profiles))
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestCreateProject(t *testing.T) {
+	// Given
+	project := db.Project{
+		Name:           "name",
+		Namespace:      "namespace",
+		MaxPods:        10,
+		MaxRunningJobs: 2,
+		Weight:         3,
+	}
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	projectRepo := db.NewSQLiteProjectRepository(database)
+
+	// When
+	_, err = projectRepo.CreateProject(project)
+	if err != nil {
+		t.Fatalf("The project creation operation failed! %v", err)
+	}
+	fetchedProject, err := projectRepo.FetchProjectByName(project.Name)
+	if err != nil {
+		t.Fatalf("The project fetch operation failed! %v", err)
+	}
+
+	// Then
+	if fetchedProject == nil || *fetchedProject != project {
+		t.Errorf("Expected %v but found %v!", project, fetchedProject)
+	}
+}
+
+func TestCreateProjectWhenNamespaceIsTaken(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	projectRepo := db.NewSQLiteProjectRepository(database)
+	if _, err = projectRepo.CreateProject(db.Project{Name: "first", Namespace: "namespace"}); err != nil {
+		t.Fatalf("The project creation operation failed! %v", err)
+	}
+
+	// When
+	_, err = projectRepo.CreateProject(db.Project{Name: "second", Namespace: "namespace"})
+
+	// Then
+	if err == nil {
+		t.Errorf("Creating a project in a namespace that is already taken was expected to fail but it didn't!")
+	}
+}
+
+func TestUpdateProjectLimits(t *testing.T) {
+	// Given
+	project := db.Project{Name: "name", Namespace: "namespace"}
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	projectRepo := db.NewSQLiteProjectRepository(database)
+	if _, err = projectRepo.CreateProject(project); err != nil {
+		t.Fatalf("The project creation operation failed! %v", err)
+	}
+	project.MaxPods = 4
+	project.MaxRunningJobs = 1
+
+	// When
+	updatedProject, err := projectRepo.UpdateProject(project)
+	if err != nil {
+		t.Fatalf("The project update operation failed! %v", err)
+	}
+
+	// Then
+	if updatedProject != project {
+		t.Errorf("Expected %v but found %v!", project, updatedProject)
+	}
+}
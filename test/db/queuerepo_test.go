@@ -0,0 +1,88 @@
+package db
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestFetchQueueEntriesOrdersByPriorityThenAge(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	queueRepo := db.NewSQLiteQueueRepository(database)
+	entries := []db.QueueEntry{
+		{JobId: "old-low", Priority: 0, EnqueueTime: 1},
+		{JobId: "new-high", Priority: 2, EnqueueTime: 3},
+		{JobId: "new-normal", Priority: 1, EnqueueTime: 4},
+		{JobId: "old-normal", Priority: 1, EnqueueTime: 2},
+	}
+	for _, entry := range entries {
+		_, err := jobRepo.CreateJob(1, time.Now().UnixMilli(), entry.JobId, "input-path", "input-type", "output-path", "owner", "project", false)
+		if err != nil {
+			t.Fatal("Couldn't populate db with job for test logic!")
+		}
+		entry.MapperName = "mapper"
+		entry.ReducerName = "reducer"
+		if _, err := queueRepo.CreateQueueEntry(entry); err != nil {
+			t.Fatalf("The queue entry creation operation failed! %v", err)
+		}
+	}
+
+	// When
+	fetchedEntries, err := queueRepo.FetchQueueEntries()
+	if err != nil {
+		t.Fatalf("The queue entries fetch operation failed! %v", err)
+	}
+
+	// Then
+	expectedOrder := []string{"new-high", "old-normal", "new-normal", "old-low"}
+	order := []string{}
+	for _, entry := range fetchedEntries {
+		order = append(order, entry.JobId)
+	}
+	if !slices.Equal(order, expectedOrder) {
+		t.Errorf("Expected %v but found %v", expectedOrder, order)
+	}
+}
+
+func TestDeleteQueueEntry(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	queueRepo := db.NewSQLiteQueueRepository(database)
+	_, err = jobRepo.CreateJob(1, time.Now().UnixMilli(), "id", "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatal("Couldn't populate db with job for test logic!")
+	}
+	entry := db.QueueEntry{JobId: "id", MapperName: "mapper", ReducerName: "reducer", Credentials: []byte("credentials")}
+	if _, err := queueRepo.CreateQueueEntry(entry); err != nil {
+		t.Fatalf("The queue entry creation operation failed! %v", err)
+	}
+
+	// When
+	deleted, err := queueRepo.DeleteQueueEntry("id")
+	if err != nil {
+		t.Fatalf("The queue entry deletion operation failed! %v", err)
+	}
+	entries, err := queueRepo.FetchQueueEntries()
+	if err != nil {
+		t.Fatalf("The queue entries fetch operation failed! %v", err)
+	}
+
+	// Then
+	if !deleted || len(entries) != 0 {
+		t.Errorf("Expected the queue entry to be deleted but found %v", entries)
+	}
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/db"
+)
+
+func TestCreateTasksBatchKeepsInputSnapshot(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	artifactRepo := db.NewSQLiteArtifactRepository(database)
+	taskRepo := db.NewSQLiteTaskRepository(database)
+	startTime := time.Now().UTC().UnixMilli()
+	job, err := jobRepo.CreateJob(1, startTime, "id", "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatalf("The job creation operation failed! %v", err)
+	}
+	program, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
+	if err != nil {
+		t.Fatalf("The artifact creation operation failed! %v", err)
+	}
+	splitStart, splitEnd := int64(0), int64(10)
+	etag, versionId := "etag", "version-id"
+	inputs := []db.InputData{{
+		Path:       "input-path",
+		Type:       "input-type",
+		SplitStart: &splitStart,
+		SplitEnd:   &splitEnd,
+		ETag:       &etag,
+		VersionId:  &versionId,
+	}}
+
+	// When
+	_, err = taskRepo.CreateTasksBatch(job.Id, "mapper", []string{"pod"}, inputs, program, startTime, 1)
+	if err != nil {
+		t.Fatalf("The task batch creation operation failed! %v", err)
+	}
+	tasks, err := taskRepo.FetchTasksByJobID(job.Id)
+	if err != nil {
+		t.Fatalf("The task fetch operation failed! %v", err)
+	}
+
+	// Then
+	if len(tasks) != 1 || tasks[0].InputData == nil {
+		t.Fatalf("Expected to find 1 task with input data but found %v", tasks)
+	}
+	inputData := tasks[0].InputData
+	if inputData.ETag == nil || *inputData.ETag != etag ||
+		inputData.VersionId == nil || *inputData.VersionId != versionId {
+		t.Errorf("Expected snapshot (%s, %s) but found (%v, %v)", etag, versionId, inputData.ETag, inputData.VersionId)
+	}
+}
+
+func TestFailTaskByIDStoresReason(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	artifactRepo := db.NewSQLiteArtifactRepository(database)
+	taskRepo := db.NewSQLiteTaskRepository(database)
+	startTime := time.Now().UTC().UnixMilli()
+	job, err := jobRepo.CreateJob(1, startTime, "id", "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatalf("The job creation operation failed! %v", err)
+	}
+	program, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
+	if err != nil {
+		t.Fatalf("The artifact creation operation failed! %v", err)
+	}
+	tasks, err := taskRepo.CreateTasksBatch(job.Id, "reducer", []string{"pod"}, []db.InputData{}, program, startTime, 1)
+	if err != nil {
+		t.Fatalf("The task batch creation operation failed! %v", err)
+	}
+	reason := "ImagePullBackOff: Back-off pulling image"
+
+	// When
+	err = taskRepo.FailTaskByID(tasks[0].Id, reason)
+	if err != nil {
+		t.Fatalf("The task failure operation failed! %v", err)
+	}
+	tasks, err = taskRepo.FetchTasksByJobID(job.Id)
+	if err != nil {
+		t.Fatalf("The task fetch operation failed! %v", err)
+	}
+
+	// Then
+	if tasks[0].Status != "failed" {
+		t.Errorf("Expected status failed but found %s", tasks[0].Status)
+	}
+	if tasks[0].FailureReason == nil || *tasks[0].FailureReason != reason {
+		t.Errorf("Expected failure reason %s but found %v", reason, tasks[0].FailureReason)
+	}
+}
+
+func TestUpdateTaskProgressByIDStoresLatestProgress(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	artifactRepo := db.NewSQLiteArtifactRepository(database)
+	taskRepo := db.NewSQLiteTaskRepository(database)
+	startTime := time.Now().UTC().UnixMilli()
+	job, err := jobRepo.CreateJob(1, startTime, "id", "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatalf("The job creation operation failed! %v", err)
+	}
+	program, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
+	if err != nil {
+		t.Fatalf("The artifact creation operation failed! %v", err)
+	}
+	tasks, err := taskRepo.CreateTasksBatch(job.Id, "mapper", []string{"pod"}, []db.InputData{}, program, startTime, 1)
+	if err != nil {
+		t.Fatalf("The task batch creation operation failed! %v", err)
+	}
+	progress := db.TaskProgress{
+		Phase:            "map",
+		BytesRead:        512,
+		BytesTotal:       1024,
+		RecordsProcessed: 12,
+		PairsEmitted:     map[int64]int64{0: 30, 1: 6},
+	}
+
+	// When
+	taskRepo.UpdateTaskProgressByID(tasks[0].Id, db.TaskProgress{Phase: "fetch"})
+	err = taskRepo.UpdateTaskProgressByID(tasks[0].Id, progress)
+	if err != nil {
+		t.Fatalf("The task progress update operation failed! %v", err)
+	}
+	tasks, err = taskRepo.FetchTasksByJobID(job.Id)
+	if err != nil {
+		t.Fatalf("The task fetch operation failed! %v", err)
+	}
+
+	// Then
+	if tasks[0].Progress == nil {
+		t.Fatalf("Expected the task progress to be stored")
+	}
+	if !reflect.DeepEqual(*tasks[0].Progress, progress) {
+		t.Errorf("Expected progress %v but found %v", progress, *tasks[0].Progress)
+	}
+}
+
+func TestFetchTaskLogsByIDReturnsTheStoredLogsOfTheJobTask(t *testing.T) {
+	// Given
+	database, dbName, err := setupDB()
+	t.Cleanup(func() { os.Remove(dbName) })
+	if err != nil {
+		t.Fatalf("Can't connect to database: %s", err)
+	}
+	jobRepo := db.NewSQLiteJobsRepository(database)
+	artifactRepo := db.NewSQLiteArtifactRepository(database)
+	taskRepo := db.NewSQLiteTaskRepository(database)
+	startTime := time.Now().UTC().UnixMilli()
+	job, err := jobRepo.CreateJob(1, startTime, "id", "input-path", "input-type", "output-path", "owner", "project", false)
+	if err != nil {
+		t.Fatalf("The job creation operation failed! %v", err)
+	}
+	program, err := artifactRepo.CreateArtifact("name", "artifact-type", "hash", "owner", "project", 10)
+	if err != nil {
+		t.Fatalf("The artifact creation operation failed! %v", err)
+	}
+	tasks, err := taskRepo.CreateTasksBatch(job.Id, "reducer", []string{"pod", "pod"}, []db.InputData{}, program, startTime, 2)
+	if err != nil {
+		t.Fatalf("The task batch creation operation failed! %v", err)
+	}
+	logs := []db.InvocationLog{{Invocation: 3, ExitCode: 2, Stdout: "partial", Stderr: "malformed line"}}
+
+	// When
+	err = taskRepo.UpdateTaskLogsByID(tasks[0].Id, logs)
+	if err != nil {
+		t.Fatalf("The task logs update operation failed! %v", err)
+	}
+	storedLogs, found, err := taskRepo.FetchTaskLogsByID(job.Id, tasks[0].Id)
+	if err != nil {
+		t.Fatalf("The task logs fetch operation failed! %v", err)
+	}
+	emptyLogs, emptyFound, _ := taskRepo.FetchTaskLogsByID(job.Id, tasks[1].Id)
+	_, otherJobFound, _ := taskRepo.FetchTaskLogsByID("other-job", tasks[0].Id)
+
+	// Then
+	if !found || !reflect.DeepEqual(storedLogs, logs) {
+		t.Errorf("Expected logs %v but found %v", logs, storedLogs)
+	}
+	if !emptyFound || len(emptyLogs) != 0 {
+		t.Errorf("Expected the task without failed invocations to have no logs but found %v", emptyLogs)
+	}
+	if otherJobFound {
+		t.Errorf("Expected the task not to be found in another job")
+	}
+}
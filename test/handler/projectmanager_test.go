@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/handler"
+)
+
+type disabledAuthenticatorMock struct {
+	coordinator.Authenticator
+}
+
+func (a *disabledAuthenticatorMock) IsEnabled() bool {
+	return false
+}
+
+type deletedProjectManagerMock struct {
+	coordinator.ProjectManager
+	projects map[string]bool
+}
+
+func (m *deletedProjectManagerMock) DeleteProject(name string) (bool, error) {
+	found := m.projects[name]
+	delete(m.projects, name)
+	return found, nil
+}
+
+func TestDeleteProjectReturnsNotFoundForUnknownProjects(t *testing.T) {
+	// Given
+	projectManager := &deletedProjectManagerMock{projects: map[string]bool{"analytics": true}}
+	controller := handler.NewProjectHandler(projectManager)
+	router := handler.Authenticate(&disabledAuthenticatorMock{})(controller.Router)
+
+	// When
+	statuses := []int{}
+	for _, name := range []string{"analytics", "analytics"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/"+name, nil))
+		statuses = append(statuses, recorder.Code)
+	}
+
+	// Then
+	if statuses[0] != http.StatusNoContent || statuses[1] != http.StatusNotFound {
+		t.Errorf("Expected the deletes to return %v then %v but got %v", http.StatusNoContent, http.StatusNotFound, statuses)
+	}
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/handler"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+	"google.golang.org/grpc"
+)
+
+// TestMain lets the test binary stand in for the map programs the sandbox re-executes it for, the fake program
+// emits no pair for the record it gets unless it is the hung mapper which never answers. The legacy mapper doesn't
+// know about SocketDirEnv and always uses the map socket of LegacySocketDir
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == worker.SandboxCommand {
+		program := ""
+		if len(os.Args) > 5 {
+			program = filepath.Base(os.Args[5])
+		}
+		if program == "hung-mapper" {
+			time.Sleep(time.Hour)
+		}
+		socketDir := os.Getenv(worker.SocketDirEnv)
+		if program == "legacy-mapper" {
+			socketDir = worker.LegacySocketDir
+		}
+		// Give the other task the time to run its programs at once
+		time.Sleep(100 * time.Millisecond)
+		fd, err := net.Dial("unix", filepath.Join(socketDir, "map.sock"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fd.Write([]byte(`{"pairs":[]}`))
+		fd.Close()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type taskStreamMock struct {
+	grpc.ServerStream
+	lock     sync.Mutex
+	statuses []*proto.TaskStatusInfo
+}
+
+func (s *taskStreamMock) Send(statusInfo *proto.TaskStatusInfo) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statuses = append(s.statuses, statusInfo)
+	return nil
+}
+
+// newObjectStorage serves an input file the way an S3 object storage would
+func newObjectStorage(content []byte) *httptest.Server {
+	modTime := time.Now()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"input"`)
+		http.ServeContent(w, r, "input.txt", modTime, bytes.NewReader(content))
+	}))
+}
+
+// newMapTask builds a map task of the whole input served by the storage, the programs run as the test user
+func newMapTask(t *testing.T, storage *httptest.Server, content []byte, id, program string) *proto.Task {
+	t.Setenv(worker.ProgramUIDEnv, strconv.Itoa(os.Getuid()))
+	t.Setenv(worker.ProgramGIDEnv, strconv.Itoa(os.Getgid()))
+	// Setting the region spares the bucket location lookup
+	region := "us-east-1"
+	nReducers, splitStart, splitEnd := int64(1), int64(0), int64(len(content))
+	return &proto.Task{
+		Id:        id,
+		Type:      0,
+		NReducers: &nReducers,
+		Program:   &proto.Program{Name: program, Content: []byte("#!/bin/sh\n")},
+		InputData: []*proto.FileData{{
+			Path:       storage.URL + "/input/input.txt",
+			SplitStart: &splitStart,
+			SplitEnd:   &splitEnd,
+		}},
+		ObjectStorageCreds: &proto.Credentials{Username: "apollo", Password: "apollo", Region: &region},
+	}
+}
+
+// startTasksAtOnce runs the tasks on the same worker at once and returns their streams and outcomes
+func startTasksAtOnce(taskCreator *handler.TaskCreatorHandler, tasks []*proto.Task) ([]*taskStreamMock, []error) {
+	streams := make([]*taskStreamMock, len(tasks))
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for idx, task := range tasks {
+		streams[idx] = &taskStreamMock{}
+		wg.Add(1)
+		go func(idx int, task *proto.Task) {
+			defer wg.Done()
+			errs[idx] = taskCreator.StartTask(task, streams[idx])
+		}(idx, task)
+	}
+	wg.Wait()
+	return streams, errs
+}
+
+func TestStartTaskRunsTasksAtOnce(t *testing.T) {
+	// Given
+	content := []byte("first record\nsecond record\n")
+	storage := newObjectStorage(content)
+	defer storage.Close()
+	taskCreator := handler.NewTaskCreatorHandler(nil)
+	tasks := []*proto.Task{
+		newMapTask(t, storage, content, "job-1-m-0", "mapper"),
+		newMapTask(t, storage, content, "job-1-m-1", "mapper"),
+	}
+
+	// When
+	streams, errs := startTasksAtOnce(taskCreator, tasks)
+
+	// Then
+	for idx, stream := range streams {
+		if errs[idx] != nil {
+			t.Errorf("Expected task %v to succeed but got %v", idx, errs[idx])
+			continue
+		}
+		final := stream.statuses[len(stream.statuses)-1]
+		if final.GetTaskStatus() != "completed" || final.GetCounters()[worker.CounterInputRecords] != 2 {
+			t.Errorf("Expected task %v to complete its 2 records on its own but got %v", idx, final)
+		}
+	}
+}
+
+func TestStartTaskFailsAHungProgramAtItsTimeout(t *testing.T) {
+	// Given
+	content := []byte("first record\n")
+	storage := newObjectStorage(content)
+	defer storage.Close()
+	task := newMapTask(t, storage, content, "job-1-m-0", "hung-mapper")
+	heartbeatInterval := int64(50)
+	task.HeartbeatIntervalMs = &heartbeatInterval
+	task.ProgramLimits = &proto.ProgramLimits{TimeoutMs: 500}
+	stream := &taskStreamMock{}
+
+	// When
+	start := time.Now()
+	err := handler.NewTaskCreatorHandler(nil).StartTask(task, stream)
+	elapsed := time.Since(start)
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "exceeded its") {
+		t.Errorf("Expected the hung program to exceed its timeout but got %v", err)
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("Expected the task to fail right after the program timeout but it took %v", elapsed)
+	}
+	heartbeats := 0
+	for _, statusInfo := range stream.statuses {
+		if statusInfo.Progress != nil {
+			heartbeats++
+		}
+	}
+	if heartbeats == 0 {
+		t.Error("Expected heartbeats to be sent while the program hung")
+	}
+	if final := stream.statuses[len(stream.statuses)-1]; final.GetTaskStatus() != "failed" {
+		t.Errorf("Expected the task to fail but got %v", final)
+	}
+}
+
+func TestLegacyProgramsOfTasksRunAtOnceDontReachTheOtherTask(t *testing.T) {
+	// Given
+	content := []byte("first record\n")
+	storage := newObjectStorage(content)
+	defer storage.Close()
+	// The worker accepts tasks at once, it doesn't let them use the legacy sockets
+	taskCreator := handler.NewTaskCreatorHandler(nil)
+	tasks := []*proto.Task{
+		newMapTask(t, storage, content, "job-1-m-0", "legacy-mapper"),
+		newMapTask(t, storage, content, "job-1-m-1", "legacy-mapper"),
+	}
+	for _, task := range tasks {
+		task.ProgramLimits = &proto.ProgramLimits{TimeoutMs: 2000}
+	}
+
+	// When
+	streams, errs := startTasksAtOnce(taskCreator, tasks)
+
+	// Then
+	for idx, stream := range streams {
+		final := stream.statuses[len(stream.statuses)-1]
+		if errs[idx] == nil || final.GetTaskStatus() != "failed" {
+			t.Errorf("Expected the legacy program of task %v not to find its socket but got %v", idx, final)
+		}
+		if errs[idx] != nil && strings.Contains(errs[idx].Error(), "exceeded its") {
+			t.Errorf("Expected the legacy program of task %v to fail right away but got %v", idx, errs[idx])
+		}
+	}
+}
+
+func TestStartTaskKeepsTheLegacySocketsOfAWorkerRunningASingleTask(t *testing.T) {
+	// Given
+	worker.AllowLegacySockets(true)
+	defer worker.AllowLegacySockets(false)
+	content := []byte("first record\n")
+	storage := newObjectStorage(content)
+	defer storage.Close()
+	index := int64(0)
+	stream := &taskStreamMock{}
+
+	// When
+	err := handler.NewTaskCreatorHandler(&index).StartTask(newMapTask(t, storage, content, "job-1-m-0", "legacy-mapper"), stream)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the legacy program to reach its socket but got %v", err)
+	}
+	if final := stream.statuses[len(stream.statuses)-1]; final.GetTaskStatus() != "completed" {
+		t.Errorf("Expected the task to complete but got %v", final)
+	}
+}
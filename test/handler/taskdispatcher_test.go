@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/coordinator"
+	"github.com/Assifar-Karim/apollo/internal/db"
+	"github.com/Assifar-Karim/apollo/internal/handler"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type taskRepositoryMock struct {
+	db.TaskRepository
+}
+
+// jobPeerContext mimics the context of a call made with a verified worker certificate of the job
+func jobPeerContext(jobId string) context.Context {
+	certificate := &x509.Certificate{Subject: pkix.Name{CommonName: utils.WorkerCommonNamePrefix + jobId}}
+	tlsInfo := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tlsInfo})
+}
+
+func TestWorkersCanOnlyActForWorkersOfTheirJob(t *testing.T) {
+	// Given
+	dispatcher := coordinator.NewTaskDispatcher(&taskRepositoryMock{})
+	dispatcherHandler := handler.NewTaskDispatcherHandler(dispatcher)
+	registration := &proto.WorkerRegistration{WorkerId: "worker-a", JobId: "job-1", Type: 0}
+	if _, err := dispatcherHandler.RegisterWorker(jobPeerContext("job-1"), registration); err != nil {
+		t.Fatalf("The worker registration failed! %v", err)
+	}
+	foreignCtx := jobPeerContext("job-2")
+	taskId := "job-1-m-0"
+
+	// When
+	_, heartbeatErr := dispatcherHandler.SendHeartbeat(foreignCtx, &proto.Heartbeat{WorkerId: "worker-a"})
+	_, pollErr := dispatcherHandler.PollTask(foreignCtx, &proto.TaskRequest{WorkerId: "worker-a"})
+	_, reportErr := dispatcherHandler.ReportTaskStatus(foreignCtx, &proto.TaskStatusReport{
+		WorkerId:   "worker-a",
+		TaskId:     taskId,
+		StatusInfo: &proto.TaskStatusInfo{TaskStatus: "completed"},
+	})
+	_, registrationErr := dispatcherHandler.RegisterWorker(foreignCtx,
+		&proto.WorkerRegistration{WorkerId: "worker-a", JobId: "job-2", Type: 0})
+
+	// Then
+	for call, err := range map[string]error{
+		"SendHeartbeat":    heartbeatErr,
+		"PollTask":         pollErr,
+		"ReportTaskStatus": reportErr,
+		"RegisterWorker":   registrationErr,
+	} {
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected %s to be denied to a worker of another job but got %v", call, err)
+		}
+	}
+	if _, err := dispatcherHandler.SendHeartbeat(jobPeerContext("job-1"), &proto.Heartbeat{WorkerId: "worker-a"}); err != nil {
+		t.Errorf("Expected the worker of the job to be accepted but got %v", err)
+	}
+}
+
+func TestUnknownWorkersAreAskedToRegister(t *testing.T) {
+	// Given
+	dispatcherHandler := handler.NewTaskDispatcherHandler(coordinator.NewTaskDispatcher(&taskRepositoryMock{}))
+
+	// When
+	_, err := dispatcherHandler.SendHeartbeat(jobPeerContext("job-1"), &proto.Heartbeat{WorkerId: "worker-a"})
+
+	// Then
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected %v but got %v", codes.NotFound, err)
+	}
+}
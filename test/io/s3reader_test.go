@@ -0,0 +1,128 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	stdio "io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/io"
+)
+
+var content = []byte("abcdefghijklmnopqrstuvwxyz")
+
+// interruptedReader hands over a few bytes before failing like a connection reset mid-stream
+type interruptedReader struct {
+	data []byte
+	err  error
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *interruptedReader) Close() error {
+	return nil
+}
+
+// rangeOpenerMock serves the ranges of content, the first opens fail with their error after some bytes
+type rangeOpenerMock struct {
+	lock     sync.Mutex
+	starts   []int64
+	failures []error
+	delay    func(start int64) time.Duration
+}
+
+func (m *rangeOpenerMock) open(ctx context.Context, start, end int64) (stdio.ReadCloser, error) {
+	m.lock.Lock()
+	m.starts = append(m.starts, start)
+	var failure error
+	if len(m.failures) > 0 {
+		failure, m.failures = m.failures[0], m.failures[1:]
+	}
+	m.lock.Unlock()
+	if m.delay != nil {
+		time.Sleep(m.delay(start))
+	}
+	data := content[start : end+1]
+	if failure != nil {
+		return &interruptedReader{data: data[:min(3, len(data))], err: failure}, nil
+	}
+	return stdio.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestRangeReaderResumesAfterTransientError(t *testing.T) {
+	// Given
+	opener := &rangeOpenerMock{failures: []error{stdio.ErrUnexpectedEOF}}
+	reader := io.NewRangeReader(context.Background(), opener.open, 2, 20, io.DefaultPrefetchChunk, 1, io.MaxReadRetries)
+	defer reader.Close()
+
+	// When
+	data, err := stdio.ReadAll(reader)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the range to be read but got %v", err)
+	}
+	if !bytes.Equal(data, content[2:21]) {
+		t.Errorf("Expected %q but read %q", content[2:21], data)
+	}
+	if expected := []int64{2, 5}; !slices.Equal(opener.starts, expected) {
+		t.Errorf("Expected the range to be opened at offsets %v but it was at %v", expected, opener.starts)
+	}
+}
+
+func TestRangeReaderKeepsPrefetchedChunksInOrder(t *testing.T) {
+	// Given
+	opener := &rangeOpenerMock{
+		failures: []error{stdio.ErrUnexpectedEOF},
+		// The last chunks are fetched first
+		delay: func(start int64) time.Duration {
+			return time.Duration(len(content)-int(start)) * time.Millisecond
+		},
+	}
+	reader := io.NewRangeReader(context.Background(), opener.open, 1, 24, 4, 4, io.MaxReadRetries)
+	defer reader.Close()
+
+	// When
+	data, err := stdio.ReadAll(reader)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Expected the range to be read but got %v", err)
+	}
+	if !bytes.Equal(data, content[1:25]) {
+		t.Errorf("Expected %q but read %q", content[1:25], data)
+	}
+}
+
+func TestRangeReaderDoesNotRetryPermanentErrors(t *testing.T) {
+	// Given
+	accessDenied := errors.New("access denied")
+	opener := &rangeOpenerMock{failures: []error{accessDenied}}
+	reader := io.NewRangeReader(context.Background(), opener.open, 0, 20, io.DefaultPrefetchChunk, 1, io.MaxReadRetries)
+	defer reader.Close()
+
+	// When
+	data, err := stdio.ReadAll(reader)
+
+	// Then
+	if !errors.Is(err, accessDenied) {
+		t.Errorf("Expected %v but got %v", accessDenied, err)
+	}
+	if !bytes.Equal(data, content[:3]) {
+		t.Errorf("Expected the bytes read before the failure %q but read %q", content[:3], data)
+	}
+	if len(opener.starts) != 1 {
+		t.Errorf("Expected the range to be opened once but it was opened at %v", opener.starts)
+	}
+}
@@ -0,0 +1,88 @@
+package io
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Assifar-Karim/apollo/internal/io"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func generateCABundle(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("The key generation failed! %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "object-storage-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("The certificate creation failed! %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCredentialsProtoRoundTrip(t *testing.T) {
+	// Given
+	credentials := io.Credentials{
+		Username:       "access-key",
+		Password:       "secret-key",
+		SessionToken:   "session-token",
+		Region:         "eu-west-3",
+		ForcePathStyle: true,
+		CABundle:       generateCABundle(t),
+	}
+
+	// When
+	converted := io.CredentialsFromProto(credentials.ToProto())
+
+	// Then
+	if converted != credentials {
+		t.Errorf("Expected %+v but got %+v", credentials, converted)
+	}
+}
+
+func TestCredentialsToProtoLeavesUnsetOptionsEmpty(t *testing.T) {
+	// Given
+	credentials := io.Credentials{Username: "access-key", Password: "secret-key"}
+
+	// When
+	converted := credentials.ToProto()
+
+	// Then
+	if converted.Region != nil || converted.ForcePathStyle != nil || converted.CaBundle != nil {
+		t.Errorf("Expected the unset options to be left empty but got %v", converted)
+	}
+	if roundTrip := io.CredentialsFromProto(converted); roundTrip != credentials {
+		t.Errorf("Expected %+v but got %+v", credentials, roundTrip)
+	}
+}
+
+func TestNewS3RegistrarValidatesCABundle(t *testing.T) {
+	// When
+	_, invalidErr := io.NewS3Registrar("localhost:9000", true, io.Credentials{CABundle: "not a certificate"})
+	_, validErr := io.NewS3Registrar("localhost:9000", true, io.Credentials{CABundle: generateCABundle(t)})
+
+	// Then
+	if status.Code(invalidErr) != codes.InvalidArgument {
+		t.Errorf("Expected an invalid CA bundle to be rejected but got %v", invalidErr)
+	}
+	if validErr != nil {
+		t.Errorf("Expected a valid CA bundle to be accepted but got %v", validErr)
+	}
+}
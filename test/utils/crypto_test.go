@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/utils"
+)
+
+var (
+	connectionsKey = bytes.Repeat([]byte{0x2a}, 32)
+	plaintext      = []byte(`{"username":"access-key","password":"secret-key"}`)
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	// Given
+	ciphertext, err := utils.Encrypt(connectionsKey, plaintext)
+	if err != nil {
+		t.Fatalf("The encryption failed! %v", err)
+	}
+
+	// When
+	decrypted, err := utils.Decrypt(connectionsKey, ciphertext)
+
+	// Then
+	if err != nil {
+		t.Fatalf("The decryption failed! %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Expected %q but decrypted %q", plaintext, decrypted)
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Error("Expected the plaintext not to appear in the ciphertext")
+	}
+}
+
+func TestDecryptRejectsWrongKey(t *testing.T) {
+	// Given
+	ciphertext, err := utils.Encrypt(connectionsKey, plaintext)
+	if err != nil {
+		t.Fatalf("The encryption failed! %v", err)
+	}
+	wrongKey := bytes.Repeat([]byte{0x2b}, 32)
+
+	// When
+	_, err = utils.Decrypt(wrongKey, ciphertext)
+
+	// Then
+	if err == nil {
+		t.Error("Expected the decryption with another key to fail")
+	}
+}
+
+func TestDecryptRejectsTamperedCiphertext(t *testing.T) {
+	// Given
+	ciphertext, err := utils.Encrypt(connectionsKey, plaintext)
+	if err != nil {
+		t.Fatalf("The encryption failed! %v", err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 0x01
+
+	// When
+	_, tamperedErr := utils.Decrypt(connectionsKey, ciphertext)
+	_, truncatedErr := utils.Decrypt(connectionsKey, ciphertext[:4])
+
+	// Then
+	if tamperedErr == nil || truncatedErr == nil {
+		t.Errorf("Expected the tampered ciphertexts to be rejected but got %v and %v", tamperedErr, truncatedErr)
+	}
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dispatcherConnMock hands a single task out and answers the status reports with the given errors in turn
+type dispatcherConnMock struct {
+	grpc.ClientConnInterface
+	lock       sync.Mutex
+	task       *proto.Task
+	polls      int
+	reports    []string
+	reportErrs []error
+}
+
+func (c *dispatcherConnMock) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch method {
+	case proto.TaskDispatcher_RegisterWorker_FullMethodName:
+		return nil
+	case proto.TaskDispatcher_PollTask_FullMethodName:
+		c.polls++
+		reply.(*proto.TaskAssignment).Task = c.task
+		return nil
+	case proto.TaskDispatcher_ReportTaskStatus_FullMethodName:
+		c.reports = append(c.reports, args.(*proto.TaskStatusReport).GetStatusInfo().GetTaskStatus())
+		if len(c.reportErrs) == 0 {
+			return nil
+		}
+		err := c.reportErrs[0]
+		c.reportErrs = c.reportErrs[1:]
+		return err
+	}
+	return errors.New("unexpected call " + method)
+}
+
+// newIndexedPuller builds the puller of an Indexed Job worker so that it returns once its task is done, the task
+// type is unknown for it to fail right away
+func newIndexedPuller(t *testing.T, conn *dispatcherConnMock) *worker.TaskPuller {
+	t.Setenv(worker.WorkerIdEnv, "worker-a")
+	t.Setenv(worker.JobIdEnv, "job-1")
+	t.Setenv(worker.TaskTypeEnv, "0")
+	conn.task = &proto.Task{Id: "job-1-m-0", Type: 7}
+	index := int64(0)
+	puller, err := worker.NewTaskPuller(conn, nil, &index)
+	if err != nil {
+		t.Fatalf("The puller creation failed! %v", err)
+	}
+	return puller
+}
+
+func TestPullerReportsTheTaskOutcomeUntilItIsAcknowledged(t *testing.T) {
+	// Given
+	unavailable := status.Error(codes.Unavailable, "the coordinator is restarting")
+	conn := &dispatcherConnMock{reportErrs: []error{unavailable, unavailable}}
+	puller := newIndexedPuller(t, conn)
+
+	// When
+	err := puller.Run()
+
+	// Then
+	if err == nil {
+		t.Error("Expected the task of an unknown type to fail")
+	}
+	if len(conn.reports) != 3 || conn.reports[2] != "failed" {
+		t.Errorf("Expected the failed status to be reported until it got acknowledged but got %v", conn.reports)
+	}
+	if conn.polls != 1 {
+		t.Errorf("Expected a single poll but got %v", conn.polls)
+	}
+}
+
+func TestPullerStopsReportingTheOutcomeOfARejectedTask(t *testing.T) {
+	// Given
+	conn := &dispatcherConnMock{reportErrs: []error{status.Error(codes.FailedPrecondition, "task isn't assigned")}}
+	puller := newIndexedPuller(t, conn)
+
+	// When
+	puller.Run()
+
+	// Then
+	if len(conn.reports) != 1 {
+		t.Errorf("Expected the rejected status not to be reported again but got %v", conn.reports)
+	}
+}
@@ -0,0 +1,229 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	coreio "github.com/Assifar-Karim/apollo/internal/io"
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/utils"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+)
+
+// TestMain lets the test binary stand in for the worker binary the sandbox re-executes
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == worker.SandboxCommand {
+		err := worker.RunSandboxed(os.Args[2:])
+		fmt.Fprintf(os.Stderr, "can't start the program: %s\n", err)
+		os.Exit(126)
+	}
+	os.Exit(m.Run())
+}
+
+// exposeLauncher lets the unprivileged program user run the test binary, go test builds it in private temporary
+// directories
+func exposeLauncher(t *testing.T) {
+	launcher, err := os.Executable()
+	if err != nil {
+		t.Fatalf("The test binary lookup failed! %v", err)
+	}
+	tmpDir := filepath.Clean(os.TempDir()) + string(filepath.Separator)
+	for dir := filepath.Dir(launcher); strings.HasPrefix(dir, tmpDir); dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatalf("The test binary directory chmod failed! %v", err)
+		}
+	}
+}
+
+// runProbe runs a shell script through the sandbox of a fresh task workspace
+func runProbe(t *testing.T, limits *proto.ProgramLimits, script string) (string, *worker.Workspace, error) {
+	if os.Geteuid() == 0 {
+		exposeLauncher(t)
+	}
+	workspace, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	t.Cleanup(func() { workspace.Remove() })
+	programPath := workspace.ProgramPath("probe")
+	if err := os.WriteFile(programPath, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("The probe program creation failed! %v", err)
+	}
+	sandbox, err := worker.NewSandbox(limits, workspace)
+	if err != nil {
+		t.Fatalf("The sandbox creation failed! %v", err)
+	}
+	invocation, err := sandbox.Command(programPath)
+	if err != nil {
+		t.Fatalf("The invocation creation failed! %v", err)
+	}
+	defer invocation.Close()
+	output, err := invocation.Output()
+	return string(output), workspace, err
+}
+
+// writeWorkerSecret writes a secret file the way the kubelet projects the secret volumes of the worker pods, the
+// directory holding it is removed afterwards
+func writeWorkerSecret(t *testing.T, path string) {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err == nil {
+		t.Skipf("The secret directory %s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("The secret directory creation failed! %v", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		// The parent directory is only removed when no other secret is left in it
+		os.Remove(filepath.Dir(dir))
+	})
+	if err := os.WriteFile(path, []byte("secret"), 0400); err != nil {
+		t.Fatalf("The secret creation failed! %v", err)
+	}
+}
+
+func isRunning(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The killed processes may stay zombies when the reaper of the container doesn't collect them
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestSandboxKillsTheProcessGroupOnTimeout(t *testing.T) {
+	// Given
+	limits := &proto.ProgramLimits{TimeoutMs: 500}
+	script := "sleep 30 &\necho $!\nwait\n"
+
+	// When
+	start := time.Now()
+	output, _, err := runProbe(t, limits, script)
+	elapsed := time.Since(start)
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "exceeded its") {
+		t.Errorf("Expected the program to exceed its timeout but got %v", err)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("Expected the program to be killed right after its timeout but it took %v", elapsed)
+	}
+	pid, convErr := strconv.Atoi(strings.TrimSpace(output))
+	if convErr != nil {
+		t.Fatalf("The program child pid parsing failed! %v", convErr)
+	}
+	for deadline := time.Now().Add(time.Second); isRunning(pid) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isRunning(pid) {
+		t.Errorf("Expected the child %v of the program to be killed along with it", pid)
+	}
+}
+
+func TestSandboxAppliesTheLimitsAfterTheReExec(t *testing.T) {
+	// Given
+	limits := &proto.ProgramLimits{MemoryBytes: 2 << 30, CpuSeconds: 7, OpenFiles: 64}
+
+	// When
+	output, _, err := runProbe(t, limits, "ulimit -v\nulimit -t\nulimit -n\n")
+
+	// Then
+	if err != nil {
+		t.Fatalf("The probe program failed! %v", err)
+	}
+	expected := []string{"2097152", "7", "64"}
+	if values := strings.Fields(output); strings.Join(values, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected the memory, cpu and open files limits %v but got %v", expected, values)
+	}
+}
+
+func TestSandboxScrubsTheEnvironment(t *testing.T) {
+	// Given
+	t.Setenv("APOLLO_TEST_SECRET", "secret")
+
+	// When
+	output, workspace, err := runProbe(t, &proto.ProgramLimits{}, "pwd\nenv\n")
+
+	// Then
+	if err != nil {
+		t.Fatalf("The probe program failed! %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	dir, env := lines[0], map[string]string{}
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, "=")
+		env[name] = value
+	}
+	if _, exists := env["APOLLO_TEST_SECRET"]; exists {
+		t.Error("Expected the worker environment not to reach the program")
+	}
+	if env["HOME"] != dir || env["TMPDIR"] != dir || filepath.Dir(dir) != workspace.ScratchDir() {
+		t.Errorf("Expected HOME and TMPDIR to be the private directory %s but got %s and %s", dir, env["HOME"], env["TMPDIR"])
+	}
+	if env[worker.SocketDirEnv] != workspace.SocketDir() {
+		t.Errorf("Expected %s to be %s but got %s", worker.SocketDirEnv, workspace.SocketDir(), env[worker.SocketDirEnv])
+	}
+	for _, name := range []string{"PATH", "HOME", "TMPDIR", worker.SocketDirEnv} {
+		delete(env, name)
+	}
+	// The shell sets a few variables of its own
+	for _, name := range []string{"PWD", "OLDPWD", "SHLVL", "_"} {
+		delete(env, name)
+	}
+	if len(env) != 0 {
+		t.Errorf("Expected the program environment to be scrubbed but found %v", env)
+	}
+}
+
+func TestSandboxSwitchesToTheProgramUser(t *testing.T) {
+	// Given
+	if os.Geteuid() != 0 {
+		t.Skip("The programs only switch users when the worker runs as root")
+	}
+	t.Setenv(worker.ProgramUIDEnv, "12345")
+	t.Setenv(worker.ProgramGIDEnv, "23456")
+
+	// When
+	output, _, err := runProbe(t, &proto.ProgramLimits{}, "id -u\nid -g\n")
+
+	// Then
+	if err != nil {
+		t.Fatalf("The probe program failed! %v", err)
+	}
+	if ids := strings.Fields(output); strings.Join(ids, " ") != "12345 23456" {
+		t.Errorf("Expected the program to run as 12345:23456 but got %v", ids)
+	}
+}
+
+func TestSandboxedProgramsCantReadTheWorkerSecrets(t *testing.T) {
+	// Given
+	if os.Geteuid() != 0 {
+		t.Skip("The programs only run as another user than the worker when it runs as root")
+	}
+	secrets := []string{
+		filepath.Join(utils.TLSDir, "tls.key"),
+		filepath.Join(coreio.CredentialsDir, coreio.CredentialsFile),
+	}
+	for _, secret := range secrets {
+		writeWorkerSecret(t, secret)
+	}
+	script := fmt.Sprintf("for secret in %s; do cat $secret 2>/dev/null && echo \" read $secret\"; done\nexit 0\n",
+		strings.Join(secrets, " "))
+
+	// When
+	output, _, err := runProbe(t, &proto.ProgramLimits{}, script)
+
+	// Then
+	if err != nil {
+		t.Fatalf("The probe program failed! %v", err)
+	}
+	if output != "" {
+		t.Errorf("Expected the program not to read the worker secrets but got %q", output)
+	}
+}
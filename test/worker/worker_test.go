@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Assifar-Karim/apollo/internal/proto"
+	"github.com/Assifar-Karim/apollo/internal/worker"
+)
+
+func TestGetCompletionIndex(t *testing.T) {
+	// Given
+	t.Setenv(worker.CompletionIndexEnv, "3")
+
+	// When
+	index, err := worker.GetCompletionIndex()
+
+	// Then
+	if err != nil {
+		t.Fatalf("The completion index read failed! %v", err)
+	}
+	if index == nil || *index != 3 {
+		t.Errorf("Expected completion index 3 but found %v", index)
+	}
+}
+
+func TestGetCompletionIndexRejectsInvalidValue(t *testing.T) {
+	// Given
+	t.Setenv(worker.CompletionIndexEnv, "not-an-index")
+
+	// When
+	_, err := worker.GetCompletionIndex()
+
+	// Then
+	if err == nil {
+		t.Error("Expected an invalid completion index to be rejected")
+	}
+}
+
+func TestProgressCountersIncludeBuiltInCounters(t *testing.T) {
+	// Given
+	progress := &worker.Progress{}
+	progress.AddCounters(map[string]int64{"malformed": 1})
+
+	// When
+	progress.AddCounters(map[string]int64{"malformed": 2, worker.CounterInputRecords: 99})
+	progress.AddRecords(4)
+	progress.AddOutput(3, 120)
+	counters := progress.Counters()
+
+	// Then
+	expected := map[string]int64{
+		"malformed":                3,
+		worker.CounterInputRecords: 4,
+		worker.CounterInputBytes:   0,
+		worker.CounterOutputPairs:  3,
+		worker.CounterOutputBytes:  120,
+	}
+	if !reflect.DeepEqual(counters, expected) {
+		t.Errorf("Expected counters %v but found %v", expected, counters)
+	}
+}
+
+func TestNewSandboxRejectsInvalidProgramUser(t *testing.T) {
+	// Given
+	if os.Geteuid() != 0 {
+		t.Skip("The programs only switch users when the worker runs as root")
+	}
+	t.Setenv(worker.ProgramUIDEnv, "nobody")
+	workspace, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	defer workspace.Remove()
+
+	// When
+	_, err = worker.NewSandbox(&proto.ProgramLimits{}, workspace)
+
+	// Then
+	if err == nil {
+		t.Error("Expected an invalid program user id to be rejected")
+	}
+}
+
+func TestRunSandboxedRejectsIncompleteLimits(t *testing.T) {
+	// When
+	missingProgramErr := worker.RunSandboxed([]string{"0", "0", "0"})
+	invalidLimitErr := worker.RunSandboxed([]string{"1Gi", "0", "0", "/bin/true"})
+
+	// Then
+	if missingProgramErr == nil || invalidLimitErr == nil {
+		t.Errorf("Expected the sandbox to reject its arguments but got %v and %v", missingProgramErr, invalidLimitErr)
+	}
+}
+
+func TestGetProgramConcurrency(t *testing.T) {
+	// Given
+	t.Setenv(worker.CPUAllocationEnv, "3")
+	concurrency := int64(5)
+
+	// When
+	jobConcurrency := worker.GetProgramConcurrency(&proto.Task{ProgramConcurrency: &concurrency})
+	defaultConcurrency := worker.GetProgramConcurrency(&proto.Task{})
+
+	// Then
+	if jobConcurrency != 5 {
+		t.Errorf("Expected the job program concurrency 5 but found %v", jobConcurrency)
+	}
+	if defaultConcurrency != 3*worker.ProgramsPerCPU {
+		t.Errorf("Expected a program concurrency of %v for 3 CPUs but found %v", 3*worker.ProgramsPerCPU, defaultConcurrency)
+	}
+}
+
+func TestNewWorkspaceIsolatesTasks(t *testing.T) {
+	// Given
+	first, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	second, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+
+	// When
+	firstSocket, secondSocket := first.SocketPath("map.sock"), second.SocketPath("map.sock")
+	firstProgram, secondProgram := first.ProgramPath("/apollo/mapper"), second.ProgramPath("/apollo/mapper")
+	first.Remove()
+	_, statErr := os.Stat(first.ScratchDir())
+	second.Remove()
+
+	// Then
+	if firstSocket == secondSocket || firstProgram == secondProgram {
+		t.Errorf("Expected the workspaces not to share %s and %s", firstSocket, firstProgram)
+	}
+	if !os.IsNotExist(statErr) {
+		t.Errorf("Expected a removed workspace to be gone but got %v", statErr)
+	}
+}
+
+func TestOnlyOneWorkspaceGetsTheLegacySocketDir(t *testing.T) {
+	// Given
+	worker.AllowLegacySockets(true)
+	defer worker.AllowLegacySockets(false)
+	first, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	second, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	defer second.Remove()
+
+	// When
+	firstSocketDir, secondSocketDir := first.SocketDir(), second.SocketDir()
+	first.Remove()
+	third, err := worker.NewWorkspace()
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	defer third.Remove()
+
+	// Then
+	if firstSocketDir != worker.LegacySocketDir {
+		t.Errorf("Expected the only task of the worker to get %s but got %s", worker.LegacySocketDir, firstSocketDir)
+	}
+	if secondSocketDir == worker.LegacySocketDir {
+		t.Errorf("Expected a task running along another one to get its own socket directory")
+	}
+	if third.SocketDir() != worker.LegacySocketDir {
+		t.Errorf("Expected %s to be handed out again once released but got %s", worker.LegacySocketDir, third.SocketDir())
+	}
+}
+
+func TestWorkspacesDontGetTheLegacySocketDirUnlessAllowed(t *testing.T) {
+	// Given
+	worker.AllowLegacySockets(false)
+
+	// When
+	workspace, err := worker.NewWorkspace()
+
+	// Then
+	if err != nil {
+		t.Fatalf("The workspace creation failed! %v", err)
+	}
+	defer workspace.Remove()
+	if workspace.SocketDir() == worker.LegacySocketDir {
+		t.Errorf("Expected the task of a worker running tasks at once to get its own socket directory")
+	}
+}